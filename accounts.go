@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bariiss/pam-auth/util/accounts"
+	"github.com/spf13/cobra"
+)
+
+// accountsCmd groups the local account subcommands
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Inspect local accounts",
+}
+
+// accountsReportCmd lists accounts that need attention
+var accountsReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "List locked, expired, never-expiring and passwordless accounts",
+	Long: `Read the passwd and shadow files (under --root) and list the accounts
+that are locked, expired (account expiry or password past its maximum age),
+have a password that never expires, or need no password at all.
+
+Only regular accounts between UID_MIN and UID_MAX of login.defs and root are
+checked unless --all is given. Reading /etc/shadow requires root.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runAccountsReport()
+	},
+}
+
+// accounts report flags
+var (
+	accountsAll  bool
+	accountsJSON bool
+)
+
+func init() {
+	accountsReportCmd.Flags().BoolVar(&accountsAll, "all", false, "Include system accounts")
+	accountsReportCmd.Flags().BoolVar(&accountsJSON, "json", false, "Print the report as JSON")
+	accountsCmd.AddCommand(accountsReportCmd)
+}
+
+// runAccountsReport prints the flagged accounts
+func runAccountsReport() error {
+	flagged, inspected, err := accounts.Report(sysRoot(), accounts.Options{All: accountsAll})
+	if err != nil {
+		return err
+	}
+
+	if accountsJSON {
+		if flagged == nil {
+			flagged = []accounts.Account{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(flagged)
+	}
+
+	if len(flagged) == 0 {
+		fmt.Printf("✅ None of the %d accounts checked is locked, expired, never-expiring or passwordless\n", inspected)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tUID\tSTATUS\tDETAILS")
+	for _, account := range flagged {
+		status := make([]string, len(account.Categories))
+		for i, category := range account.Categories {
+			status[i] = string(category)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", account.Name, account.UID, strings.Join(status, ","), strings.Join(account.Details, "; "))
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, category := range accounts.Categories {
+		count := 0
+		for _, account := range flagged {
+			if account.Has(category) {
+				count++
+			}
+		}
+		fmt.Printf("%s: %d  ", category, count)
+	}
+	fmt.Printf("(%d accounts checked)\n", inspected)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/backend"
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/bariiss/pam-auth/util/tracing"
+	"golang.org/x/term"
+)
+
+// backendConfig is filled in by the backend selection flags
+var backendConfig = backend.DefaultConfig()
+
+func init() {
+	cfg := &backendConfig
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&cfg.Name, "backend", cfg.Name, "Authentication backend: system, radius, krb5, htpasswd, db")
+	flags.DurationVar(&cfg.Timeout, "timeout", 0, "Give up on an authentication attempt after this long (0 for no limit)")
+
+	flags.StringArrayVar(&cfg.RADIUSServers, "radius-server", nil, "RADIUS server host:port for the radius backend (repeatable, tried in order)")
+	flags.StringVar(&cfg.RADIUSSecret, "radius-secret", "", "Shared secret for the radius backend")
+	flags.DurationVar(&cfg.RADIUSTimeout, "radius-timeout", cfg.RADIUSTimeout, "Timeout for each RADIUS attempt")
+	flags.IntVar(&cfg.RADIUSRetries, "radius-retries", cfg.RADIUSRetries, "Retries per RADIUS server before failing over")
+	flags.StringArrayVar(&cfg.RADIUSGroupMap, "radius-group", nil, "Map a Class/Filter-Id value to a group as VALUE=GROUP (repeatable)")
+
+	flags.StringVar(&cfg.KRB5Realm, "krb5-realm", "", "Kerberos realm (defaults to default_realm from krb5.conf)")
+	flags.StringArrayVar(&cfg.KRB5AllowedRealms, "krb5-allowed-realm", nil, "Other realm usernames may name as user@REALM (repeatable; others are refused)")
+	flags.StringVar(&cfg.KRB5Config, "krb5-config", "", "krb5.conf path (defaults to $KRB5_CONFIG or /etc/krb5.conf)")
+	flags.StringArrayVar(&cfg.KRB5KDCs, "krb5-kdc", nil, "KDC host:port, overriding krb5.conf (repeatable)")
+	flags.StringVar(&cfg.KRB5Keytab, "krb5-keytab", cfg.KRB5Keytab, "Keytab used to verify tickets against KDC spoofing")
+	flags.StringVar(&cfg.KRB5ServicePrinc, "krb5-service-principal", "", "Principal in the keytab used for verification (default host/<hostname>)")
+	flags.BoolVar(&cfg.KRB5AllowUnverified, "krb5-allow-unverified", false, "Accept TGTs without keytab verification (vulnerable to KDC spoofing)")
+	flags.StringVar(&cfg.KRB5CCache, "krb5-ccache", "", "Write the user's TGT to this credential cache, e.g. /tmp/krb5cc_%{uid}")
+
+	flags.StringVar(&cfg.HtpasswdFile, "htpasswd-file", "", "htpasswd file for the htpasswd backend")
+	flags.BoolVar(&cfg.HtpasswdUpgrade, "htpasswd-upgrade", false, "Re-hash non-bcrypt htpasswd entries to bcrypt after a successful login")
+	flags.BoolVar(&cfg.HtpasswdPlaintext, "htpasswd-plaintext", false, "Accept plaintext htpasswd entries (htpasswd -p), which RADIUS CHAP and MS-CHAPv2 need")
+
+	flags.StringVar(&cfg.DBFile, "db-file", cfg.DBFile, "SQLite user database for the db backend and db commands")
+
+	flags.StringVar(&cfg.OfflineCache, "offline-cache", "", "Encrypted offline credential cache for the radius and krb5 backends")
+	flags.StringVar(&cfg.OfflineKey, "offline-key", "", "Key file for the offline cache (default the cache path plus .key, created if missing)")
+	flags.DurationVar(&cfg.OfflineTTL, "offline-ttl", cfg.OfflineTTL, "How long a cached user can log in offline after their last online login")
+	flags.DurationVar(&cfg.OfflineMaxAge, "offline-max-age", cfg.OfflineMaxAge, "Refuse offline logins once the backends have been unreachable this long (0 for no limit)")
+
+	cfg.RADIUSPrompt = promptSecret
+}
+
+// newAuthenticator returns the authenticator selected with --backend
+func newAuthenticator() (auth.Authenticator, error) {
+	if backendConfig.Name == "system" || backendConfig.Name == "" {
+		return systemAuthenticator{}, nil
+	}
+	return backend.New(backendConfig)
+}
+
+// newServerAuthenticator returns the --backend authenticator for the network
+// servers and exec. Their callers may send any user's password, so on Linux
+// the system backend checks it against the shadow file unless --real-pam is
+// given and libpam is linked in: getent only proves that the user exists.
+func newServerAuthenticator() (auth.Authenticator, error) {
+	verifyShadow = runtime.GOOS == "linux" && !(useRealPAM && pam.RealPAMCompiled)
+	return newAuthenticator()
+}
+
+// exitTimeout is the exit status of attempts stopped by --timeout, as with timeout(1)
+const exitTimeout = 124
+
+// authContext bounds one authentication attempt by --timeout and cancels it
+// on SIGINT or SIGTERM. It continues the trace passed in TRACEPARENT.
+func authContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(tracing.FromEnvironment(context.Background()), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := auth.WithTimeout(ctx, backendConfig.Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// promptSecret asks the user for a hidden answer through the terminal
+func promptSecret(message string) (string, error) {
+	fmt.Print(message)
+	answer, err := readPassword()
+	fmt.Println()
+	return answer, err
+}
+
+// readPassword reads a password without echo, or a plain line when stdin is not a terminal
+func readPassword() (string, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		line, err := stdinReader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return "", err
+	}
+	return string(passwordBytes), nil
+}
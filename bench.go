@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/bench"
+	"github.com/bariiss/pam-auth/util/fake"
+	"github.com/spf13/cobra"
+)
+
+// benchCmd load-tests the configured authenticator
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Load-test the authentication backends",
+	Long: `Drive the backend selected with --backend from many concurrent workers
+and report throughput, latency percentiles per outcome and windows with an
+error spike. Credentials are taken round robin from --users, a file of
+"username:password" lines. Use --backend radius --radius-server HOST:PORT to
+measure a running radius-serve, or --backend fake for reproducible numbers:
+the fake backend only exists in bench and accepts --fake-password for every
+user. Each attempt is bounded by --timeout.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// A failed run is not a usage error
+		cmd.SilenceUsage = true
+		return runBench()
+	},
+}
+
+// bench flags
+var (
+	benchConcurrency    int
+	benchDuration       time.Duration
+	benchRequests       int
+	benchUsersFile      string
+	benchWindow         time.Duration
+	benchSpikeThreshold float64
+	benchSpikeMinErrors int
+
+	// The fake backend is a deterministic stand-in for benchmarks
+	benchFakePassword  string
+	benchFakeLatency   time.Duration
+	benchFakeErrorRate float64
+)
+
+func init() {
+	flags := benchCmd.Flags()
+	flags.IntVarP(&benchConcurrency, "concurrency", "c", 4, "Attempts in flight at once")
+	flags.DurationVarP(&benchDuration, "duration", "d", 10*time.Second, "How long to run (0 to run until --requests)")
+	flags.IntVarP(&benchRequests, "requests", "n", 0, "Stop after this many attempts (0 for no limit)")
+	flags.StringVar(&benchUsersFile, "users", "", "File with \"username:password\" lines to log in with")
+	flags.DurationVar(&benchWindow, "window", time.Second, "Interval error rates are checked over")
+	flags.Float64Var(&benchSpikeThreshold, "spike-threshold", 0.1, "Error and timeout rate in a window that counts as a spike")
+	flags.IntVar(&benchSpikeMinErrors, "spike-min-errors", 3, "Errors a window needs before it can count as a spike")
+	flags.StringVar(&benchFakePassword, "fake-password", fake.DefaultPassword, "Password the fake backend accepts for every user")
+	flags.DurationVar(&benchFakeLatency, "fake-latency", 0, "Latency the fake backend adds to every attempt")
+	flags.Float64Var(&benchFakeErrorRate, "fake-error-rate", 0, "Fraction of fake backend attempts reported as unreachable (0 to 1)")
+	benchCmd.MarkFlagRequired("users")
+}
+
+// runBench runs the benchmark and prints the report. It fails when an
+// error spike was detected.
+func runBench() error {
+	if benchDuration <= 0 && benchRequests <= 0 {
+		return fmt.Errorf("set --duration or --requests")
+	}
+	users, err := bench.LoadUsers(benchUsersFile)
+	if err != nil {
+		return err
+	}
+
+	// Access-Challenge prompts would stall the workers
+	backendConfig.RADIUSPrompt = nil
+	authenticator, err := newBenchAuthenticator()
+	if err != nil {
+		return err
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+	startPAMPool()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("🏁 Benchmarking the %s backend with %d workers and %d users", backendConfig.Name, benchConcurrency, len(users))
+	if benchDuration > 0 {
+		fmt.Printf(" for %s", benchDuration)
+	}
+	fmt.Println()
+
+	report := bench.Run(ctx, authenticator, users, bench.Options{
+		Concurrency: benchConcurrency,
+		Duration:    benchDuration,
+		Requests:    benchRequests,
+		Timeout:     backendConfig.Timeout,
+		Window:      benchWindow,
+	})
+	if ctx.Err() != nil {
+		fmt.Println("🛑 Benchmark interrupted - partial results")
+	}
+	printBenchReport(report)
+	showPAMPoolStats()
+
+	spikes := report.Spikes(benchSpikeThreshold, benchSpikeMinErrors)
+	if len(spikes) == 0 {
+		fmt.Printf("✅ No error spikes (threshold %.0f%% per %s window)\n", benchSpikeThreshold*100, benchWindow)
+		return nil
+	}
+	for _, spike := range spikes {
+		fmt.Printf("⚠️ Error spike at +%s: %d of %d attempts failed (%.0f%%)\n",
+			spike.Start, spike.Errors, spike.Total, spike.ErrorRate()*100)
+	}
+	return fmt.Errorf("%d error spike(s) detected", len(spikes))
+}
+
+// newBenchAuthenticator returns the --backend authenticator, or the fake
+// backend that only bench offers
+func newBenchAuthenticator() (auth.Authenticator, error) {
+	if backendConfig.Name != "fake" {
+		return newAuthenticator()
+	}
+	if benchFakeErrorRate < 0 || benchFakeErrorRate > 1 {
+		return nil, fmt.Errorf("--fake-error-rate must be between 0 and 1")
+	}
+	authenticator := fake.New(benchFakePassword)
+	authenticator.Latency = benchFakeLatency
+	authenticator.ErrorRate = benchFakeErrorRate
+	return authenticator, nil
+}
+
+// printBenchReport prints the throughput and the latency table
+func printBenchReport(report bench.Report) {
+	fmt.Printf("📊 %d attempts in %s (%.1f/s)\n", report.Total, report.Elapsed.Round(time.Millisecond), report.Throughput())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OUTCOME\tCOUNT\tSHARE\tMIN\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, outcome := range bench.Outcomes {
+		stats, ok := report.Outcomes[outcome]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\t%s\t%s\t%s\t%s\t%s\n", outcome, stats.Count,
+			float64(stats.Count)*100/float64(report.Total), benchLatency(stats.Min), benchLatency(stats.Mean),
+			benchLatency(stats.P50), benchLatency(stats.P90), benchLatency(stats.P99), benchLatency(stats.Max))
+	}
+	w.Flush()
+}
+
+// benchLatency rounds a latency for display
+func benchLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,326 @@
+// libnss_pamauth is a glibc NSS module resolving pam-auth database users
+// and groups from the cache written by "pam-auth db nss-update". It is
+// plain C rather than a Go c-shared library so that loading it into a Go
+// program, such as pam-auth itself resolving users through os/user, does
+// not start a second Go runtime in the process. Build it with
+//
+//	cc -shared -fPIC -Wl,-soname,libnss_pamauth.so.2 -o libnss_pamauth.so.2 cmd/nss_pamauth/nss_pamauth.c
+//
+// install it next to libnss_files.so.2 and add "pamauth" to the passwd,
+// group and shadow lines of /etc/nsswitch.conf.
+
+#define _GNU_SOURCE
+#include <errno.h>
+#include <grp.h>
+#include <limits.h>
+#include <nss.h>
+#include <pthread.h>
+#include <pwd.h>
+#include <shadow.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#define DEFAULT_CACHE_DIR "/var/lib/pam-auth/nss"
+
+// Enumeration streams for getpwent, getgrent and getspent
+static pthread_mutex_t ent_lock = PTHREAD_MUTEX_INITIALIZER;
+static FILE *pwent, *grent, *spent;
+
+// cache_open opens one file of the cache directory. PAMAUTH_NSS_CACHE
+// overrides the directory except for setuid and other secure-execution
+// processes.
+static FILE *cache_open(const char *name)
+{
+	const char *dir = secure_getenv("PAMAUTH_NSS_CACHE");
+	char path[PATH_MAX];
+
+	if (dir == NULL || *dir == '\0')
+		dir = DEFAULT_CACHE_DIR;
+	if (snprintf(path, sizeof(path), "%s/%s", dir, name) >= (int)sizeof(path)) {
+		errno = ENAMETOOLONG;
+		return NULL;
+	}
+	return fopen(path, "re");
+}
+
+// unavailable reports a missing cache
+static enum nss_status unavailable(int *errnop)
+{
+	*errnop = errno;
+	return NSS_STATUS_UNAVAIL;
+}
+
+// read_status converts the result of an fget*ent_r call. ERANGE asks the
+// caller to retry with a larger buffer; the stream is left at the same
+// entry.
+static enum nss_status read_status(int err, int *errnop)
+{
+	switch (err) {
+	case 0:
+		return NSS_STATUS_SUCCESS;
+	case ERANGE:
+		*errnop = ERANGE;
+		return NSS_STATUS_TRYAGAIN;
+	default:
+		*errnop = ENOENT;
+		return NSS_STATUS_NOTFOUND;
+	}
+}
+
+// ent_reset closes an enumeration stream so the next call starts over
+static enum nss_status ent_reset(FILE **fp)
+{
+	pthread_mutex_lock(&ent_lock);
+	if (*fp != NULL) {
+		fclose(*fp);
+		*fp = NULL;
+	}
+	pthread_mutex_unlock(&ent_lock);
+	return NSS_STATUS_SUCCESS;
+}
+
+enum nss_status _nss_pamauth_getpwnam_r(const char *name, struct passwd *result, char *buffer,
+					size_t buflen, int *errnop)
+{
+	FILE *fp = cache_open("passwd");
+	struct passwd *entry;
+	int err;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	while ((err = fgetpwent_r(fp, result, buffer, buflen, &entry)) == 0)
+		if (strcmp(result->pw_name, name) == 0)
+			break;
+	fclose(fp);
+	return read_status(err, errnop);
+}
+
+enum nss_status _nss_pamauth_getpwuid_r(uid_t uid, struct passwd *result, char *buffer,
+					size_t buflen, int *errnop)
+{
+	FILE *fp = cache_open("passwd");
+	struct passwd *entry;
+	int err;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	while ((err = fgetpwent_r(fp, result, buffer, buflen, &entry)) == 0)
+		if (result->pw_uid == uid)
+			break;
+	fclose(fp);
+	return read_status(err, errnop);
+}
+
+enum nss_status _nss_pamauth_setpwent(int stayopen)
+{
+	(void)stayopen;
+	return ent_reset(&pwent);
+}
+
+enum nss_status _nss_pamauth_endpwent(void)
+{
+	return ent_reset(&pwent);
+}
+
+enum nss_status _nss_pamauth_getpwent_r(struct passwd *result, char *buffer, size_t buflen,
+					int *errnop)
+{
+	struct passwd *entry;
+	enum nss_status status;
+
+	pthread_mutex_lock(&ent_lock);
+	if (pwent == NULL && (pwent = cache_open("passwd")) == NULL)
+		status = unavailable(errnop);
+	else
+		status = read_status(fgetpwent_r(pwent, result, buffer, buflen, &entry), errnop);
+	pthread_mutex_unlock(&ent_lock);
+	return status;
+}
+
+enum nss_status _nss_pamauth_getgrnam_r(const char *name, struct group *result, char *buffer,
+					size_t buflen, int *errnop)
+{
+	FILE *fp = cache_open("group");
+	struct group *entry;
+	int err;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	while ((err = fgetgrent_r(fp, result, buffer, buflen, &entry)) == 0)
+		if (strcmp(result->gr_name, name) == 0)
+			break;
+	fclose(fp);
+	return read_status(err, errnop);
+}
+
+enum nss_status _nss_pamauth_getgrgid_r(gid_t gid, struct group *result, char *buffer,
+					size_t buflen, int *errnop)
+{
+	FILE *fp = cache_open("group");
+	struct group *entry;
+	int err;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	while ((err = fgetgrent_r(fp, result, buffer, buflen, &entry)) == 0)
+		if (result->gr_gid == gid)
+			break;
+	fclose(fp);
+	return read_status(err, errnop);
+}
+
+enum nss_status _nss_pamauth_setgrent(int stayopen)
+{
+	(void)stayopen;
+	return ent_reset(&grent);
+}
+
+enum nss_status _nss_pamauth_endgrent(void)
+{
+	return ent_reset(&grent);
+}
+
+enum nss_status _nss_pamauth_getgrent_r(struct group *result, char *buffer, size_t buflen,
+					int *errnop)
+{
+	struct group *entry;
+	enum nss_status status;
+
+	pthread_mutex_lock(&ent_lock);
+	if (grent == NULL && (grent = cache_open("group")) == NULL)
+		status = unavailable(errnop);
+	else
+		status = read_status(fgetgrent_r(grent, result, buffer, buflen, &entry), errnop);
+	pthread_mutex_unlock(&ent_lock);
+	return status;
+}
+
+// add_group appends gid to an initgroups_dyn result, growing it up to
+// limit; returns 0 when the list is full
+static int add_group(gid_t gid, long int *start, long int *size, gid_t **groupsp, long int limit)
+{
+	long int i, newsize;
+	gid_t *groups;
+
+	for (i = 0; i < *start; i++)
+		if ((*groupsp)[i] == gid)
+			return 1;
+
+	if (*start == *size) {
+		newsize = *size > 0 ? *size * 2 : 16;
+		if (limit > 0 && newsize > limit)
+			newsize = limit;
+		if (newsize <= *size)
+			return 0;
+		groups = realloc(*groupsp, newsize * sizeof(gid_t));
+		if (groups == NULL)
+			return 0;
+		*groupsp = groups;
+		*size = newsize;
+	}
+	(*groupsp)[(*start)++] = gid;
+	return 1;
+}
+
+// is_member reports whether user is listed in gr
+static int is_member(const struct group *gr, const char *user)
+{
+	char **member;
+
+	for (member = gr->gr_mem; *member != NULL; member++)
+		if (strcmp(*member, user) == 0)
+			return 1;
+	return 0;
+}
+
+enum nss_status _nss_pamauth_initgroups_dyn(const char *user, gid_t group, long int *start,
+					    long int *size, gid_t **groupsp, long int limit,
+					    int *errnop)
+{
+	FILE *fp = cache_open("group");
+	struct group gr, *entry;
+	size_t buflen = 1024;
+	char *buffer = NULL, *grown;
+	int err, found = 0;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	for (;;) {
+		if (buffer == NULL) {
+			if ((buffer = malloc(buflen)) == NULL) {
+				fclose(fp);
+				*errnop = ENOMEM;
+				return NSS_STATUS_TRYAGAIN;
+			}
+		}
+		err = fgetgrent_r(fp, &gr, buffer, buflen, &entry);
+		if (err == ERANGE) {
+			buflen *= 2;
+			if ((grown = realloc(buffer, buflen)) == NULL) {
+				free(buffer);
+				fclose(fp);
+				*errnop = ENOMEM;
+				return NSS_STATUS_TRYAGAIN;
+			}
+			buffer = grown;
+			continue;
+		}
+		if (err != 0)
+			break;
+		if (!is_member(&gr, user))
+			continue;
+		found = 1;
+		if (gr.gr_gid != group && !add_group(gr.gr_gid, start, size, groupsp, limit))
+			break;
+	}
+	free(buffer);
+	fclose(fp);
+	if (!found) {
+		*errnop = ENOENT;
+		return NSS_STATUS_NOTFOUND;
+	}
+	return NSS_STATUS_SUCCESS;
+}
+
+enum nss_status _nss_pamauth_getspnam_r(const char *name, struct spwd *result, char *buffer,
+					size_t buflen, int *errnop)
+{
+	FILE *fp = cache_open("shadow");
+	struct spwd *entry;
+	int err;
+
+	if (fp == NULL)
+		return unavailable(errnop);
+	while ((err = fgetspent_r(fp, result, buffer, buflen, &entry)) == 0)
+		if (strcmp(result->sp_namp, name) == 0)
+			break;
+	fclose(fp);
+	return read_status(err, errnop);
+}
+
+enum nss_status _nss_pamauth_setspent(int stayopen)
+{
+	(void)stayopen;
+	return ent_reset(&spent);
+}
+
+enum nss_status _nss_pamauth_endspent(void)
+{
+	return ent_reset(&spent);
+}
+
+enum nss_status _nss_pamauth_getspent_r(struct spwd *result, char *buffer, size_t buflen,
+					int *errnop)
+{
+	struct spwd *entry;
+	enum nss_status status;
+
+	pthread_mutex_lock(&ent_lock);
+	if (spent == NULL && (spent = cache_open("shadow")) == NULL)
+		status = unavailable(errnop);
+	else
+		status = read_status(fgetspent_r(spent, result, buffer, buflen, &entry), errnop);
+	pthread_mutex_unlock(&ent_lock);
+	return status;
+}
@@ -0,0 +1,294 @@
+//go:build linux && pam
+
+// Command pam_pamauth is a PAM service module exposing the pam-auth backends
+// to PAM aware programs such as sshd and sudo. Build it with
+//
+//	go build -tags pam -buildmode=c-shared -o pam_pamauth.so ./cmd/pam_pamauth
+//
+// and reference it from /etc/pam.d, passing pam-auth flags as module arguments:
+//
+//	auth    required  pam_pamauth.so backend=db db-file=/var/lib/pam-auth/users.db
+//	account required  pam_pamauth.so backend=db db-file=/var/lib/pam-auth/users.db
+package main
+
+/*
+#cgo LDFLAGS: -lpam
+#include <stdlib.h>
+#include <security/pam_modules.h>
+
+typedef const char const_char_t;
+
+int pamauth_converse(pam_handle_t *pamh, int style, const char *text, char **answer);
+const char *pamauth_get_string_item(pam_handle_t *pamh, int type);
+const char *pamauth_get_user(pam_handle_t *pamh);
+void pamauth_free_secret(char *secret);
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+	"unsafe"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/backend"
+)
+
+// PAM return codes and conversation styles used by the module
+const (
+	pamSuccess         = int(C.PAM_SUCCESS)
+	pamServiceErr      = int(C.PAM_SERVICE_ERR)
+	pamAuthErr         = int(C.PAM_AUTH_ERR)
+	pamAuthInfoUnavail = int(C.PAM_AUTHINFO_UNAVAIL)
+	pamUserUnknown     = int(C.PAM_USER_UNKNOWN)
+	pamPermDenied      = int(C.PAM_PERM_DENIED)
+	pamAcctExpired     = int(C.PAM_ACCT_EXPIRED)
+	pamConvErr         = int(C.PAM_CONV_ERR)
+	pamIgnore          = int(C.PAM_IGNORE)
+
+	pamSilent = int(C.PAM_SILENT)
+
+	promptEchoOff = int(C.PAM_PROMPT_ECHO_OFF)
+	promptEchoOn  = int(C.PAM_PROMPT_ECHO_ON)
+	errorMsg      = int(C.PAM_ERROR_MSG)
+)
+
+// handle wraps a pam_handle_t for the duration of one module call
+type handle struct {
+	pamh  *C.pam_handle_t
+	flags int
+}
+
+// user returns PAM_USER, prompting for it when the application has not set it
+func (h handle) user() string {
+	user := C.pamauth_get_user(h.pamh)
+	if user == nil {
+		return ""
+	}
+	return C.GoString(user)
+}
+
+// item returns a string item such as PAM_RHOST or PAM_AUTHTOK
+func (h handle) item(kind C.int) string {
+	value := C.pamauth_get_string_item(h.pamh, kind)
+	if value == nil {
+		return ""
+	}
+	return C.GoString(value)
+}
+
+// service returns PAM_SERVICE, the name of the calling application's stack
+func (h handle) service() string {
+	return h.item(C.PAM_SERVICE)
+}
+
+// rhost returns PAM_RHOST, the remote host the user connects from
+func (h handle) rhost() string {
+	return h.item(C.PAM_RHOST)
+}
+
+// setAuthtok stores password as PAM_AUTHTOK for modules further down the stack
+func (h handle) setAuthtok(password string) {
+	value := C.CString(password)
+	defer C.pamauth_free_secret(value)
+	C.pam_set_item(h.pamh, C.PAM_AUTHTOK, unsafe.Pointer(value))
+}
+
+// converse shows text to the user and returns their answer
+func (h handle) converse(style int, text string) (string, error) {
+	ctext := C.CString(text)
+	defer C.free(unsafe.Pointer(ctext))
+
+	var answer *C.char
+	if ret := C.pamauth_converse(h.pamh, C.int(style), ctext, &answer); ret != C.PAM_SUCCESS {
+		return "", errors.New(C.GoString(C.pam_strerror(h.pamh, ret)))
+	}
+	defer C.pamauth_free_secret(answer)
+	if answer == nil {
+		return "", nil
+	}
+	return C.GoString(answer), nil
+}
+
+// prompt asks for a hidden answer, as the RADIUS backend does for challenges
+func (h handle) prompt(text string) (string, error) {
+	return h.converse(promptEchoOff, text)
+}
+
+// message shows an error message unless the application asked for silence
+func (h handle) message(text string) {
+	if h.flags&pamSilent == 0 {
+		h.converse(errorMsg, text)
+	}
+}
+
+// goArgs converts the module argument vector
+func goArgs(argc C.int, argv **C.const_char_t) []string {
+	if argc <= 0 || argv == nil {
+		return nil
+	}
+	args := make([]string, 0, int(argc))
+	for _, arg := range unsafe.Slice(argv, int(argc)) {
+		args = append(args, C.GoString((*C.char)(arg)))
+	}
+	return args
+}
+
+//export pam_sm_authenticate
+func pam_sm_authenticate(pamh *C.pam_handle_t, flags C.int, argc C.int, argv **C.const_char_t) C.int {
+	return C.int(authenticate(handle{pamh, int(flags)}, goArgs(argc, argv)))
+}
+
+//export pam_sm_acct_mgmt
+func pam_sm_acct_mgmt(pamh *C.pam_handle_t, flags C.int, argc C.int, argv **C.const_char_t) C.int {
+	return C.int(accountManagement(handle{pamh, int(flags)}, goArgs(argc, argv)))
+}
+
+//export pam_sm_setcred
+func pam_sm_setcred(pamh *C.pam_handle_t, flags C.int, argc C.int, argv **C.const_char_t) C.int {
+	return C.int(pamSuccess)
+}
+
+// authenticate implements pam_sm_authenticate
+func authenticate(h handle, args []string) int {
+	opts, err := parseOptions(args)
+	if err != nil {
+		logError("%v", err)
+		return pamServiceErr
+	}
+	username := h.user()
+	if username == "" {
+		return pamUserUnknown
+	}
+	opts.Backend.RADIUSPrompt = h.prompt
+
+	authenticator, err := backend.New(opts.Backend)
+	if err != nil {
+		logError("cannot use backend %s: %v", opts.Backend.Name, err)
+		return pamAuthInfoUnavail
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	ctx, cancel := auth.WithTimeout(context.Background(), opts.Backend.Timeout)
+	defer cancel()
+	result, ret := opts.checkPassword(ctx, h, authenticator, username)
+	if ret == pamSuccess {
+		ret = opts.checkSecondFactor(h, username)
+		if ret != pamSuccess {
+			result.Success = false
+			result.Message = "second factor rejected"
+		}
+	}
+	opts.record(h, result)
+	if ret != pamSuccess {
+		opts.debug("authentication failed for %s: %s", username, result.Message)
+	}
+	return ret
+}
+
+// checkPassword obtains the password, honouring use_first_pass and
+// try_first_pass, and verifies it with the backend
+func (o *options) checkPassword(ctx context.Context, h handle, authenticator auth.Authenticator, username string) (auth.Result, int) {
+	if o.useFirstPass || o.tryFirstPass {
+		if password := h.item(C.PAM_AUTHTOK); password != "" {
+			result := authenticator.Authenticate(ctx, username, password)
+			if result.Success || result.Err != nil || o.useFirstPass {
+				return result, resultCode(result)
+			}
+		} else if o.useFirstPass {
+			return auth.Failure(o.Backend.Name, username, "no password from an earlier module"), pamAuthErr
+		}
+	}
+
+	password, err := h.converse(promptEchoOff, "Password: ")
+	if err != nil {
+		logError("conversation failed: %v", err)
+		return auth.Failure(o.Backend.Name, username, err.Error()), pamConvErr
+	}
+	h.setAuthtok(password)
+	result := authenticator.Authenticate(ctx, username, password)
+	return result, resultCode(result)
+}
+
+// checkSecondFactor asks users enrolled in the TOTP secrets file for a code
+func (o *options) checkSecondFactor(h handle, username string) int {
+	if o.totp == nil || !o.totp.Enrolled(username) {
+		return pamSuccess
+	}
+	code, err := h.converse(promptEchoOn, "Verification code: ")
+	if err != nil {
+		logError("conversation failed: %v", err)
+		return pamConvErr
+	}
+	if !o.totp.Verify(username, code) {
+		return pamAuthErr
+	}
+	return pamSuccess
+}
+
+// resultCode maps a backend result to a PAM return code. An attempt that
+// timed out says nothing about the password.
+func resultCode(result auth.Result) int {
+	switch {
+	case result.Success:
+		return pamSuccess
+	case result.Err != nil:
+		return pamAuthInfoUnavail
+	}
+	return pamAuthErr
+}
+
+// accountManagement implements pam_sm_acct_mgmt for backends that hold
+// account details; other backends leave the decision to other modules
+func accountManagement(h handle, args []string) int {
+	opts, err := parseOptions(args)
+	if err != nil {
+		logError("%v", err)
+		return pamServiceErr
+	}
+	username := h.user()
+	if username == "" {
+		return pamUserUnknown
+	}
+
+	authenticator, err := backend.New(opts.Backend)
+	if err != nil {
+		logError("cannot use backend %s: %v", opts.Backend.Name, err)
+		return pamAuthInfoUnavail
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+	source, ok := authenticator.(auth.InfoSource)
+	if !ok {
+		return pamIgnore
+	}
+
+	info, err := source.UserInfo(username)
+	if errors.Is(err, auth.ErrUnknownUser) {
+		return pamUserUnknown
+	}
+	if errors.Is(err, backend.ErrNoAccountData) {
+		return pamIgnore
+	}
+	if err != nil {
+		logError("account lookup for %s failed: %v", username, err)
+		return pamAuthInfoUnavail
+	}
+	switch err := auth.CheckAccount(info, time.Now()); {
+	case errors.Is(err, auth.ErrAccountDisabled):
+		h.message("Your account has been disabled; please contact your system administrator.")
+		return pamPermDenied
+	case errors.Is(err, auth.ErrAccountExpired):
+		h.message("Your account has expired; please contact your system administrator.")
+		return pamAcctExpired
+	}
+	return pamSuccess
+}
+
+// main is required for -buildmode=c-shared but never runs
+func main() {}
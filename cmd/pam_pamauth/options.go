@@ -0,0 +1,114 @@
+//go:build linux && pam
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+
+	"github.com/bariiss/pam-auth/util/audit"
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/backend"
+	"github.com/bariiss/pam-auth/util/totp"
+)
+
+// options holds the parsed module arguments
+type options struct {
+	// Backend takes every pam-auth backend flag, e.g. backend=db db-file=...
+	Backend backend.Config
+
+	useFirstPass bool
+	tryFirstPass bool
+	debugLog     bool
+	totp         *totp.Store
+	auditPath    string
+}
+
+// parseOptions reads module arguments of the form name or name=value.
+// Backend options use the pam-auth flag names without the leading dashes.
+func parseOptions(args []string) (*options, error) {
+	opts := &options{Backend: backend.DefaultConfig()}
+	opts.Backend.Name = ""
+
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		switch strings.ReplaceAll(name, "_", "-") {
+		case "use-first-pass":
+			opts.useFirstPass = true
+		case "try-first-pass":
+			opts.tryFirstPass = true
+		case "debug":
+			opts.debugLog = true
+		case "audit-log":
+			opts.auditPath = value
+		case "totp-secrets":
+			store, err := totp.LoadStore(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load TOTP secrets: %w", err)
+			}
+			opts.totp = store
+		default:
+			if err := opts.Backend.Set(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Backend.Name == "" || opts.Backend.Name == "system" {
+		return nil, fmt.Errorf("a non-system backend= argument is required")
+	}
+	return opts, nil
+}
+
+// record writes the outcome of an authentication to the audit log, if configured
+func (o *options) record(h handle, result auth.Result) {
+	if o.auditPath == "" {
+		return
+	}
+	logger, err := audit.Open(o.auditPath)
+	if err != nil {
+		logError("cannot open audit log: %v", err)
+		return
+	}
+	defer logger.Close()
+
+	outcome := "failure"
+	if result.Success {
+		outcome = "success"
+	}
+	err = logger.Log(audit.Record{
+		Event:      audit.EventAuthentication,
+		Service:    "pam:" + h.service(),
+		Username:   result.Username,
+		Remote:     h.rhost(),
+		Outcome:    outcome,
+		Message:    result.Message,
+		Attributes: map[string]string{"backend": result.Backend},
+	})
+	if err != nil {
+		logError("cannot write audit record: %v", err)
+	}
+}
+
+// debug logs to syslog when the debug argument is given
+func (o *options) debug(format string, args ...any) {
+	if o.debugLog {
+		writeLog(syslog.LOG_DEBUG, format, args...)
+	}
+}
+
+// logError logs a module error to syslog
+func logError(format string, args ...any) {
+	writeLog(syslog.LOG_ERR, format, args...)
+}
+
+// writeLog sends one message to the authpriv syslog facility
+func writeLog(priority syslog.Priority, format string, args ...any) {
+	writer, err := syslog.New(syslog.LOG_AUTHPRIV|priority, "pam_pamauth")
+	if err != nil {
+		return
+	}
+	defer writer.Close()
+	fmt.Fprintf(writer, format, args...)
+}
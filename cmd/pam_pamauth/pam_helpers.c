@@ -0,0 +1,64 @@
+//go:build linux && pam
+
+#include <stdlib.h>
+#include <string.h>
+#include <security/pam_modules.h>
+
+// pamauth_converse sends a single message through the application's
+// conversation function. The answer, if any, must be released with
+// pamauth_free_secret.
+int pamauth_converse(pam_handle_t *pamh, int style, const char *text, char **answer)
+{
+	const struct pam_conv *conv = NULL;
+	struct pam_message msg;
+	const struct pam_message *msgp = &msg;
+	struct pam_response *resp = NULL;
+	int ret;
+
+	*answer = NULL;
+	ret = pam_get_item(pamh, PAM_CONV, (const void **)&conv);
+	if (ret != PAM_SUCCESS)
+		return ret;
+	if (conv == NULL || conv->conv == NULL)
+		return PAM_CONV_ERR;
+
+	msg.msg_style = style;
+	msg.msg = text;
+	ret = conv->conv(1, &msgp, &resp, conv->appdata_ptr);
+	if (ret != PAM_SUCCESS)
+		return ret;
+	if (resp != NULL) {
+		*answer = resp->resp;
+		free(resp);
+	}
+	return PAM_SUCCESS;
+}
+
+// pamauth_get_string_item returns a string item such as PAM_RHOST, or NULL
+const char *pamauth_get_string_item(pam_handle_t *pamh, int type)
+{
+	const void *item = NULL;
+
+	if (pam_get_item(pamh, type, &item) != PAM_SUCCESS)
+		return NULL;
+	return item;
+}
+
+// pamauth_get_user returns the target user, prompting for it if necessary
+const char *pamauth_get_user(pam_handle_t *pamh)
+{
+	const char *user = NULL;
+
+	if (pam_get_user(pamh, &user, NULL) != PAM_SUCCESS)
+		return NULL;
+	return user;
+}
+
+// pamauth_free_secret wipes and frees a string returned by the conversation
+void pamauth_free_secret(char *secret)
+{
+	if (secret == NULL)
+		return;
+	memset(secret, 0, strlen(secret));
+	free(secret);
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/nsscache"
+	"github.com/bariiss/pam-auth/util/policy"
+	"github.com/bariiss/pam-auth/util/userdb"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd groups the SQLite user database subcommands
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the SQLite user database",
+	Long: `Manage application users kept in an embedded SQLite database (see
+--db-file). These accounts are independent of system accounts and are used
+with --backend db.`,
+}
+
+// dbUserCmd groups the user management subcommands
+var dbUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage database users",
+}
+
+// dbUserAddCmd creates a user
+var dbUserAddCmd = &cobra.Command{
+	Use:   "add USER",
+	Short: "Add a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		pol, err := passwordPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		return runDBUserAdd(args[0], pol)
+	},
+}
+
+// dbUserPasswdCmd changes a user's password
+var dbUserPasswdCmd = &cobra.Command{
+	Use:   "passwd USER",
+	Short: "Change a user's password",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		pol, err := passwordPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		return runDBUserPasswd(args[0], pol)
+	},
+}
+
+// dbUserDisableCmd disables or re-enables a user
+var dbUserDisableCmd = &cobra.Command{
+	Use:   "disable USER",
+	Short: "Disable a user (or re-enable with --enable)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDBUserDisable(args[0])
+	},
+}
+
+// dbUserListCmd lists all users
+var dbUserListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDBUserList()
+	},
+}
+
+// dbUserShowCmd prints one user's account details
+var dbUserShowCmd = &cobra.Command{
+	Use:   "show USER",
+	Short: "Show a user's account details",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDBUserShow(args[0])
+	},
+}
+
+// dbNSSUpdateCmd writes the cache read by the NSS module
+var dbNSSUpdateCmd = &cobra.Command{
+	Use:   "nss-update",
+	Short: "Write the NSS cache read by libnss_pamauth",
+	Long: `Export users and groups to the cache directory read by libnss_pamauth.so.2
+so they resolve through getpwnam/getgrnam. Once the cache exists it is
+refreshed automatically by the db user commands.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openUserDB(false)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		return writeNSSCache(store)
+	},
+}
+
+// dbNSSCache is the cache directory exported for the NSS module
+var dbNSSCache string
+
+// db user subcommand flags
+var (
+	dbUserUID        int
+	dbUserGID        int
+	dbUserGecos      string
+	dbUserHome       string
+	dbUserShell      string
+	dbUserGroups     []string
+	dbUserAttributes []string
+	dbUserExpires    string
+	dbUserEnable     bool
+)
+
+func init() {
+	dbCmd.PersistentFlags().StringVar(&dbNSSCache, "nss-cache", nsscache.DefaultPath, "NSS cache directory kept up to date for libnss_pamauth")
+
+	dbUserAddCmd.Flags().IntVar(&dbUserUID, "uid", 0, "Numeric user ID (default next free ID from 100000)")
+	dbUserAddCmd.Flags().IntVar(&dbUserGID, "gid", 0, "Numeric primary group ID (default same as the UID)")
+	dbUserAddCmd.Flags().StringVar(&dbUserGecos, "name", "", "Full name")
+	dbUserAddCmd.Flags().StringVar(&dbUserHome, "home", "", "Home directory (default /home/USER)")
+	dbUserAddCmd.Flags().StringVar(&dbUserShell, "shell", "", "Login shell (default /bin/sh)")
+	dbUserAddCmd.Flags().StringSliceVar(&dbUserGroups, "group", nil, "Group membership (repeatable or comma separated)")
+	dbUserAddCmd.Flags().StringArrayVar(&dbUserAttributes, "attr", nil, "Attribute as KEY=VALUE (repeatable)")
+	dbUserAddCmd.Flags().StringVar(&dbUserExpires, "expires", "", "Account expiry date as YYYY-MM-DD")
+
+	addPolicyFlags(dbUserAddCmd, "none")
+	addPolicyFlags(dbUserPasswdCmd, "none")
+
+	dbUserDisableCmd.Flags().BoolVar(&dbUserEnable, "enable", false, "Re-enable the user instead")
+
+	dbUserCmd.AddCommand(dbUserAddCmd)
+	dbUserCmd.AddCommand(dbUserPasswdCmd)
+	dbUserCmd.AddCommand(dbUserDisableCmd)
+	dbUserCmd.AddCommand(dbUserListCmd)
+	dbUserCmd.AddCommand(dbUserShowCmd)
+	dbCmd.AddCommand(dbUserCmd)
+	dbCmd.AddCommand(dbNSSUpdateCmd)
+}
+
+// openUserDB opens --db-file, creating its directory and the database when create is set
+func openUserDB(create bool) (*userdb.Store, error) {
+	if _, err := os.Stat(backendConfig.DBFile); err != nil {
+		if !create || !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(backendConfig.DBFile), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return userdb.Open(backendConfig.DBFile)
+}
+
+// writeNSSCache exports store to the NSS cache directory
+func writeNSSCache(store *userdb.Store) error {
+	cache, err := store.NSSCache()
+	if err != nil {
+		return err
+	}
+	if err := cache.Save(dbNSSCache); err != nil {
+		return err
+	}
+	fmt.Printf("📇 NSS cache written to %s (%d users, %d groups)\n", dbNSSCache, len(cache.Users), len(cache.Groups))
+	return nil
+}
+
+// refreshNSSCache rewrites the NSS cache after a change, if one is in use
+func refreshNSSCache(store *userdb.Store) {
+	if _, err := os.Stat(dbNSSCache); err != nil {
+		return
+	}
+	if err := writeNSSCache(store); err != nil {
+		fmt.Printf("⚠️ Could not refresh NSS cache: %v\n", err)
+	}
+}
+
+// runDBUserAdd creates a user after prompting for a password that meets pol
+func runDBUserAdd(username string, pol policy.Policy) error {
+	u := userdb.User{
+		Name:       username,
+		UID:        dbUserUID,
+		GID:        dbUserGID,
+		Gecos:      dbUserGecos,
+		Home:       dbUserHome,
+		Shell:      dbUserShell,
+		Groups:     dbUserGroups,
+		Attributes: make(map[string]string),
+	}
+	for _, spec := range dbUserAttributes {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --attr %q: expected KEY=VALUE", spec)
+		}
+		u.Attributes[key] = value
+	}
+	if dbUserExpires != "" {
+		expires, err := time.ParseInLocation("2006-01-02", dbUserExpires, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --expires %q: expected YYYY-MM-DD", dbUserExpires)
+		}
+		u.Expires = expires
+	}
+
+	store, err := openUserDB(true)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	password, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	if err := enforcePolicy(pol, password, policy.Account{Username: username, Gecos: u.Gecos}); err != nil {
+		return err
+	}
+	if err := store.AddUser(u, password); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Added user: %s\n", username)
+	refreshNSSCache(store)
+	return nil
+}
+
+// runDBUserPasswd prompts for a new password for username and stores it
+// once it meets pol
+func runDBUserPasswd(username string, pol policy.Policy) error {
+	store, err := openUserDB(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	u, err := store.User(username)
+	if err != nil {
+		return err
+	}
+	password, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	account := policy.Account{Username: username, Gecos: u.Gecos}
+	if pol.History > 0 {
+		if account.History, err = store.PasswordHistory(username); err != nil {
+			return err
+		}
+	}
+	if err := enforcePolicy(pol, password, account); err != nil {
+		return err
+	}
+	if err := store.SetPassword(username, password); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Password changed for user: %s\n", username)
+	refreshNSSCache(store)
+	return nil
+}
+
+// runDBUserDisable disables username, or re-enables it with --enable
+func runDBUserDisable(username string) error {
+	store, err := openUserDB(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.SetDisabled(username, !dbUserEnable); err != nil {
+		return err
+	}
+	if dbUserEnable {
+		fmt.Printf("✅ Enabled user: %s\n", username)
+	} else {
+		fmt.Printf("🔒 Disabled user: %s\n", username)
+	}
+	refreshNSSCache(store)
+	return nil
+}
+
+// runDBUserList prints a table of all users
+func runDBUserList() error {
+	store, err := openUserDB(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	users, err := store.Users()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tUID\tGID\tSTATUS\tEXPIRES\tGROUPS")
+	for _, u := range users {
+		status := "active"
+		switch {
+		case u.Disabled:
+			status = "disabled"
+		case u.Expired(now):
+			status = "expired"
+		}
+		expires := "never"
+		if !u.Expires.IsZero() {
+			expires = u.Expires.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", u.Name, u.UID, u.GID, status, expires, strings.Join(u.Groups, ","))
+	}
+	return w.Flush()
+}
+
+// runDBUserShow prints username's account details
+func runDBUserShow(username string) error {
+	store, err := openUserDB(false)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	info, err := store.UserInfo(username)
+	if err != nil {
+		return err
+	}
+	showAccountInfo(info)
+	return nil
+}
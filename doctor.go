@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bariiss/pam-auth/util/backend"
+	"github.com/bariiss/pam-auth/util/doctor"
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd diagnoses the environment pam-auth runs in
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the authentication environment",
+	Long: `Check what this binary was built with and whether the system lets it
+authenticate: effective capabilities, /etc/shadow access, unix_chkpwd,
+nsswitch.conf sources, the PAM service file and SELinux/AppArmor
+confinement. Each problem comes with a suggested fix. Exits with status 1
+when a check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDoctor()
+	},
+}
+
+// doctor flags
+var (
+	doctorJSON    bool
+	doctorService string
+)
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the report as JSON")
+	doctorCmd.Flags().StringVar(&doctorService, "service", "login", "PAM service file to validate")
+}
+
+// doctorIcons marks each check status in the text report
+var doctorIcons = map[doctor.Status]string{
+	doctor.OK:   "✅",
+	doctor.Warn: "⚠️",
+	doctor.Fail: "❌",
+	doctor.Skip: "⏭️",
+}
+
+// runDoctor prints the diagnostics report
+func runDoctor() error {
+	backends := append([]string{"system"}, backend.Names...)
+	if pam.RealPAMCompiled {
+		backends = append(backends, "real-pam")
+	}
+	report := doctor.Run(doctor.Options{Service: doctorService, Backends: backends, Root: sysRoot()})
+
+	if doctorJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("🩺 pam-auth %s doctor (%s/%s, %s)\n", version, report.Build.OS, report.Build.Arch, report.Build.GoVersion)
+		fmt.Printf("Backends: %v\n\n", report.Build.Backends)
+		for _, check := range report.Checks {
+			fmt.Printf("%s %s: %s\n", doctorIcons[check.Status], check.Name, check.Detail)
+			if check.Fix != "" {
+				fmt.Printf("   💡 %s\n", check.Fix)
+			}
+		}
+	}
+
+	if report.Failed() {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/bariiss/pam-auth/util/runas"
+	"github.com/bariiss/pam-auth/util/tracing"
+	"github.com/bariiss/pam-auth/util/utmp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/term"
+)
+
+// execCmd runs a command as an authenticated user
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] [-- COMMAND [ARG...]]",
+	Short: "Run a command as another user after authenticating them",
+	Long: `Authenticate --user with the selected backend, like su, then run COMMAND
+as that user, or their login shell when no command is given.
+
+The command gets the user's supplementary groups (initgroups), group and
+user ID and a clean environment: HOME, SHELL, USER, LOGNAME, TERM and PATH
+from ENV_PATH, or ENV_SUPATH for root, in login.defs. --login starts the
+shell as a login shell in the home directory.
+
+The password is always asked, also when running as root. With the system
+backend on Linux it is checked against /etc/shadow unless --real-pam is
+given to a build with -tags pam, as the getent check only proves that the
+user exists. When standard
+input is not a terminal the password is its first line and the rest is
+left to the command.
+
+By default pam-auth replaces itself with the command, like doas. With
+--session (Linux builds with -tags pam) a PAM session is opened for the
+user on --service, whose modules may add variables such as MAIL, and with
+--utmp the session is recorded in utmp and wtmp; pam-auth then waits for
+the command, closes the session and exits with its status. Switching users
+requires root. A setuid pam-auth only accepts the system backend and the
+flags above, plus --real-pam, --timeout and --utmp.`,
+	Example: `  pam-auth exec --user alice -- id
+  pam-auth exec --user alice --login
+  pam-auth --real-pam exec --user alice --session --service su --utmp
+  pam-auth exec --backend db --user alice -- make install`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := checkSetuidExec(cmd.Flags()); err != nil {
+			return err
+		}
+		return runExec(args)
+	},
+}
+
+// exec flags
+var (
+	execUser    string
+	execLogin   bool
+	execShell   string
+	execSession bool
+	execService string
+)
+
+func init() {
+	execCmd.Flags().StringVarP(&execUser, "user", "u", "root", "User to authenticate and run the command as")
+	execCmd.Flags().BoolVarP(&execLogin, "login", "l", false, "Start a login shell in the user's home directory")
+	execCmd.Flags().StringVarP(&execShell, "shell", "s", "", "Shell to run instead of the user's login shell")
+	execCmd.Flags().BoolVar(&execSession, "session", false, "Open a PAM session for the user around the command")
+	execCmd.Flags().StringVar(&execService, "service", "login", "PAM service the session is opened on")
+}
+
+// setuidExecFlags are the flags a setuid exec accepts. The caller sets
+// every flag, so none may name a file, an endpoint or a backend that
+// vouches for host accounts: the system backend checks the shadow file.
+var setuidExecFlags = map[string]bool{
+	"user": true, "login": true, "shell": true, "session": true, "service": true,
+	"backend": true, "real-pam": true, "timeout": true, "utmp": true,
+}
+
+// checkSetuidExec refuses the flags a setuid pam-auth would otherwise
+// honour on behalf of the user who started it
+func checkSetuidExec(flags *pflag.FlagSet) error {
+	if os.Getuid() == os.Geteuid() {
+		return nil
+	}
+	if name := backendConfig.Name; name != "" && name != "system" {
+		return fmt.Errorf("setuid exec only uses the system backend, not %s", name)
+	}
+	var err error
+	flags.Visit(func(f *pflag.Flag) {
+		if err == nil && !setuidExecFlags[f.Name] {
+			err = fmt.Errorf("setuid exec does not accept --%s", f.Name)
+		}
+	})
+	return err
+}
+
+// exitNotFound is the exit status for a command that cannot be found, as
+// with env(1)
+const exitNotFound = 127
+
+// runExec authenticates execUser and runs args, or the shell, as them
+func runExec(args []string) error {
+	if !sysRoot().Host() {
+		return errors.New("exec cannot be combined with --root")
+	}
+	if execSession && !pam.RealPAMCompiled {
+		return pam.ErrNoSessions
+	}
+	target, err := runas.LookupTarget(execUser)
+	if err != nil {
+		return err
+	}
+	if os.Geteuid() != 0 && os.Geteuid() != target.UID {
+		return fmt.Errorf("exec must run as root to become %s", target.Username)
+	}
+
+	// Resolve the command before asking for the password
+	defs, err := sysRoot().LoginDefs()
+	if err != nil {
+		return err
+	}
+	env := runas.Environment(target, defs, nil)
+	shell := target.Shell
+	if execShell != "" {
+		shell = execShell
+	}
+	name, argv := shell, []string{filepath.Base(shell)}
+	if len(args) > 0 {
+		name, argv = args[0], args
+	} else if execLogin {
+		argv[0] = "-" + argv[0]
+	}
+	path, err := runas.LookPath(name, envValue(env, "PATH"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitNotFound)
+	}
+	dir := ""
+	if execLogin {
+		dir = target.Home
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "⚠️ Cannot change to %s, starting in /\n", dir)
+			dir = "/"
+		}
+	}
+
+	if !authenticateExec(target.Username) {
+		os.Exit(1)
+	}
+
+	line := terminalLine()
+	if !execSession && !recordUtmp {
+		if err := runas.Exec(target, path, argv, env, dir); err != nil {
+			return fmt.Errorf("cannot run %s as %s: %w", path, target.Username, err)
+		}
+		return nil
+	}
+
+	var session *pam.Session
+	if execSession {
+		tty := ""
+		if !utmp.NoTTY(line) {
+			tty = line
+		}
+		session, err = pam.OpenSession(execService, target.Username, tty)
+		if err != nil {
+			return err
+		}
+		env = runas.Environment(target, defs, session.Env())
+	}
+	closeSession := openUtmpSession(target.Username, line, "", os.Getpid())
+
+	code, err := runExecChild(target, path, argv, env, dir)
+	if closeSession != nil {
+		closeSession()
+	}
+	if session != nil {
+		if err := session.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ %v\n", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(code)
+	return nil
+}
+
+// authenticateExec asks for username's password and verifies it with the
+// selected backend. Progress messages go to standard error, leaving
+// standard output to the command.
+func authenticateExec(username string) bool {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	authenticator, err := newServerAuthenticator()
+	if err != nil {
+		fmt.Printf("❌ Error configuring backend: %v\n", err)
+		return false
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	fmt.Printf("Password for %s: ", username)
+	password, err := readExecPassword()
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("❌ Error reading password: %v\n", err)
+		return false
+	}
+
+	ctx, cancel := authContext()
+	defer cancel()
+	ctx, span := tracing.Tracer().Start(ctx, "exec", trace.WithAttributes(semconv.EnduserID(username)))
+	result := tracing.Authenticate(ctx, "backend", authenticator, username, password)
+	recordLogin(ctx, result)
+	tracing.RecordResult(span, result)
+	span.End()
+	flushTraces()
+	if !result.Success {
+		if result.Err == nil {
+			recordUtmpFailure(username, terminalLine(), "", os.Getpid())
+		}
+		fmt.Printf("❌ Authentication failed for user: %s\n", username)
+		if errors.Is(result.Err, auth.ErrTimeout) {
+			fmt.Printf("⏰ Authentication timed out after %s\n", backendConfig.Timeout)
+		} else if result.Message != "" && (result.Backend != "system" || result.Locked) {
+			fmt.Printf("💡 %s\n", result.Message)
+		}
+		return false
+	}
+	return true
+}
+
+// readExecPassword reads the password from the terminal, or the first line
+// of standard input one byte at a time so the rest reaches the command
+func readExecPassword() (string, error) {
+	if term.IsTerminal(int(syscall.Stdin)) {
+		return readPassword()
+	}
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+// runExecChild runs the command as target and returns its exit status, or
+// 128 plus the signal that ended it as shells report it. Interrupts from
+// the terminal reach the command, which decides whether to stop, while
+// pam-auth waits to close the session.
+func runExecChild(target runas.Target, path string, argv, env []string, dir string) (int, error) {
+	cmd, err := runas.Command(target, path, argv, env, dir)
+	if err != nil {
+		return 0, err
+	}
+	signal.Ignore(os.Interrupt, syscall.SIGQUIT)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("cannot run %s as %s: %w", path, target.Username, err)
+	}
+
+	// Pass termination requests on to the command
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+// envValue returns the value of name in env
+func envValue(env []string, name string) string {
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/sysroot"
+	"github.com/bariiss/pam-auth/util/utmp"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// historyCmd lists logins and failed logins from wtmp and btmp
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the login history from wtmp and btmp",
+	Long: `List the sessions recorded in wtmp, like last(1), and the failed logins
+recorded in btmp, like lastb(1), newest first. The files are read under
+--root; btmp is usually readable by root only.
+
+With --user the last successful login is also looked up in lastlog and the
+lastlog2 database of pam_lastlog2, and the failed attempts since then are
+counted.`,
+	Example: `  pam-auth history --user alice
+  pam-auth history --failed --limit 50
+  pam-auth --root ./image history --user alice --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runHistory()
+	},
+}
+
+// history flags
+var (
+	historyUser   string
+	historyLimit  int
+	historyFailed bool
+	historyJSON   bool
+)
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyUser, "user", "u", "", "Only show this user (default all users)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Entries shown per list (0 for all)")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "Only show failed logins")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print the history as JSON")
+}
+
+// loginHistory is the output of the history command
+type loginHistory struct {
+	Summary  *utmp.Summary  `json:"summary,omitempty"`
+	Sessions []utmp.Session `json:"sessions"`
+	Failures []utmp.Record  `json:"failures"`
+}
+
+// runHistory prints the sessions and failed logins of historyUser
+func runHistory() error {
+	root := sysRoot()
+	var history loginHistory
+
+	if !historyFailed {
+		records, err := utmp.ReadFile(root.Path(sysroot.WtmpFile))
+		if err != nil {
+			return err
+		}
+		for _, s := range utmp.Sessions(records) {
+			if historyUser == "" || s.User == historyUser {
+				history.Sessions = append(history.Sessions, s)
+			}
+		}
+		history.Sessions = limit(history.Sessions, historyLimit)
+	}
+
+	records, err := utmp.ReadFile(root.Path(sysroot.BtmpFile))
+	if err != nil && (historyFailed || !errors.Is(err, fs.ErrNotExist)) {
+		return err
+	}
+	history.Failures = limit(utmp.Failures(records, historyUser), historyLimit)
+
+	if historyUser != "" {
+		summary := utmp.Summarize(root, historyUser, userUID(historyUser))
+		history.Summary = &summary
+	}
+
+	if historyJSON {
+		if history.Sessions == nil {
+			history.Sessions = []utmp.Session{}
+		}
+		if history.Failures == nil {
+			history.Failures = []utmp.Record{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(history)
+	}
+
+	if history.Summary != nil {
+		fmt.Printf("Login history of %s:\n", historyUser)
+		printLoginSummary(*history.Summary)
+		fmt.Println()
+	}
+	if !historyFailed {
+		if len(history.Sessions) == 0 {
+			fmt.Println("No sessions recorded in wtmp")
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "USER\tLINE\tFROM\tLOGIN\tLOGOUT\tDURATION")
+			for _, s := range history.Sessions {
+				logout, duration := s.End, ""
+				if !s.Logout.IsZero() {
+					duration = s.Logout.Sub(s.Login).Round(time.Second).String()
+					if s.End == utmp.EndLogout {
+						logout = s.Logout.Format("2006-01-02 15:04:05")
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.User, s.Line, s.Host,
+					s.Login.Format("2006-01-02 15:04:05"), logout, duration)
+			}
+			w.Flush()
+		}
+		fmt.Println()
+	}
+	if len(history.Failures) == 0 {
+		fmt.Println("No failed logins recorded in btmp")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tLINE\tFROM\tFAILED AT")
+	for _, r := range history.Failures {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.User, r.Line, r.Source(), r.Time.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// limit returns the first n items of list, or all of them when n is 0
+func limit[T any](list []T, n int) []T {
+	if n > 0 && len(list) > n {
+		return list[:n]
+	}
+	return list
+}
+
+// userUID returns the UID of username, or -1 when it is unknown
+func userUID(username string) int {
+	u, err := sysRoot().LookupUser(username)
+	if err != nil {
+		return -1
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return -1
+	}
+	return uid
+}
+
+// printLoginSummary prints the last login and the failed attempts since
+func printLoginSummary(summary utmp.Summary) {
+	if summary.Last == nil {
+		fmt.Println("Last Login: never")
+	} else {
+		fmt.Printf("Last Login: %s\n", describeLogin(summary.Last.Time, summary.Last.Line, summary.Last.Host))
+	}
+	switch {
+	case !summary.FailuresKnown:
+		fmt.Println("Failed Logins Since: unknown (btmp is not readable)")
+	case len(summary.Failures) == 0:
+		fmt.Println("Failed Logins Since: 0")
+	default:
+		newest := summary.Failures[0]
+		fmt.Printf("Failed Logins Since: %d (last %s)\n", len(summary.Failures),
+			describeLogin(newest.Time, newest.Line, newest.Source()))
+	}
+}
+
+// describeLogin formats a login time with its source host and terminal
+func describeLogin(at time.Time, line, host string) string {
+	s := at.Format("2006-01-02 15:04:05")
+	if host != "" {
+		s += " from " + host
+	}
+	if line != "" {
+		s += " on " + line
+	}
+	return s
+}
+
+// terminalLine returns the terminal on standard input, or "pam-auth:notty"
+// when there is none
+func terminalLine() string {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		if tty, err := os.Readlink("/proc/self/fd/0"); err == nil && strings.HasPrefix(tty, "/dev/") {
+			return tty
+		}
+	}
+	return "pam-auth:notty"
+}
+
+// openUtmpSession records a login in utmp and wtmp when --utmp is set and
+// returns the function recording its logout, or nil
+func openUtmpSession(username, line, host string, pid int) func() {
+	if !recordUtmp {
+		return nil
+	}
+	login := utmp.NewRecord(utmp.UserProcess, pid, line, username, host)
+	if err := utmp.RecordLogin(sysRoot(), login); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ Cannot record login: %v\n", err)
+		return nil
+	}
+	return func() {
+		if err := utmp.RecordLogout(sysRoot(), login); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ Cannot record logout: %v\n", err)
+		}
+	}
+}
+
+// recordUtmpFailure records a failed login in btmp when --utmp is set
+func recordUtmpFailure(username, line, host string, pid int) {
+	if !recordUtmp {
+		return
+	}
+	failure := utmp.NewRecord(utmp.LoginProcess, pid, line, username, host)
+	if err := utmp.RecordFailure(sysRoot(), failure); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ Cannot record failed login: %v\n", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bariiss/pam-auth/util/htpasswd"
+	"github.com/bariiss/pam-auth/util/policy"
+	"github.com/spf13/cobra"
+)
+
+// htpasswdCmd groups the htpasswd file management subcommands
+var htpasswdCmd = &cobra.Command{
+	Use:   "htpasswd",
+	Short: "Manage Apache htpasswd files",
+	Long: `Add, delete and verify users in Apache htpasswd files. New passwords
+are always stored as bcrypt hashes.`,
+}
+
+// htpasswdAddCmd adds or updates a user
+var htpasswdAddCmd = &cobra.Command{
+	Use:   "add FILE USER",
+	Short: "Add a user or change their password",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		pol, err := passwordPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		return runHtpasswdAdd(args[0], args[1], pol)
+	},
+}
+
+// htpasswdDeleteCmd removes a user
+var htpasswdDeleteCmd = &cobra.Command{
+	Use:   "delete FILE USER",
+	Short: "Delete a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHtpasswdDelete(args[0], args[1])
+	},
+}
+
+// htpasswdVerifyCmd checks a user's password
+var htpasswdVerifyCmd = &cobra.Command{
+	Use:   "verify FILE USER",
+	Short: "Verify a user's password",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHtpasswdVerify(args[0], args[1])
+	},
+}
+
+// htpasswd subcommand flags
+var (
+	htpasswdCreate bool
+	htpasswdCost   int
+)
+
+func init() {
+	htpasswdAddCmd.Flags().BoolVarP(&htpasswdCreate, "create", "c", false, "Create the file if it does not exist")
+	htpasswdAddCmd.Flags().IntVar(&htpasswdCost, "cost", 0, "bcrypt cost (default 10)")
+	addPolicyFlags(htpasswdAddCmd, "none")
+
+	htpasswdCmd.AddCommand(htpasswdAddCmd)
+	htpasswdCmd.AddCommand(htpasswdDeleteCmd)
+	htpasswdCmd.AddCommand(htpasswdVerifyCmd)
+}
+
+// runHtpasswdAdd prompts for a new password and stores it for username
+// once it meets pol
+func runHtpasswdAdd(path, username string, pol policy.Policy) error {
+	file, err := htpasswd.Open(path, htpasswdCreate)
+	if err != nil {
+		return err
+	}
+	file.BcryptCost = htpasswdCost
+
+	password, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	// The file keeps no history, only the current password
+	account := policy.Account{Username: username}
+	if hash, err := file.Hash(username); err == nil {
+		account.History = []string{hash}
+	}
+	if err := enforcePolicy(pol, password, account); err != nil {
+		return err
+	}
+	if err := file.Set(username, password); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Password stored for user: %s\n", username)
+	return nil
+}
+
+// runHtpasswdDelete removes username from the file
+func runHtpasswdDelete(path, username string) error {
+	file, err := htpasswd.Open(path, false)
+	if err != nil {
+		return err
+	}
+	if err := file.Delete(username); err != nil {
+		return err
+	}
+	fmt.Printf("🗑️ Deleted user: %s\n", username)
+	return nil
+}
+
+// runHtpasswdVerify prompts for username's password and checks it
+func runHtpasswdVerify(path, username string) error {
+	file, err := htpasswd.Open(path, false)
+	if err != nil {
+		return err
+	}
+	file.Plaintext = backendConfig.HtpasswdPlaintext
+
+	password, err := promptSecret("Password: ")
+	if err != nil {
+		return err
+	}
+	match, scheme, err := file.Verify(username, password)
+	if errors.Is(err, htpasswd.ErrNoSuchUser) {
+		fmt.Printf("❌ User not found: %s\n", username)
+		os.Exit(1)
+	}
+	if err != nil {
+		return err
+	}
+	if !match {
+		fmt.Printf("❌ Password incorrect for user: %s (%s)\n", username, scheme)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Password correct for user: %s (%s)\n", username, scheme)
+	return nil
+}
+
+// promptNewPassword asks for a new password twice and checks that both match
+func promptNewPassword() (string, error) {
+	password, err := promptSecret("New password: ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := promptSecret("Re-type new password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", errors.New("passwords do not match")
+	}
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
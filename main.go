@@ -2,19 +2,27 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
-	"os/user"
 	"runtime"
+	"sort"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/bariiss/pam-auth/util/audit"
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/crypt"
 	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/bariiss/pam-auth/util/sysroot"
+	"github.com/bariiss/pam-auth/util/tracing"
+	"github.com/bariiss/pam-auth/util/utmp"
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // version holds the application version
@@ -50,20 +58,83 @@ var (
 	strictBiometric bool
 )
 
+// auditLogPath is the optional JSON lines audit log shared by all subcommands
+var auditLogPath string
+
+// rootDir is the --root directory system files are read from
+var rootDir string
+
+// recordUtmp writes logins to utmp and wtmp and failed logins to btmp
+var recordUtmp bool
+
+// verifyShadow makes the system backend check passwords against the shadow
+// file on the host too, where it otherwise only checks that the user exists
+var verifyShadow bool
+
+// Sizing of the PAM transaction pool used with --real-pam
+var (
+	pamWorkers int
+	pamQueue   int
+)
+
 func init() {
 	// Add biometric flag to root command
 	rootCmd.Flags().BoolVarP(&useBiometric, "biometric", "b", false, "Use biometric authentication (TouchID/FaceID) on macOS")
-	// Add real PAM authentication flag for Linux
-	rootCmd.Flags().BoolVar(&useRealPAM, "real-pam", false, "Use real PAM authentication (Linux only) - requires system permissions")
+	// Add real PAM authentication flag for Linux (shared with the server subcommands)
+	rootCmd.PersistentFlags().BoolVar(&useRealPAM, "real-pam", false, "Use real PAM authentication (Linux only) - requires system permissions")
+	rootCmd.PersistentFlags().IntVar(&pamWorkers, "pam-workers", pam.DefaultPoolWorkers, "OS threads running concurrent --real-pam transactions")
+	rootCmd.PersistentFlags().IntVar(&pamQueue, "pam-queue", pam.DefaultPoolQueue, "Requests that may wait for a PAM worker before new ones are refused")
 	// Add cache clearing flag for biometric authentication
 	rootCmd.Flags().BoolVar(&clearBioCache, "clear-cache", false, "Clear biometric authentication cache before authenticating")
 	// Add strict biometric flag - no password fallback
 	rootCmd.Flags().BoolVar(&strictBiometric, "strict-biometric", false, "Require biometric authentication only (no password fallback)")
+	// Add audit log flag shared by the interactive flow and the servers
+	rootCmd.PersistentFlags().StringVar(&auditLogPath, "audit-log", "", "Append JSON audit records to this file")
+	// Add alternate root flag for inspecting mounted images
+	rootCmd.PersistentFlags().StringVar(&rootDir, "root", "", "Read passwd, group, shadow, pam.d, login.defs and login records under this directory instead of /, bypassing NSS")
+	// Add login record flag for session front ends
+	rootCmd.PersistentFlags().BoolVar(&recordUtmp, "utmp", false, "Record sessions in utmp and wtmp and failed logins in btmp, for who, last and lastb")
+}
+
+// sysRoot returns the --root directory system files are resolved under
+func sysRoot() sysroot.Root {
+	return sysroot.Root(rootDir)
+}
+
+// checkRoot rejects --root with options that can only act on the host
+func checkRoot() error {
+	if sysRoot().Host() {
+		return nil
+	}
+	if info, err := os.Stat(rootDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("--root %s is not a directory", rootDir)
+	}
+	if useRealPAM {
+		return fmt.Errorf("--real-pam cannot be used with --root: libpam always reads the host's /etc/pam.d")
+	}
+	if backendConfig.KRB5CCache != "" {
+		return fmt.Errorf("--krb5-ccache cannot be used with --root: the cache would belong to a host account")
+	}
+	return nil
 }
 
 func main() {
 	// Add version command to root command
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(radiusServeCmd)
+	rootCmd.AddCommand(tacacsServeCmd)
+	rootCmd.AddCommand(htpasswdCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(pamExecCmd)
+	rootCmd.AddCommand(offlineCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(pamCmd)
+	rootCmd.AddCommand(accountsCmd)
+	rootCmd.AddCommand(policyCmd)
+	rootCmd.AddCommand(hashCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(execCmd)
 
 	// Execute the root command
 	if err := rootCmd.Execute(); err != nil {
@@ -73,9 +144,11 @@ func main() {
 }
 
 // authenticateUser performs system user validation
-func authenticateUser(username, password string) bool {
+func authenticateUser(ctx context.Context, username, password string) bool {
 	// First check if the user exists in the system
-	user, err := user.Lookup(username)
+	_, span := tracing.Tracer().Start(ctx, "user lookup")
+	user, err := sysRoot().LookupUser(username)
+	span.End()
 	if err != nil {
 		fmt.Printf("User not found: %v\n", err)
 		return false
@@ -84,13 +157,27 @@ func authenticateUser(username, password string) bool {
 	fmt.Printf("User found: %s (UID: %s, GID: %s)\n", user.Username, user.Uid, user.Gid)
 
 	// Platform-specific authentication
-	switch runtime.GOOS {
-	case "darwin":
+	switch {
+	case !sysRoot().Host() || verifyShadow:
+		// Accounts of an alternate root, and server and exec logins without
+		// libpam, are checked against the shadow file
+		ctx, span := tracing.Tracer().Start(ctx, "system authentication", trace.WithAttributes(tracing.AttrMethod.String("files")))
+		defer span.End()
+		return authenticateFiles(ctx, username, password)
+	case runtime.GOOS == "darwin":
 		// Use dscl for macOS user information verification
-		return pam.AuthenticateWithDSCL(username, password)
-	case "linux":
+		ctx, span := tracing.Tracer().Start(ctx, "system authentication", trace.WithAttributes(tracing.AttrMethod.String("dscl")))
+		defer span.End()
+		return pam.AuthenticateWithDSCL(ctx, username, password)
+	case runtime.GOOS == "linux":
 		// Use passwd/shadow file check for Linux
-		return authenticateLinux(username, password)
+		method := "getent"
+		if useRealPAM {
+			method = "pam"
+		}
+		ctx, span := tracing.Tracer().Start(ctx, "system authentication", trace.WithAttributes(tracing.AttrMethod.String(method)))
+		defer span.End()
+		return authenticateLinux(ctx, username, password)
 	}
 
 	// Fallback authentication for other platforms
@@ -99,16 +186,16 @@ func authenticateUser(username, password string) bool {
 }
 
 // authenticateLinux performs simple authentication for Linux systems
-func authenticateLinux(username, password string) bool {
+func authenticateLinux(ctx context.Context, username, password string) bool {
 	fmt.Printf("Attempting Linux authentication for user: %s\n", username)
 
 	// If real PAM authentication is requested, use actual PAM
 	if useRealPAM && runtime.GOOS == "linux" {
-		return pam.AuthenticateWithRealPAM(username, password)
+		return pam.AuthenticateWithRealPAM(ctx, username, password)
 	}
 
 	// Check user information from /etc/passwd file
-	cmd := exec.Command("getent", "passwd", username)
+	cmd := exec.CommandContext(ctx, "getent", "passwd", username)
 	output, err := cmd.Output()
 	if err != nil {
 		fmt.Printf("getent command failed: %v\n", err)
@@ -128,13 +215,102 @@ func authenticateLinux(username, password string) bool {
 	return false
 }
 
+// authenticateFiles verifies password against the shadow file under --root
+func authenticateFiles(ctx context.Context, username, password string) bool {
+	fmt.Printf("Checking %s for user: %s\n", sysRoot().Path(sysroot.ShadowFile), username)
+
+	entry, err := sysRoot().LookupShadow(username)
+	if err != nil {
+		fmt.Printf("Shadow lookup failed: %v\n", err)
+		return false
+	}
+	switch {
+	case entry.Passwordless():
+		fmt.Println("Account has no password")
+		return false
+	case entry.Locked():
+		fmt.Println("Account is locked")
+		return false
+	}
+
+	ok, err := crypt.Verify(entry.Password, password)
+	if err != nil {
+		fmt.Printf("Cannot verify %s password hash: %v\n", crypt.Scheme(entry.Password), err)
+		return false
+	}
+	if ok {
+		fmt.Println("Authentication completed")
+	}
+	return ok
+}
+
+// shadowAging evaluates username's shadow entry; ok is false when there is
+// none or the shadow file is not readable
+func shadowAging(username string) (aging sysroot.Aging, ok bool) {
+	entry, err := sysRoot().LookupShadow(username)
+	if err != nil {
+		return sysroot.Aging{}, false
+	}
+	return entry.Aging(time.Now()), true
+}
+
+// systemAuthenticator exposes authenticateUser to the server subcommands
+type systemAuthenticator struct{}
+
+// Authenticate implements auth.Authenticator using the platform authentication
+func (systemAuthenticator) Authenticate(ctx context.Context, username, password string) auth.Result {
+	if !authenticateUser(ctx, username, password) {
+		if ctx.Err() != nil {
+			return auth.Aborted(ctx, "system", username)
+		}
+		return auth.Failure("system", username, "invalid credentials")
+	}
+	// Real PAM runs pam_unix's account checks; without it the shadow
+	// aging fields are enforced here
+	if !useRealPAM {
+		if aging, ok := shadowAging(username); ok && aging.Refused() {
+			result := auth.Failure("system", username, aging.Message())
+			result.Locked = true
+			return result
+		}
+	}
+	return auth.Result{Username: username, Backend: "system", Success: true, Groups: lookupUserGroups(username)}
+}
+
+// Groups implements auth.GroupSource
+func (systemAuthenticator) Groups(username string) []string {
+	return lookupUserGroups(username)
+}
+
+// lookupUserGroups returns the names of the groups username belongs to
+func lookupUserGroups(username string) []string {
+	u, err := sysRoot().LookupUser(username)
+	if err != nil {
+		return nil
+	}
+	groups, _ := sysRoot().GroupNames(u)
+	return groups
+}
+
+// openAuditLog opens the --audit-log file; a nil logger is returned when auditing is disabled
+func openAuditLog() (*audit.Logger, error) {
+	if auditLogPath == "" {
+		return nil, nil
+	}
+	logger, err := audit.Open(auditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return logger, nil
+}
+
 // showUserInfo displays user information
 func showUserInfo(username string) {
 	fmt.Println("\nUser Information:")
 	fmt.Println("=================")
 
 	// Get system user information
-	user, err := user.Lookup(username)
+	user, err := sysRoot().LookupUser(username)
 	if err != nil {
 		fmt.Printf("Error getting user info: %v\n", err)
 		return
@@ -145,16 +321,114 @@ func showUserInfo(username string) {
 	fmt.Printf("Group ID: %s\n", user.Gid)
 	fmt.Printf("Home Directory: %s\n", user.HomeDir)
 	fmt.Printf("Name: %s\n", user.Name)
+	showPasswordAging(username)
 	fmt.Printf("Authentication Time: %s\n", getCurrentTime())
+	printLoginSummary(utmp.Summarize(sysRoot(), username, userUID(username)))
 	fmt.Printf("Operating System: %s\n", runtime.GOOS)
 	fmt.Printf("Architecture: %s\n", runtime.GOARCH)
 
 	// Show user groups
-	if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
+	if !sysRoot().Host() {
+		fmt.Printf("\nGroups for user %s:\n", username)
+		fmt.Printf("Groups: %s\n", strings.Join(lookupUserGroups(username), " "))
+	} else if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
 		showUserGroups(username)
 	}
 }
 
+// showPasswordAging displays the shadow aging fields when the shadow file is readable
+func showPasswordAging(username string) {
+	entry, err := sysRoot().LookupShadow(username)
+	if err != nil {
+		return
+	}
+	aging := entry.Aging(time.Now())
+	day := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format("2006-01-02")
+	}
+
+	switch {
+	case entry.LastChange == 0:
+		fmt.Println("Password Last Changed: must be changed at next login")
+	case entry.LastChange < 0:
+		fmt.Println("Password Last Changed: unknown")
+	default:
+		fmt.Printf("Password Last Changed: %s\n", day(aging.LastChange))
+	}
+	fmt.Printf("Password Expires: %s\n", day(aging.PasswordExpires))
+	if entry.Min > 0 {
+		fmt.Printf("Minimum Password Age: %d days\n", entry.Min)
+	}
+	if entry.Inactive >= 0 {
+		fmt.Printf("Password Inactive Period: %d days\n", entry.Inactive)
+	}
+	fmt.Printf("Account Expires: %s\n", day(aging.AccountExpires))
+	fmt.Printf("Password Status: %s\n", aging.Message())
+}
+
+// showResultInfo displays user information for a successful authentication result
+func showResultInfo(authenticator auth.Authenticator, result auth.Result) {
+	if result.Backend == "system" {
+		showUserInfo(result.Username)
+		return
+	}
+	if source, ok := authenticator.(auth.InfoSource); ok {
+		if info, err := source.UserInfo(result.Username); err == nil {
+			showAccountInfo(info)
+			fmt.Printf("Authentication Time: %s\n", getCurrentTime())
+			return
+		}
+	}
+
+	fmt.Println("\nUser Information:")
+	fmt.Println("=================")
+	fmt.Printf("Username: %s\n", result.Username)
+	fmt.Printf("Backend: %s\n", result.Backend)
+	fmt.Printf("Authentication Time: %s\n", getCurrentTime())
+	if len(result.Groups) > 0 {
+		fmt.Printf("\nGroups for user %s:\n", result.Username)
+		fmt.Printf("Groups: %s\n", strings.Join(result.Groups, " "))
+	}
+}
+
+// showAccountInfo displays backend-held account details in the same layout as showUserInfo
+func showAccountInfo(info auth.UserInfo) {
+	fmt.Println("\nUser Information:")
+	fmt.Println("=================")
+	fmt.Printf("Username: %s\n", info.Username)
+	fmt.Printf("User ID: %s\n", info.UID)
+	fmt.Printf("Group ID: %s\n", info.GID)
+	fmt.Printf("Home Directory: %s\n", info.HomeDir)
+	fmt.Printf("Name: %s\n", info.Name)
+	fmt.Printf("Shell: %s\n", info.Shell)
+	if info.Expires.IsZero() {
+		fmt.Println("Account Expires: never")
+	} else {
+		fmt.Printf("Account Expires: %s\n", info.Expires.Format("2006-01-02"))
+	}
+	if info.Disabled {
+		fmt.Println("Status: disabled")
+	}
+
+	if len(info.Attributes) > 0 {
+		keys := make([]string, 0, len(info.Attributes))
+		for key := range info.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("\nAttributes:")
+		for _, key := range keys {
+			fmt.Printf("  %s: %s\n", key, info.Attributes[key])
+		}
+	}
+
+	fmt.Printf("\nGroups for user %s:\n", info.Username)
+	fmt.Printf("Groups: %s\n", strings.Join(info.Groups, " "))
+}
+
 // showUserGroups displays the groups that the user belongs to
 func showUserGroups(username string) {
 	fmt.Printf("\nGroups for user %s:\n", username)
@@ -170,6 +444,69 @@ func showUserGroups(username string) {
 	fmt.Printf("Groups: %s\n", groups)
 }
 
+// startPAMPool sizes the PAM transaction pool for the server subcommands
+func startPAMPool() {
+	if !useRealPAM {
+		return
+	}
+	if !pam.CheckPAMPermissions() {
+		fmt.Println("⚠️ Insufficient permissions for real PAM - consider running with sudo")
+	}
+	pam.ConfigurePAMPool(pamWorkers, pamQueue)
+	fmt.Printf("🧵 PAM pool: %d workers, queue of %d\n", pamWorkers, pamQueue)
+}
+
+// showPAMPoolStats prints the PAM pool counters when a server stops
+func showPAMPoolStats() {
+	if !useRealPAM {
+		return
+	}
+	stats := pam.PAMPoolStats()
+	fmt.Printf("🧵 PAM pool: %d accepted, %d failed, %d refused (queue full), %d abandoned\n",
+		stats.Completed, stats.Failed, stats.Rejected, stats.Abandoned)
+}
+
+// recordLogin writes the outcome of an interactive login to the audit log
+func recordLogin(ctx context.Context, result auth.Result) {
+	_, span := tracing.Tracer().Start(ctx, "audit")
+	defer span.End()
+	auditLog, err := openAuditLog()
+	if err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+		return
+	}
+	defer auditLog.Close()
+
+	outcome := "failure"
+	if result.Success {
+		outcome = "success"
+	}
+	var attributes map[string]string
+	if result.Offline {
+		attributes = map[string]string{"offline": "true"}
+	}
+	auditLog.Log(audit.Record{
+		Event:      audit.EventAuthentication,
+		Service:    "login:" + result.Backend,
+		Username:   result.Username,
+		Outcome:    outcome,
+		Message:    result.Message,
+		Attributes: attributes,
+	})
+}
+
+// stdinReader buffers standard input for line based prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readLine reads a single line from standard input without the trailing newline
+func readLine() (string, error) {
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
 // showVersion displays version information
 func showVersion() {
 	fmt.Printf("System Authentication Tool v%s\n", version)
@@ -224,14 +561,18 @@ func runAuthentication() {
 		}
 	}
 
+	// Resolve the authentication backend before prompting
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		log.Fatal("Error configuring backend: ", err)
+	}
+
 	// Get username
-	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("Username: ")
-	username, err := reader.ReadString('\n')
+	username, err := readLine()
 	if err != nil {
 		log.Fatal("Error reading username:", err)
 	}
-	username = strings.TrimSpace(username)
 
 	var password string
 
@@ -249,7 +590,12 @@ func runAuthentication() {
 			fmt.Printf("🔐 Attempting biometric authentication for user: %s\n", username)
 		}
 
-		if pam.AuthenticateWithBiometrics(username) {
+		ctx, cancel := authContext()
+		ctx, span := tracing.Tracer().Start(ctx, "login", trace.WithAttributes(semconv.EnduserID(username), tracing.AttrMethod.String("biometric")))
+		ok := pam.AuthenticateWithBiometrics(ctx, username)
+		span.End()
+		cancel()
+		if ok {
 			fmt.Printf("✅ Biometric authentication successful for user: %s\n", username)
 			showUserInfo(username)
 			return
@@ -260,6 +606,7 @@ func runAuthentication() {
 			fmt.Printf("❌ Biometric authentication failed for user: %s\n", username)
 			fmt.Println("🔒 STRICT BIOMETRIC MODE: Password authentication is disabled")
 			fmt.Println("💡 Please ensure TouchID/FaceID is working and try again")
+			flushTraces()
 			os.Exit(1)
 		} else {
 			fmt.Println("⚠️ Biometric authentication failed, falling back to password...")
@@ -275,19 +622,48 @@ func runAuthentication() {
 
 	// Get password securely
 	fmt.Print("Password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	password, err = readPassword()
 	if err != nil {
 		log.Fatal("Error reading password:", err)
 	}
-	password = string(passwordBytes)
 	fmt.Println() // Add new line
 
-	// Perform system authentication
-	if authenticateUser(username, password) {
+	// Perform authentication with the selected backend
+	ctx, cancel := authContext()
+	ctx, span := tracing.Tracer().Start(ctx, "login", trace.WithAttributes(semconv.EnduserID(username)))
+	result := tracing.Authenticate(ctx, "backend", authenticator, username, password)
+	cancel()
+	recordLogin(ctx, result)
+	if !result.Success && result.Err == nil {
+		recordUtmpFailure(username, terminalLine(), "", os.Getpid())
+	}
+	tracing.RecordResult(span, result)
+	span.End()
+	flushTraces()
+	if result.Success {
 		fmt.Printf("✅ Password authentication successful for user: %s\n", username)
-		showUserInfo(username)
+		if result.Backend == "system" && !useRealPAM {
+			if aging, ok := shadowAging(username); ok && aging.State == sysroot.AgingWarn {
+				fmt.Printf("⚠️ Your %s\n", aging.Message())
+			}
+		}
+		if result.Offline {
+			fmt.Printf("📴 %s backend unreachable - served from the offline cache\n", result.Backend)
+		}
+		showResultInfo(authenticator, result)
 	} else {
+		switch {
+		case errors.Is(result.Err, auth.ErrTimeout):
+			fmt.Printf("⏰ Authentication timed out after %s for user: %s\n", backendConfig.Timeout, username)
+			os.Exit(exitTimeout)
+		case errors.Is(result.Err, auth.ErrCanceled):
+			fmt.Printf("🛑 Authentication canceled for user: %s\n", username)
+			os.Exit(1)
+		}
 		fmt.Printf("❌ Authentication failed for user: %s\n", username)
+		if result.Message != "" && (result.Backend != "system" || result.Locked) {
+			fmt.Printf("💡 %s\n", result.Message)
+		}
 		os.Exit(1)
 	}
 }
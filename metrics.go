@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/metrics"
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/bariiss/pam-auth/util/tracing"
+)
+
+// metricsListen is the --metrics-listen address shared by the server subcommands
+var metricsListen string
+
+func init() {
+	usage := "Serve /metrics, /healthz and /readyz on this TCP address, e.g. :9812"
+	radiusServeCmd.Flags().StringVar(&metricsListen, "metrics-listen", "", usage)
+	tacacsServeCmd.Flags().StringVar(&metricsListen, "metrics-listen", "", usage)
+}
+
+// startMetrics serves the metrics and health endpoints when --metrics-listen
+// is set and returns the observer service should report backend calls to
+func startMetrics(service string, authenticator auth.Authenticator) (func(auth.Result, time.Duration), error) {
+	if metricsListen == "" {
+		return nil, nil
+	}
+	m := metrics.New()
+	if useRealPAM {
+		m.WatchPAMPool()
+	}
+
+	listener, err := net.Listen("tcp", metricsListen)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listener: %w", err)
+	}
+	go func() {
+		server := &http.Server{Handler: tracing.Middleware(m.Handler(readinessChecks(authenticator))), ReadHeaderTimeout: 10 * time.Second}
+		if err := server.Serve(listener); err != nil {
+			fmt.Printf("⚠️ Metrics server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("📈 Metrics and health endpoints on http://%s\n", listener.Addr())
+
+	return func(result auth.Result, elapsed time.Duration) {
+		m.Observe(service, result, elapsed)
+	}, nil
+}
+
+// readinessChecks returns the /readyz checks for the selected backend: real
+// PAM needs its service file and other backends must answer a ping, such as
+// a RADIUS Status-Server request or a connection to a KDC. No login is
+// attempted, so scrapes do not show up as rejects or lock out a probe user.
+func readinessChecks(authenticator auth.Authenticator) []metrics.Check {
+	var checks []metrics.Check
+	if useRealPAM {
+		checks = append(checks, metrics.Check{Name: "pam", Run: func(ctx context.Context) error {
+			if !pam.IsPAMAvailable() {
+				return errors.New("real PAM is unavailable: built without PAM support or /etc/pam.d/login missing")
+			}
+			return nil
+		}})
+	}
+	if pinger, ok := authenticator.(auth.Pinger); ok {
+		checks = append(checks, metrics.Check{Name: "backend:" + backendConfig.Name, Run: pinger.Ping})
+	}
+	return checks
+}
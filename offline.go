@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/offline"
+	"github.com/spf13/cobra"
+)
+
+// offlineCmd groups the offline credential cache subcommands
+var offlineCmd = &cobra.Command{
+	Use:   "offline",
+	Short: "Inspect the offline credential cache",
+	Long: `Inspect the encrypted offline credential cache selected with
+--offline-cache. While the radius or krb5 backend is unreachable, users who
+logged in online within --offline-ttl can still log in from this cache, for
+at most --offline-max-age after the backend was last reachable.`,
+}
+
+// offlineListCmd lists cached users
+var offlineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached users",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOfflineList()
+	},
+}
+
+// offlineForgetCmd removes a user from the cache
+var offlineForgetCmd = &cobra.Command{
+	Use:   "forget USER",
+	Short: "Remove a user's cached credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOfflineForget(args[0])
+	},
+}
+
+func init() {
+	offlineCmd.AddCommand(offlineListCmd)
+	offlineCmd.AddCommand(offlineForgetCmd)
+}
+
+// openOfflineCache opens --offline-cache for the offline subcommands
+func openOfflineCache() (*offline.Cache, error) {
+	if backendConfig.OfflineCache == "" {
+		return nil, fmt.Errorf("select the cache with --offline-cache")
+	}
+	keyPath := backendConfig.OfflineKey
+	if keyPath == "" {
+		keyPath = backendConfig.OfflineCache + ".key"
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		return nil, fmt.Errorf("offline cache key: %w", err)
+	}
+	key, err := offline.LoadKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return offline.New(nil, backendConfig.OfflineCache, key), nil
+}
+
+// runOfflineList prints a table of cached users
+func runOfflineList() error {
+	cache, err := openOfflineCache()
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tBACKEND\tLAST ONLINE LOGIN\tEXPIRES\tSTATUS\tGROUPS")
+	for _, entry := range entries {
+		status := "usable"
+		if !now.Before(entry.Expires) {
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Username, entry.Backend,
+			entry.Verified.Format(time.DateTime), entry.Expires.Format(time.DateTime), status, strings.Join(entry.Groups, ","))
+	}
+	return w.Flush()
+}
+
+// runOfflineForget drops username's cached credentials
+func runOfflineForget(username string) error {
+	cache, err := openOfflineCache()
+	if err != nil {
+		return err
+	}
+	removed, err := cache.Forget(username)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("no cached credentials for %s", username)
+	}
+	fmt.Printf("🗑️ Removed cached credentials for user: %s\n", username)
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bariiss/pam-auth/util/pamconf"
+	"github.com/bariiss/pam-auth/util/sysroot"
+	"github.com/spf13/cobra"
+)
+
+// pamCmd groups the PAM configuration subcommands
+var pamCmd = &cobra.Command{
+	Use:   "pam",
+	Short: "Inspect PAM service configuration",
+}
+
+// pamLintCmd checks pam.d files for mistakes
+var pamLintCmd = &cobra.Command{
+	Use:   "lint [SERVICE]",
+	Short: "Check PAM service files for mistakes",
+	Long: `Parse a PAM service file and every file it includes, and report with
+FILE:LINE locations:
+
+  errors:   syntax errors, missing modules, missing or cyclic includes
+  warnings: rules no path through the stack reaches, required rules skipped
+            by an earlier sufficient rule, nullok on remote services
+
+Without SERVICE every file in --dir is checked. Exits with status 1 when
+errors are found.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPAMLint(args)
+	},
+}
+
+// pamSimulateCmd evaluates a stack for hypothetical module results
+var pamSimulateCmd = &cobra.Command{
+	Use:   "simulate SERVICE",
+	Short: "Evaluate a PAM stack for hypothetical module results",
+	Long: `Walk the stack of SERVICE the way libpam does, without loading any
+module: each module returns the value given by --results, including jumps
+such as [success=1 default=ignore], substacks and the done, die and reset
+actions. Prints the trace and the final decision.
+
+Modules missing from --results return --default-result; pam_permit and
+pam_deny keep their fixed results unless listed. With --expect the command
+exits with status 1 when the decision differs.`,
+	Example: `  pam-auth pam simulate sshd --results "pam_unix=success,pam_faillock=auth_err"
+  pam-auth pam simulate login --type account --results pam_unix=acct_expired --expect acct_expired`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runPAMSimulate(args[0])
+	},
+}
+
+// pam lint and simulate flags
+var (
+	pamDir            string
+	pamModuleDirs     []string
+	pamRemoteServices []string
+	pamFacility       string
+	pamResults        string
+	pamDefaultResult  string
+	pamExpect         string
+)
+
+func init() {
+	pamCmd.PersistentFlags().StringVar(&pamDir, "dir", "", "Directory holding the PAM service files (default /etc/pam.d under --root)")
+	pamCmd.PersistentFlags().StringSliceVar(&pamModuleDirs, "module-dir", nil, "Directories searched for PAM modules (default the standard locations under --root)")
+	pamLintCmd.Flags().StringSliceVar(&pamRemoteServices, "remote", pamconf.RemoteServices, "Services reached over the network, where nullok is flagged")
+	pamSimulateCmd.Flags().StringVarP(&pamFacility, "type", "t", "auth", "Stack to evaluate: auth, account, password or session")
+	pamSimulateCmd.Flags().StringVarP(&pamResults, "results", "r", "", "Module results as MODULE=VALUE pairs, e.g. pam_unix=success,pam_faillock=auth_err")
+	pamSimulateCmd.Flags().StringVar(&pamDefaultResult, "default-result", "success", "Result of modules not listed in --results")
+	pamSimulateCmd.Flags().StringVar(&pamExpect, "expect", "", "Exit with status 1 unless the decision is this return value")
+	pamCmd.AddCommand(pamLintCmd)
+	pamCmd.AddCommand(pamSimulateCmd)
+}
+
+// pamOptions resolves the default --dir and --module-dir under --root
+func pamOptions() pamconf.Options {
+	opts := pamconf.Options{Dir: pamDir, ModuleDirs: pamModuleDirs, RemoteServices: pamRemoteServices}
+	if opts.Dir == "" {
+		opts.Dir = sysRoot().Path(sysroot.PAMDir)
+	}
+	if opts.ModuleDirs == nil {
+		opts.ModuleDirs = sysRoot().Paths(pamconf.ModuleDirs)
+	}
+	return opts
+}
+
+// runPAMLint prints the diagnostics for one or every service
+func runPAMLint(args []string) error {
+	opts := pamOptions()
+	services := args
+	if len(services) == 0 {
+		entries, err := os.ReadDir(opts.Dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				services = append(services, entry.Name())
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	errors, warnings := 0, 0
+	for _, service := range services {
+		diags, err := pamconf.Lint(service, opts)
+		if err != nil {
+			return err
+		}
+		// Files included by several services are reported once
+		for _, d := range diags {
+			if seen[d.String()] {
+				continue
+			}
+			seen[d.String()] = true
+			fmt.Println(d)
+			if d.Severity == pamconf.Error {
+				errors++
+			} else {
+				warnings++
+			}
+		}
+	}
+
+	switch {
+	case errors > 0:
+		fmt.Printf("❌ %d errors, %d warnings\n", errors, warnings)
+		return fmt.Errorf("PAM configuration has errors")
+	case warnings > 0:
+		fmt.Printf("⚠️ %d warnings\n", warnings)
+	default:
+		fmt.Printf("✅ No problems found in %d services\n", len(services))
+	}
+	return nil
+}
+
+// runPAMSimulate prints the trace and decision of a simulated stack
+func runPAMSimulate(service string) error {
+	modules, err := pamconf.ParseResults(pamResults)
+	if err != nil {
+		return err
+	}
+	results := pamconf.Results{Modules: modules, Default: pamDefaultResult}
+	sim, err := pamconf.Simulate(service, pamFacility, results, pamOptions())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔎 Simulating the %s stack of %s\n\n", sim.Facility, sim.Service)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tCONTROL\tMODULE\tRESULT\tACTION")
+	for _, step := range sim.Steps {
+		rule := step.Rule
+		module := strings.Repeat("  ", step.Level) + rule.Module
+		if rule.Optional {
+			module = strings.Repeat("  ", step.Level) + "-" + rule.Module
+		}
+		result := step.Result
+		if result == "" {
+			result = "-"
+		}
+		fmt.Fprintf(w, "%s:%d\t%s\t%s\t%s\t%s\n", rule.File, rule.Line, rule.Control, module, result, step.Action)
+	}
+	w.Flush()
+	fmt.Println()
+
+	if sim.Succeeded() {
+		fmt.Printf("✅ Decision: %s\n", sim.Decision)
+	} else {
+		fmt.Printf("❌ Decision: %s\n", sim.Decision)
+	}
+	if pamExpect != "" && sim.Decision != pamExpect {
+		return fmt.Errorf("expected %s, the stack decided %s", pamExpect, sim.Decision)
+	}
+	return nil
+}
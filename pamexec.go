@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/audit"
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/backend"
+	"github.com/bariiss/pam-auth/util/tracing"
+	"github.com/bariiss/pam-auth/util/utmp"
+	"github.com/spf13/cobra"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pamExecCmd runs pam-auth as a pam_exec(8) helper
+var pamExecCmd = &cobra.Command{
+	Use:   "pam-exec",
+	Short: "Run as a pam_exec helper",
+	Long: `Run under pam_exec.so as a lightweight alternative to the native PAM
+module. The PAM context is read from PAM_USER, PAM_RHOST, PAM_SERVICE and
+PAM_TYPE and, for the auth type, the password from stdin (expose_authtok).
+The exit status tells pam_exec whether to succeed.
+
+  auth    required pam_exec.so expose_authtok quiet /usr/local/bin/pam-auth pam-exec --backend db
+  account required pam_exec.so quiet /usr/local/bin/pam-auth pam-exec --backend db
+  session optional pam_exec.so quiet /usr/local/bin/pam-auth pam-exec --backend db --audit-log /var/log/pam-auth.log
+
+The selected backend (or comma separated chain of backends) must not be
+"system", which would recurse into PAM.
+
+With --utmp the session hook also records the session in utmp and wtmp
+for who and last, and rejected passwords are recorded in btmp for lastb.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		code := runPAMExec()
+		flushTraces()
+		os.Exit(code)
+	},
+}
+
+// pam-exec exit statuses
+const (
+	pamExecSuccess = 0
+	pamExecDenied  = 1
+	pamExecError   = 2
+)
+
+// pamExecRequest is the PAM state pam_exec passes in the environment
+type pamExecRequest struct {
+	user    string
+	rhost   string
+	ruser   string
+	tty     string
+	service string
+	kind    string
+}
+
+// runPAMExec handles one pam_exec invocation and returns the exit status
+func runPAMExec() int {
+	req := pamExecRequest{
+		user:    os.Getenv("PAM_USER"),
+		rhost:   os.Getenv("PAM_RHOST"),
+		ruser:   os.Getenv("PAM_RUSER"),
+		tty:     os.Getenv("PAM_TTY"),
+		service: os.Getenv("PAM_SERVICE"),
+		kind:    os.Getenv("PAM_TYPE"),
+	}
+	if req.user == "" || req.kind == "" {
+		fmt.Fprintln(os.Stderr, "pam-exec: PAM_USER and PAM_TYPE must be set (run from pam_exec.so)")
+		return pamExecError
+	}
+
+	switch req.kind {
+	case "open_session", "close_session", "auth", "account":
+	default:
+		fmt.Fprintf(os.Stderr, "pam-exec: unsupported PAM_TYPE %s\n", req.kind)
+		return pamExecError
+	}
+
+	ctx, cancel := authContext()
+	defer cancel()
+	ctx, span := tracing.Tracer().Start(ctx, "pam-exec "+req.kind, trace.WithAttributes(semconv.EnduserID(req.user)))
+	defer span.End()
+	if req.kind == "open_session" || req.kind == "close_session" {
+		return pamExecSession(ctx, req)
+	}
+
+	// stdin carries the token, so challenges cannot be answered interactively
+	cfg := backendConfig
+	cfg.RADIUSPrompt = nil
+	authenticator, err := backend.New(cfg)
+	if errors.Is(err, backend.ErrSystem) {
+		fmt.Fprintln(os.Stderr, "pam-exec: select a non-system backend with --backend")
+		return pamExecError
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: %v\n", err)
+		return pamExecError
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if req.kind == "auth" {
+		return pamExecAuth(ctx, req, authenticator)
+	}
+	return pamExecAccount(ctx, req, authenticator)
+}
+
+// pamExecAuth verifies the token pam_exec writes to stdin. A timed out
+// attempt is an error rather than a rejection.
+func pamExecAuth(ctx context.Context, req pamExecRequest, authenticator auth.Authenticator) int {
+	token, err := io.ReadAll(io.LimitReader(os.Stdin, 4096))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: reading token: %v\n", err)
+		return pamExecError
+	}
+	// pam_exec terminates the token with a NUL byte
+	token = bytes.TrimRight(token, "\x00\r\n")
+
+	result := tracing.Authenticate(ctx, "backend", authenticator, req.user, string(token))
+	req.record(ctx, audit.EventAuthentication, result.Success, result.Message, result.Backend)
+	if result.Err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: %v for %s\n", result.Err, req.user)
+		return pamExecError
+	}
+	if !result.Success {
+		fmt.Fprintf(os.Stderr, "pam-exec: authentication failed for %s: %s\n", req.user, result.Message)
+		recordUtmpFailure(req.user, req.line(), req.rhost, os.Getppid())
+		return pamExecDenied
+	}
+	return pamExecSuccess
+}
+
+// pamExecAccount applies the account policy for backends that keep account data
+func pamExecAccount(ctx context.Context, req pamExecRequest, authenticator auth.Authenticator) int {
+	source, ok := authenticator.(auth.InfoSource)
+	if !ok {
+		return pamExecSuccess
+	}
+	info, err := source.UserInfo(req.user)
+	if errors.Is(err, backend.ErrNoAccountData) {
+		return pamExecSuccess
+	}
+	if err == nil {
+		_, span := tracing.Tracer().Start(ctx, "policy")
+		err = auth.CheckAccount(info, time.Now())
+		span.End()
+	}
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	req.record(ctx, audit.EventAuthorization, err == nil, message, backendConfig.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: account check failed for %s: %v\n", req.user, err)
+		return pamExecDenied
+	}
+	return pamExecSuccess
+}
+
+// pamExecSession records session start and end; it never fails the session.
+// The login records carry the PID of the PAM application, pam_exec's parent,
+// so the close_session call finds the entry of open_session.
+func pamExecSession(ctx context.Context, req pamExecRequest) int {
+	message := "session opened"
+	if req.kind == "close_session" {
+		message = "session closed"
+	}
+	req.record(ctx, audit.EventSession, true, message, "")
+	if recordUtmp {
+		login := utmp.NewRecord(utmp.UserProcess, os.Getppid(), req.line(), req.user, req.rhost)
+		record := utmp.RecordLogin
+		if req.kind == "close_session" {
+			record = utmp.RecordLogout
+		}
+		if err := record(sysRoot(), login); err != nil {
+			fmt.Fprintf(os.Stderr, "pam-exec: %v\n", err)
+		}
+	}
+	return pamExecSuccess
+}
+
+// line returns the terminal for login records, or SERVICE:notty when
+// PAM_TTY names none; sshd sets it to "ssh" for sessions without a pty
+func (req pamExecRequest) line() string {
+	if strings.Contains(req.tty, "/") || strings.HasPrefix(req.tty, "tty") {
+		return req.tty
+	}
+	service := req.service
+	if service == "" {
+		service = "pam-auth"
+	}
+	return service + ":notty"
+}
+
+// record writes an audit record for this invocation
+func (req pamExecRequest) record(ctx context.Context, event string, success bool, message, backendName string) {
+	_, span := tracing.Tracer().Start(ctx, "audit")
+	defer span.End()
+	auditLog, err := openAuditLog()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: %v\n", err)
+		return
+	}
+	defer auditLog.Close()
+
+	outcome := "failure"
+	if success {
+		outcome = "success"
+	}
+	attributes := map[string]string{"pam_type": req.kind}
+	if backendName != "" {
+		attributes["backend"] = backendName
+	}
+	if req.ruser != "" {
+		attributes["ruser"] = req.ruser
+	}
+	err = auditLog.Log(audit.Record{
+		Event:      event,
+		Service:    "pam:" + req.service,
+		Username:   req.user,
+		Remote:     req.rhost,
+		Port:       req.tty,
+		Outcome:    outcome,
+		Message:    message,
+		Attributes: attributes,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pam-exec: writing audit record: %v\n", err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bariiss/pam-auth/util/policy"
+	"github.com/spf13/cobra"
+)
+
+// policyCmd groups the password policy subcommands
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Check passwords against the password policy",
+}
+
+// policyCheckCmd tries a password against the policy without storing it
+var policyCheckCmd = &cobra.Command{
+	Use:   "check [USER]",
+	Short: "Check a password against the password policy",
+	Long: `Prompt for a password and report every rule of the password policy it
+breaks, without storing it. The same policy flags select the policy enforced
+by htpasswd add, db user add and db user passwd.
+
+With USER the password is also compared with the user name and the full
+name from the passwd file (under --root), or --gecos. Exits with status 1
+when the password is rejected.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		pol, err := passwordPolicy(cmd)
+		if err != nil {
+			return err
+		}
+		return runPolicyCheck(pol, args)
+	},
+}
+
+// policy check flags
+var (
+	policyGecos string
+	policyJSON  bool
+)
+
+func init() {
+	addPolicyFlags(policyCheckCmd, "nist")
+	policyCheckCmd.Flags().StringVar(&policyGecos, "gecos", "", "Full name to compare the password with (default from the passwd file)")
+	policyCheckCmd.Flags().BoolVar(&policyJSON, "json", false, "Print the verdict as JSON")
+	policyCmd.AddCommand(policyCheckCmd)
+}
+
+// addPolicyFlags registers the password policy flags on cmd. The flags are
+// read back with passwordPolicy, since cmd's preset default differs between
+// commands.
+func addPolicyFlags(cmd *cobra.Command, preset string) {
+	flags := cmd.Flags()
+	flags.String("policy", preset, "Password policy preset: none or nist (NIST SP 800-63B)")
+	flags.Int("min-length", 0, "Minimum password length in characters (overrides the preset)")
+	flags.Int("max-length", 0, "Maximum password length in characters (overrides the preset)")
+	flags.Int("min-classes", 0, "Character classes required among lowercase, uppercase, digits and symbols")
+	flags.Bool("check-user", false, "Reject passwords containing the user name or full name (overrides the preset)")
+	flags.String("dictionary", "", "Word list rejected besides the built-in common passwords")
+	flags.Int("history", 0, "Reject the current and this many minus one earlier passwords")
+	flags.String("breached", "", "Sorted SHA-1 breached password list, e.g. the Pwned Passwords download")
+}
+
+// passwordPolicy resolves the policy preset and overrides given on cmd
+func passwordPolicy(cmd *cobra.Command) (policy.Policy, error) {
+	flags := cmd.Flags()
+	preset, _ := flags.GetString("policy")
+	pol, err := policy.Preset(preset)
+	if err != nil {
+		return policy.Policy{}, err
+	}
+	if flags.Changed("min-length") {
+		pol.MinLength, _ = flags.GetInt("min-length")
+	}
+	if flags.Changed("max-length") {
+		pol.MaxLength, _ = flags.GetInt("max-length")
+	}
+	if flags.Changed("min-classes") {
+		pol.MinClasses, _ = flags.GetInt("min-classes")
+	}
+	if flags.Changed("check-user") {
+		pol.CheckUser, _ = flags.GetBool("check-user")
+	}
+	if flags.Changed("dictionary") {
+		pol.Dictionary = true
+		pol.DictionaryFile, _ = flags.GetString("dictionary")
+	}
+	if flags.Changed("history") {
+		pol.History, _ = flags.GetInt("history")
+	}
+	if flags.Changed("breached") {
+		pol.BreachedFile, _ = flags.GetString("breached")
+	}
+	return pol, nil
+}
+
+// enforcePolicy refuses password when it breaks pol, listing the reasons
+func enforcePolicy(pol policy.Policy, password string, account policy.Account) error {
+	violations, err := pol.Check(password, account)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	printViolations(violations)
+	return errors.New("password does not meet the password policy")
+}
+
+// printViolations lists why a password was rejected
+func printViolations(violations []policy.Violation) {
+	fmt.Println("❌ Password rejected by the password policy:")
+	for _, v := range violations {
+		fmt.Printf("   • %s (%s)\n", v.Reason, v.Rule)
+	}
+}
+
+// runPolicyCheck prompts for a password and prints the policy's verdict
+func runPolicyCheck(pol policy.Policy, args []string) error {
+	account := policy.Account{Gecos: policyGecos}
+	if len(args) > 0 {
+		account.Username = args[0]
+		if account.Gecos == "" {
+			if u, err := sysRoot().LookupUser(account.Username); err == nil {
+				account.Gecos = u.Name
+			}
+		}
+	}
+
+	// Keep the prompt out of JSON output
+	prompt := promptSecret
+	if policyJSON {
+		prompt = func(string) (string, error) { return readPassword() }
+	}
+	password, err := prompt("Password: ")
+	if err != nil {
+		return err
+	}
+	violations, err := pol.Check(password, account)
+	if err != nil {
+		return err
+	}
+
+	if policyJSON {
+		if violations == nil {
+			violations = []policy.Violation{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			Policy     policy.Policy      `json:"policy"`
+			Accepted   bool               `json:"accepted"`
+			Violations []policy.Violation `json:"violations"`
+		}{pol, len(violations) == 0, violations})
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			os.Exit(1)
+		}
+		return nil
+	}
+
+	if len(violations) > 0 {
+		printViolations(violations)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Password meets the password policy")
+	return nil
+}
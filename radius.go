@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/radius"
+	"github.com/bariiss/pam-auth/util/totp"
+	"github.com/spf13/cobra"
+)
+
+// radiusServeCmd runs pam-auth as a RADIUS authentication server
+var radiusServeCmd = &cobra.Command{
+	Use:   "radius-serve",
+	Short: "Run a RADIUS server backed by pam-auth",
+	Long: `Answer RFC 2865 Access-Requests from network equipment using the
+pam-auth authentication backends. PAP is always supported; CHAP and
+MS-CHAPv2 need the cleartext password, which only the htpasswd backend
+supplies, for plaintext entries with --htpasswd-plaintext.
+
+On Linux the system backend checks passwords against /etc/shadow unless
+--real-pam is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRADIUSServer()
+	},
+}
+
+// RADIUS server flags
+var (
+	radiusListen         string
+	radiusClientsFile    string
+	radiusClients        []string
+	radiusReplyRules     []string
+	radiusTOTPSecrets    string
+	radiusRequireMsgAuth bool
+)
+
+func init() {
+	radiusServeCmd.Flags().StringVar(&radiusListen, "listen", ":1812", "UDP address to listen on")
+	radiusServeCmd.Flags().StringVar(&radiusClientsFile, "clients", "", "File with one \"CIDR secret\" client entry per line")
+	radiusServeCmd.Flags().StringArrayVar(&radiusClients, "client", nil, "Allowed client as CIDR=SECRET (repeatable)")
+	radiusServeCmd.Flags().StringArrayVar(&radiusReplyRules, "reply", nil, "Reply attribute for group members as GROUP=ATTRIBUTE:VALUE (repeatable, GROUP may be *)")
+	radiusServeCmd.Flags().StringVar(&radiusTOTPSecrets, "totp-secrets", "", "File with \"username:BASE32SECRET\" entries; enrolled users get an Access-Challenge")
+	radiusServeCmd.Flags().BoolVar(&radiusRequireMsgAuth, "require-message-authenticator", false, "Drop Access-Requests without a Message-Authenticator")
+}
+
+// runRADIUSServer builds the RADIUS server from flags and serves until interrupted
+func runRADIUSServer() error {
+	authenticator, err := newServerAuthenticator()
+	if err != nil {
+		return err
+	}
+
+	server := &radius.Server{
+		Addr:                        radiusListen,
+		Authenticator:               authenticator,
+		RequireMessageAuthenticator: radiusRequireMsgAuth,
+		AuthTimeout:                 backendConfig.Timeout,
+	}
+
+	if radiusClientsFile != "" {
+		clients, err := radius.LoadClients(radiusClientsFile)
+		if err != nil {
+			return fmt.Errorf("loading clients: %w", err)
+		}
+		server.Clients = append(server.Clients, clients...)
+	}
+	for _, spec := range radiusClients {
+		address, secret, _ := strings.Cut(spec, "=")
+		client, err := radius.ParseClient(address, secret)
+		if err != nil {
+			return err
+		}
+		server.Clients = append(server.Clients, client)
+	}
+	if len(server.Clients) == 0 {
+		return fmt.Errorf("no RADIUS clients configured (use --client or --clients)")
+	}
+
+	for _, spec := range radiusReplyRules {
+		rule, err := radius.ParseReplyRule(spec)
+		if err != nil {
+			return err
+		}
+		server.ReplyRules = append(server.ReplyRules, rule)
+	}
+
+	if radiusTOTPSecrets != "" {
+		store, err := totp.LoadStore(radiusTOTPSecrets)
+		if err != nil {
+			return fmt.Errorf("loading TOTP secrets: %w", err)
+		}
+		server.SecondFactor = store
+		fmt.Println("🔐 Second factor enabled: enrolled users will receive an Access-Challenge")
+	}
+
+	startPAMPool()
+	if server.Observe, err = startMetrics("radius", authenticator); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("\n🛑 Shutting down RADIUS server...")
+		showPAMPoolStats()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	return server.ListenAndServe()
+}
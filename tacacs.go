@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/bariiss/pam-auth/util/radius"
+	"github.com/bariiss/pam-auth/util/tacacs"
+	"github.com/spf13/cobra"
+)
+
+// tacacsServeCmd runs pam-auth as a TACACS+ server
+var tacacsServeCmd = &cobra.Command{
+	Use:   "tacacs-serve",
+	Short: "Run a TACACS+ server backed by pam-auth",
+	Long: `Serve TACACS+ (RFC 8907) for network device administration. Logins
+(ASCII and PAP) are verified with the selected pam-auth backend,
+authorization grants privilege levels from group membership and
+accounting records are written to the audit log.
+
+Users without a mapped group are denied authorization unless
+--default-priv-lvl is set, and then only once they logged in or the backend
+lists groups for them. On Linux the system backend checks passwords against
+/etc/shadow unless --real-pam is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTACACSServer()
+	},
+}
+
+// TACACS+ server flags
+var (
+	tacacsListen       string
+	tacacsClientsFile  string
+	tacacsClients      []string
+	tacacsPrivLevels   []string
+	tacacsDefaultLevel int
+)
+
+func init() {
+	tacacsServeCmd.Flags().StringVar(&tacacsListen, "listen", ":49", "TCP address to listen on")
+	tacacsServeCmd.Flags().StringVar(&tacacsClientsFile, "clients", "", "File with one \"CIDR key\" device entry per line")
+	tacacsServeCmd.Flags().StringArrayVar(&tacacsClients, "client", nil, "Allowed device as CIDR=KEY (repeatable)")
+	tacacsServeCmd.Flags().StringArrayVar(&tacacsPrivLevels, "priv-lvl", nil, "Privilege level for group members as GROUP=LEVEL (repeatable)")
+	tacacsServeCmd.Flags().IntVar(&tacacsDefaultLevel, "default-priv-lvl", -1, "Privilege level for logged in users without a mapped group (-1 denies authorization)")
+}
+
+// runTACACSServer builds the TACACS+ server from flags and serves until interrupted
+func runTACACSServer() error {
+	authenticator, err := newServerAuthenticator()
+	if err != nil {
+		return err
+	}
+
+	// Device keys use the same "CIDR secret" format as RADIUS clients
+	var clients radius.ClientList
+	if tacacsClientsFile != "" {
+		clients, err = radius.LoadClients(tacacsClientsFile)
+		if err != nil {
+			return fmt.Errorf("loading clients: %w", err)
+		}
+	}
+	for _, spec := range tacacsClients {
+		address, key, _ := strings.Cut(spec, "=")
+		client, err := radius.ParseClient(address, key)
+		if err != nil {
+			return err
+		}
+		clients = append(clients, client)
+	}
+	if len(clients) == 0 {
+		return fmt.Errorf("no TACACS+ clients configured (use --client or --clients)")
+	}
+
+	levels := make(map[string]int)
+	for _, spec := range tacacsPrivLevels {
+		group, value, ok := strings.Cut(spec, "=")
+		level, err := strconv.Atoi(value)
+		if !ok || group == "" || err != nil || level < 0 || level > 15 {
+			return fmt.Errorf("invalid --priv-lvl %q: expected GROUP=LEVEL with LEVEL 0-15", spec)
+		}
+		levels[group] = level
+	}
+
+	auditLog, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
+	server := &tacacs.Server{
+		Addr:             tacacsListen,
+		Keys:             clients,
+		Authenticator:    authenticator,
+		PrivilegeLevels:  levels,
+		DefaultPrivilege: tacacsDefaultLevel,
+		Audit:            auditLog,
+		AuthTimeout:      backendConfig.Timeout,
+	}
+
+	startPAMPool()
+	if server.Observe, err = startMetrics("tacacs", authenticator); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("\n🛑 Shutting down TACACS+ server...")
+		showPAMPoolStats()
+		server.Close()
+	}()
+
+	return server.ListenAndServe()
+}
@@ -0,0 +1,163 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Password Aging Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-accounts.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+ROOT="$WORKDIR/image"
+mkdir -p "$ROOT/etc"
+HASH=$(openssl passwd -6 -salt agingTest s3cret)
+TODAY=$(( $(date +%s) / 86400 ))
+
+cat > "$ROOT/etc/passwd" <<PASSWD
+root:x:0:0:root:/root:/bin/sh
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+active:x:1001:1001::/home/active:/bin/sh
+warnuser:x:1002:1002::/home/warnuser:/bin/sh
+pwexpired:x:1003:1003::/home/pwexpired:/bin/sh
+inactive:x:1004:1004::/home/inactive:/bin/sh
+acctexp:x:1005:1005::/home/acctexp:/bin/sh
+forever:x:1006:1006::/home/forever:/bin/sh
+nopass:x:1007:1007::/home/nopass:/bin/sh
+locked:x:1008:1008::/home/locked:/bin/sh
+mustchange:x:1009:1009::/home/mustchange:/bin/sh
+noshadow:x:1010:1010::/home/noshadow:/bin/sh
+nobody:x:65534:65534::/nonexistent:/usr/sbin/nologin
+PASSWD
+cat > "$ROOT/etc/group" <<GROUP
+root:x:0:
+GROUP
+cat > "$ROOT/etc/shadow" <<SHADOW
+root:$HASH:$((TODAY - 10)):0:90:7:::
+daemon:*:$((TODAY - 10)):0:99999:7:::
+active:$HASH:$((TODAY - 10)):1:90:7:::
+warnuser:$HASH:$((TODAY - 85)):0:90:7:::
+pwexpired:$HASH:$((TODAY - 100)):0:90:7:::
+inactive:$HASH:$((TODAY - 200)):0:90:7:30::
+acctexp:$HASH:$((TODAY - 10)):0:90:7::$((TODAY - 1)):
+forever:$HASH:$((TODAY - 10)):0:99999:7:::
+nopass::$((TODAY - 10)):0:90:7:::
+locked:!$HASH:$((TODAY - 10)):0:90:7:::
+mustchange:$HASH:0:0:90:7:::
+nobody:*:$((TODAY - 10)):0:99999:7:::
+SHADOW
+
+# day N prints the date N days after the epoch, as shadow(5) counts
+day() {
+    python3 -c 'import datetime,sys; print(datetime.date(1970,1,1) + datetime.timedelta(days=int(sys.argv[1])))' "$1"
+}
+
+# login USER runs the interactive flow against the image with the right password
+login() {
+    printf '%s\n%s\n' "$1" s3cret | ./pam-auth --root "$ROOT"
+}
+
+login active > "$WORKDIR/active.out"
+./pam-auth --root "$ROOT" accounts report > "$WORKDIR/report.out"
+./pam-auth --root "$ROOT" accounts report --json > "$WORKDIR/report.json"
+
+run_test "Valid account logs in" "grep -q 'Password authentication successful' $WORKDIR/active.out"
+
+run_test "User info shows the last password change" \
+    "grep -q \"^Password Last Changed: $(day $((TODAY - 10)))\$\" $WORKDIR/active.out"
+
+run_test "User info shows the password expiry" \
+    "grep -q \"^Password Expires: $(day $((TODAY + 80)))\$\" $WORKDIR/active.out"
+
+run_test "User info shows the account expiry" "grep -q '^Account Expires: never$' $WORKDIR/active.out"
+
+run_test "User info shows the minimum age" "grep -q '^Minimum Password Age: 1 days$' $WORKDIR/active.out"
+
+run_test "Password about to expire warns after login" \
+    "login warnuser | grep -q '⚠️ Your password expires in 5 days'"
+
+run_test "Expired password is refused" \
+    "login pwexpired | grep -q 'password expired: it must be changed'"
+
+run_test "Expired password exits 1" "login pwexpired" 1
+
+run_test "Inactive account is refused" "login inactive | grep -q 'inactivity period has passed'"
+
+run_test "Expired account is refused" \
+    "login acctexp | grep -q \"account expired on $(day $((TODAY - 1)))\""
+
+run_test "Forced password change is refused" "login mustchange | grep -q 'must be changed'"
+
+run_test "Report lists locked accounts" "grep -q '^locked  *1008  *locked ' $WORKDIR/report.out"
+
+run_test "Report lists passwordless accounts" "grep -q '^nopass  *1007  *passwordless ' $WORKDIR/report.out"
+
+run_test "Report lists never-expiring passwords" "grep -q '^forever  *1006  *never-expires ' $WORKDIR/report.out"
+
+run_test "Report lists expired accounts" \
+    "grep -q '^acctexp  *1005  *expired ' $WORKDIR/report.out && grep -q '^pwexpired  *1003  *expired ' $WORKDIR/report.out && grep -q '^inactive  *1004  *expired ' $WORKDIR/report.out"
+
+run_test "Accounts without a shadow entry are locked" "grep -q '^noshadow .*no shadow entry' $WORKDIR/report.out"
+
+run_test "Healthy accounts are not listed" "! grep -qE '^(active|warnuser|root|mustchange) ' $WORKDIR/report.out"
+
+run_test "System accounts are skipped by default" "! grep -qE '^(daemon|nobody) ' $WORKDIR/report.out"
+
+run_test "--all includes system accounts" \
+    "./pam-auth --root $ROOT accounts report --all | grep -q '^daemon  *1  *locked'"
+
+run_test "Report summary counts categories" \
+    "grep -q 'locked: 2  expired: 3  never-expires: 1  passwordless: 1  (11 accounts checked)' $WORKDIR/report.out"
+
+run_test "JSON report is valid" \
+    "python3 -c 'import json,sys; a={a[\"name\"]: a for a in json.load(open(sys.argv[1]))}; assert a[\"inactive\"][\"aging\"] == \"inactive\" and a[\"forever\"][\"categories\"] == [\"never-expires\"]' $WORKDIR/report.json"
+
+run_test "UID range follows login.defs" \
+    "printf 'UID_MIN 1005\nUID_MAX 1006\n' > $ROOT/etc/login.defs && ./pam-auth --root $ROOT accounts report | grep -q '(3 accounts checked)'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,110 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Benchmark Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+RADIUS_PORT=18160
+RADIUS_SECRET=testing123
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-bench.XXXXXX)
+server_pid=""
+trap '[ -n "$server_pid" ] && kill $server_pid 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+USERS="$WORKDIR/users"
+cat > "$USERS" <<USERS
+# one good and one bad password
+alice:fake
+bob:wrong
+USERS
+
+# fake_bench FLAGS... benchmarks the fake backend in-process
+fake_bench() {
+    ./pam-auth bench --backend fake --users "$USERS" "$@"
+}
+
+run_test "--users is required" "./pam-auth bench --backend fake" 1
+run_test "Malformed users file is refused" "printf 'nocolon\n' > $WORKDIR/bad; ./pam-auth bench --backend fake --users $WORKDIR/bad -n 10" 1
+run_test "Fixed request count is honoured" "fake_bench -n 200 -d 0 | grep -q '^📊 200 attempts'"
+run_test "Outcomes are reported separately" \
+    "fake_bench -n 200 -d 0 > $WORKDIR/out; grep -q '^success  *100 ' $WORKDIR/out && grep -q '^reject  *100 ' $WORKDIR/out"
+run_test "Latency percentiles reflect the backend" \
+    "fake_bench -n 40 -d 0 -c 4 --fake-latency 20ms | grep -Eq '^success .* 2[0-9]\.[0-9]+ms'"
+run_test "Duration bounds the run" "timeout 5 ./pam-auth bench --backend fake --users $USERS -d 1s --fake-latency 1ms"
+run_test "Steady runs report no error spikes" "fake_bench -n 500 -d 0 | grep -q 'No error spikes'"
+run_test "Error spikes fail the run" "fake_bench -n 500 -d 0 --fake-error-rate 0.2" 1
+run_test "Error spikes are reported" "fake_bench -n 500 -d 0 --fake-error-rate 0.2 | grep -q 'Error spike at +0s: 100 of 500'"
+run_test "Spike threshold is configurable" "fake_bench -n 500 -d 0 --fake-error-rate 0.2 --spike-threshold 0.5"
+run_test "Slow attempts count as timeouts" \
+    "fake_bench -n 8 -d 0 --fake-latency 200ms --timeout 20ms --spike-threshold 2 | grep -q '^timeout  *8 '"
+
+run_test "Fake backend is only offered by bench" \
+    "printf 'fake\n' | ./pam-auth radius-serve --backend fake --listen 127.0.0.1:$RADIUS_PORT --client 127.0.0.1=$RADIUS_SECRET 2>&1 | grep -q 'unknown backend: fake'"
+
+# A running radius-serve endpoint answering for alice from an htpasswd file
+printf 'fake\nfake\n' | ./pam-auth htpasswd add --create "$WORKDIR/htpasswd" alice > /dev/null || exit 1
+./pam-auth radius-serve --backend htpasswd --htpasswd-file "$WORKDIR/htpasswd" --listen 127.0.0.1:$RADIUS_PORT \
+    --client 127.0.0.1=$RADIUS_SECRET > "$WORKDIR/server.log" 2>&1 &
+server_pid=$!
+sleep 1
+
+run_test "Benchmarks a running radius-serve" \
+    "./pam-auth bench --backend radius --radius-server 127.0.0.1:$RADIUS_PORT --radius-secret $RADIUS_SECRET \
+        --users $USERS -n 100 -d 0 -c 8 > $WORKDIR/radius.out; grep -q '^success  *50 ' $WORKDIR/radius.out && grep -q '^reject  *50 ' $WORKDIR/radius.out"
+
+kill $server_pid 2>/dev/null
+wait $server_pid 2>/dev/null
+server_pid=""
+
+run_test "Unreachable endpoints show up as errors" \
+    "./pam-auth bench --backend radius --radius-server 127.0.0.1:$RADIUS_PORT --radius-secret $RADIUS_SECRET \
+        --radius-timeout 100ms --radius-retries 0 --users $USERS -n 4 -d 0 | grep -q '^error  *4 '"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,93 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - SQLite User Database Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+DBDIR=$(mktemp -d /tmp/pam-auth-db.XXXXXX)
+DB="$DBDIR/users.db"
+trap 'rm -rf "$DBDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+login() {
+    printf '%s\n%s\n' "$1" "$2" | ./pam-auth --backend db --db-file "$DB"
+}
+
+run_test "Add user with groups, attributes and expiry" \
+    "printf 'alicepw\nalicepw\n' | ./pam-auth --db-file $DB db user add alice --name 'Alice A' --group devs,oncall --attr team=ops --expires 2999-01-01"
+run_test "Adding an existing user is refused" \
+    "printf 'x\nx\n' | ./pam-auth --db-file $DB db user add alice" 1
+run_test "Host account names are refused" \
+    "printf 'x\nx\n' | ./pam-auth --db-file $DB db user add root" 1
+run_test "System IDs are refused" \
+    "printf 'x\nx\n' | ./pam-auth --db-file $DB db user add mallory --uid 5" 1
+run_test "Host group names are refused" \
+    "printf 'x\nx\n' | ./pam-auth --db-file $DB db user add mallory --group root" 1
+run_test "Password is stored as argon2id" "grep -aq 'argon2id\\\$v=19' $DB"
+run_test "Correct password logs in" "login alice alicepw"
+run_test "Wrong password fails" "login alice nope" 1
+run_test "Unknown user fails" "login nobody x" 1
+run_test "Login shows database account info" "login alice alicepw | grep -q 'User ID: 100000'"
+run_test "Login shows groups" "login alice alicepw | grep -q 'Groups: devs oncall'"
+run_test "Login shows attributes" "login alice alicepw | grep -q 'team: ops'"
+run_test "Change password" "printf 'newpw\nnewpw\n' | ./pam-auth --db-file $DB db user passwd alice"
+run_test "Old password no longer works" "login alice alicepw" 1
+run_test "New password works" "login alice newpw"
+run_test "Disable user" "./pam-auth --db-file $DB db user disable alice"
+run_test "Disabled user is refused" "login alice newpw" 1
+run_test "List shows disabled status" "./pam-auth --db-file $DB db user list | grep -q 'alice.*disabled'"
+run_test "Re-enable user" "./pam-auth --db-file $DB db user disable --enable alice"
+run_test "Re-enabled user logs in" "login alice newpw"
+run_test "Add already expired user" \
+    "printf 'bobpw\nbobpw\n' | ./pam-auth --db-file $DB db user add bob --expires 2000-01-01"
+run_test "Expired user is refused" "login bob bobpw" 1
+run_test "List shows expired status" "./pam-auth --db-file $DB db user list | grep -q 'bob.*expired'"
+run_test "Show prints account details" "./pam-auth --db-file $DB db user show alice | grep -q 'Name: Alice A'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,159 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Run As User Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-exec.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+# Switching users needs root; daemon is the target account
+RUN_USER=daemon
+if [[ "$OSTYPE" != "linux-gnu"* ]] || [ "$(id -u)" -ne 0 ] || ! id $RUN_USER > /dev/null 2>&1; then
+    echo "⏭️  SKIPPED: the exec tests need Linux, root and the $RUN_USER account"
+    exit 77
+fi
+
+# The target user must reach the helper scripts
+chmod 755 "$WORKDIR"
+printf 's3cret\ns3cret\n' | ./pam-auth htpasswd add --create "$WORKDIR/htpasswd" $RUN_USER > /dev/null || exit 1
+printf 'r00tpw\nr00tpw\n' | ./pam-auth htpasswd add "$WORKDIR/htpasswd" root > /dev/null || exit 1
+chmod 644 "$WORKDIR/htpasswd"
+cat > "$WORKDIR/where.sh" <<'SH'
+#!/bin/sh
+pwd
+SH
+chmod 755 "$WORKDIR/where.sh"
+
+# runas PASSWORD ARGS... runs exec for the target user with the htpasswd backend
+runas() {
+    local password="$1"
+    shift
+    printf '%s\n' "$password" | ./pam-auth exec --backend htpasswd --htpasswd-file "$WORKDIR/htpasswd" "$@" 2> /dev/null
+}
+
+# login_defs KEY prints the path login.defs sets for KEY
+login_defs() {
+    awk -v key="$1" '$1 == key { sub(/^PATH=/, "", $2); print $2 }' /etc/login.defs
+}
+
+run_test "Exec command is registered" "./pam-auth exec --help | grep -q -- '--session'"
+
+run_test "Command runs with the user's UID" "[ \"\$(runas s3cret --user $RUN_USER -- id -u)\" = \"$(id -u $RUN_USER)\" ]"
+
+run_test "Command runs with the user's primary group" "[ \"\$(runas s3cret --user $RUN_USER -- id -g)\" = \"$(id -g $RUN_USER)\" ]"
+
+run_test "Command gets the user's supplementary groups" "[ \"\$(runas s3cret --user $RUN_USER -- id -G)\" = \"$(id -G $RUN_USER)\" ]"
+
+run_test "Password prompt stays off standard output" "[ \"\$(runas s3cret --user $RUN_USER -- echo ok)\" = ok ]"
+
+run_test "Environment is reset" "! FOO=bar runas s3cret --user $RUN_USER -- env | grep -q '^FOO='"
+
+run_test "HOME, SHELL, USER and LOGNAME describe the user" \
+    "runas s3cret --user $RUN_USER -- env > $WORKDIR/env && grep -qx 'HOME=$(getent passwd $RUN_USER | cut -d: -f6)' $WORKDIR/env && grep -qx 'SHELL=$(getent passwd $RUN_USER | cut -d: -f7)' $WORKDIR/env && grep -qx 'USER=$RUN_USER' $WORKDIR/env && grep -qx 'LOGNAME=$RUN_USER' $WORKDIR/env"
+
+run_test "TERM is kept" "[ \"\$(TERM=vt100 runas s3cret --user $RUN_USER -- printenv TERM)\" = vt100 ]"
+
+if [ -n "$(login_defs ENV_PATH)" ]; then
+    run_test "PATH comes from ENV_PATH" "[ \"\$(runas s3cret --user $RUN_USER -- printenv PATH)\" = '$(login_defs ENV_PATH)' ]"
+fi
+if [ -n "$(login_defs ENV_SUPATH)" ]; then
+    run_test "Root gets ENV_SUPATH" "[ \"\$(runas r00tpw --user root -- printenv PATH)\" = '$(login_defs ENV_SUPATH)' ]"
+fi
+
+run_test "Wrong password is refused" "runas wrong --user $RUN_USER -- touch $WORKDIR/ran" 1
+
+run_test "Refused command does not run" "[ ! -e $WORKDIR/ran ]"
+
+run_test "Exit status of the command is kept" "runas s3cret --user $RUN_USER -- sh -c 'exit 7'" 7
+
+run_test "Unknown command exits 127" "runas s3cret --user $RUN_USER -- no-such-command" 127
+
+run_test "Rest of standard input reaches the command" \
+    "[ \"\$(printf 's3cret\necho piped\n' | ./pam-auth exec --backend htpasswd --htpasswd-file $WORKDIR/htpasswd --user $RUN_USER -- sh 2> /dev/null)\" = piped ]"
+
+run_test "Shell runs in the current directory" "[ \"\$(cd $WORKDIR && printf 's3cret\n' | $PWD/pam-auth exec --backend htpasswd --htpasswd-file htpasswd --user $RUN_USER --shell $WORKDIR/where.sh 2> /dev/null)\" = $WORKDIR ]"
+
+run_test "Login shell starts in the home directory" \
+    "[ \"\$(runas s3cret --user $RUN_USER --shell $WORKDIR/where.sh --login)\" = \"$(getent passwd $RUN_USER | cut -d: -f6)\" ]"
+
+run_test "System backend checks the shadow file" \
+    "printf 'anything\n' | ./pam-auth exec --user $RUN_USER -- true 2>&1 | grep -q 'Checking /etc/shadow'"
+
+run_test "Locked system account is refused" "printf 'anything\n' | ./pam-auth exec --user $RUN_USER -- true" 1
+
+run_test "--real-pam without libpam still checks the shadow file" \
+    "printf 'anything\n' | ./pam-auth --real-pam exec --user $RUN_USER -- true" 1
+
+run_test "Unknown user is refused" "runas s3cret --user no-such-user -- true" 1
+
+run_test "Alternate root is refused" "runas s3cret --root $WORKDIR --user $RUN_USER -- true" 1
+
+run_test "Sessions need the PAM build" \
+    "printf 's3cret\n' | ./pam-auth exec --backend htpasswd --htpasswd-file $WORKDIR/htpasswd --session --user $RUN_USER -- true 2>&1 | grep -q 'tags pam'"
+
+cp pam-auth "$WORKDIR/pam-auth"
+run_test "Other users cannot switch" \
+    "printf 's3cret\n' | setpriv --reuid=$RUN_USER --regid=$(id -g $RUN_USER) --clear-groups $WORKDIR/pam-auth exec --backend htpasswd --htpasswd-file $WORKDIR/htpasswd --user root -- true 2>&1 | grep -q 'must run as root'"
+
+# A setuid copy is started by the target user, who controls every flag
+chmod 4755 "$WORKDIR/pam-auth"
+run_test "Setuid exec refuses other backends" \
+    "printf 's3cret\n' | setpriv --reuid=$RUN_USER --regid=$(id -g $RUN_USER) --clear-groups $WORKDIR/pam-auth exec --backend htpasswd --user root -- touch $WORKDIR/ran 2>&1 | grep -q 'only uses the system backend'"
+
+run_test "Setuid exec refuses file flags" \
+    "printf 's3cret\n' | setpriv --reuid=$RUN_USER --regid=$(id -g $RUN_USER) --clear-groups $WORKDIR/pam-auth --audit-log $WORKDIR/audit exec --user root -- touch $WORKDIR/ran 2>&1 | grep -q 'does not accept --audit-log'"
+
+run_test "Setuid exec checks the shadow file" \
+    "printf 'anything\n' | setpriv --reuid=$RUN_USER --regid=$(id -g $RUN_USER) --clear-groups $WORKDIR/pam-auth --real-pam exec --user root -- touch $WORKDIR/ran" 1
+
+run_test "Refused setuid commands do not run" "[ ! -e $WORKDIR/ran ] && [ ! -e $WORKDIR/audit ]"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,180 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Login History Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-history.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+export TZ=UTC
+
+# An image with login records written in the glibc struct utmp and struct
+# lastlog layouts
+ROOT="$WORKDIR/image"
+mkdir -p "$ROOT/etc" "$ROOT/var/log" "$ROOT/var/lib/lastlog"
+HASH=$(openssl passwd -6 -salt histTest s3cret)
+cat > "$ROOT/etc/passwd" <<PASSWD
+root:x:0:0:root:/root:/bin/sh
+histalice:x:1500:1500:History Alice:/home/histalice:/bin/bash
+histbob:x:1501:1501:History Bob:/home/histbob:/bin/bash
+histcarol:x:1502:1502:History Carol:/home/histcarol:/bin/bash
+histdave:x:1503:1503:History Dave:/home/histdave:/bin/bash
+PASSWD
+cat > "$ROOT/etc/group" <<GROUP
+root:x:0:
+histalice:x:1500:
+GROUP
+cat > "$ROOT/etc/shadow" <<SHADOW
+root:*:19000:0:99999:7:::
+histalice:$HASH:19000:0:99999:7:::
+histbob:$HASH:19000:0:99999:7:::
+histcarol:$HASH:19000:0:99999:7:::
+histdave:$HASH:19000:0:99999:7:::
+SHADOW
+
+python3 - "$ROOT" <<'PY'
+import socket, sqlite3, struct, sys
+
+root = sys.argv[1]
+
+def utmp(kind, pid, line, user, host, sec, addr=""):
+    raw = socket.inet_aton(addr) if addr else b""
+    return struct.pack("<hxxi32s4s32s256shhiii4s12x20x", kind, pid, line.encode(),
+                       line[-4:].encode(), user.encode(), host.encode(), 0, 0, 0,
+                       sec, 0, raw)
+
+USER, DEAD, BOOT = 7, 8, 2
+with open(root + "/var/log/wtmp", "wb") as f:
+    f.write(utmp(BOOT, 0, "~", "reboot", "6.1.0", 1700000000))
+    f.write(utmp(USER, 100, "pts/0", "histalice", "203.0.113.5", 1700000100))
+    f.write(utmp(DEAD, 100, "pts/0", "", "", 1700003700))
+    f.write(utmp(USER, 200, "pts/1", "histbob", "", 1700010000, "198.51.100.7"))
+    f.write(utmp(BOOT, 0, "~", "reboot", "6.1.0", 1700020000))
+    f.write(utmp(USER, 300, "tty1", "histalice", "", 1700030000))
+    f.write(b"\0" * 100)  # truncated trailing record
+with open(root + "/var/log/btmp", "wb") as f:
+    f.write(utmp(6, 400, "ssh:notty", "histalice", "192.0.2.1", 1700020500))
+    f.write(utmp(6, 401, "ssh:notty", "histalice", "192.0.2.2", 1700040000))
+    f.write(utmp(6, 402, "ssh:notty", "histalice", "192.0.2.3", 1700050000))
+    f.write(utmp(6, 403, "ssh:notty", "histbob", "192.0.2.4", 1700050100))
+with open(root + "/var/log/lastlog", "wb") as f:
+    f.seek(1502 * 292)
+    f.write(struct.pack("<i32s256s", 1700060000, b"pts/9", b"carol.example.org"))
+db = sqlite3.connect(root + "/var/lib/lastlog/lastlog2.db")
+db.execute("CREATE TABLE Lastlog2(Name TEXT PRIMARY KEY, Time INTEGER, TTY TEXT, RemoteHost TEXT, Service TEXT)")
+db.execute("INSERT INTO Lastlog2 VALUES ('histdave', 1700070000, 'pts/4', 'dave.example.org', 'sshd')")
+db.commit()
+PY
+
+# history ARGS... runs the history command against the image
+history() {
+    ./pam-auth --root "$ROOT" history "$@"
+}
+
+# login USER PASSWORD runs the interactive flow against the image
+login() {
+    printf '%s\n%s\n' "$1" "$2" | ./pam-auth --root "$ROOT"
+}
+
+run_test "History command is registered" "./pam-auth history --help | grep -q -- '--user'"
+
+run_test "Sessions are paired with their logout" \
+    "history | grep -q 'histalice  pts/0  203.0.113.5   2023-11-14 22:15:00  2023-11-14 23:15:00  1h0m0s'"
+
+run_test "Address is shown when no host is recorded" "history | grep -q '198.51.100.7'"
+
+run_test "Session cut by a reboot ended in a crash" "history | grep 'histbob' | grep -q 'crash'"
+
+run_test "Open session is still logged in" "history | grep 'tty1' | grep -q 'still logged in'"
+
+run_test "Newest session is listed first" "history | sed -n 2p | grep -q tty1"
+
+run_test "Sessions are filtered by user" "! history --user histalice | grep -q 'pts/1 '"
+
+run_test "Failed logins are listed from btmp" "history --failed | grep -q '192.0.2.4'"
+
+run_test "Failed logins skip wtmp" "! history --failed | grep -q 'LOGOUT'"
+
+run_test "Limit caps each list" "[ \$(history --failed --limit 2 | grep -c ssh:notty) -eq 2 ]"
+
+run_test "Last login comes from wtmp" \
+    "history --user histalice | grep -q '^Last Login: 2023-11-15 06:33:20 on tty1$'"
+
+run_test "Only failures after the last login are counted" \
+    "history --user histalice | grep -q '^Failed Logins Since: 2 (last 2023-11-15 12:06:40 from 192.0.2.3 on ssh:notty)$'"
+
+run_test "Last login comes from lastlog by UID" \
+    "history --user histcarol | grep -q '^Last Login: 2023-11-15 14:53:20 from carol.example.org on pts/9$'"
+
+run_test "Last login comes from lastlog2" \
+    "history --user histdave | grep -q '^Last Login: 2023-11-15 17:40:00 from dave.example.org on pts/4$'"
+
+run_test "User without records never logged in" "history --user root | grep -q '^Last Login: never$'"
+
+run_test "JSON output has sessions, failures and the summary" \
+    "history --user histalice --json | python3 -c 'import json,sys; h=json.load(sys.stdin); assert len(h[\"sessions\"])==2 and len(h[\"failures\"])==3 and h[\"summary\"][\"last_login\"][\"source\"]==\"wtmp\" and len(h[\"summary\"][\"failures\"])==2'"
+
+run_test "JSON records carry the utmp type and PID" \
+    "history --failed --json | python3 -c 'import json,sys; r=json.load(sys.stdin)[\"failures\"][0]; assert r[\"type\"]==6 and r[\"pid\"]==403'"
+
+run_test "User info shows the last login" \
+    "login histalice s3cret | grep -q '^Last Login: 2023-11-15 06:33:20 on tty1$'"
+
+run_test "User info counts failed logins since" "login histalice s3cret | grep -q '^Failed Logins Since: 2 '"
+
+run_test "Missing wtmp is reported" "./pam-auth --root $WORKDIR history" 1
+
+mkdir -p "$WORKDIR/bare/etc"
+cp "$ROOT/etc/passwd" "$ROOT/etc/group" "$ROOT/etc/shadow" "$WORKDIR/bare/etc"
+run_test "User info works without login records" \
+    "printf 'histalice\ns3cret\n' | ./pam-auth --root $WORKDIR/bare | grep -q '^Last Login: never$'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
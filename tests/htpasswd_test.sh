@@ -0,0 +1,97 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - htpasswd Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+HTFILE=$(mktemp /tmp/pam-auth-htpasswd.XXXXXX)
+rm -f "$HTFILE"
+trap 'rm -f "$HTFILE"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+verify() {
+    printf '%s\n' "$2" | ./pam-auth htpasswd verify "$HTFILE" "$1"
+}
+
+run_test "Create file and add bcrypt user" \
+    "printf 'alicepw\nalicepw\n' | ./pam-auth htpasswd add --create $HTFILE alice"
+run_test "Mismatched confirmation is refused" \
+    "printf 'one\ntwo\n' | ./pam-auth htpasswd add $HTFILE bob" 1
+run_test "bcrypt entry verifies" "verify alice alicepw"
+run_test "Wrong password fails" "verify alice nope" 1
+run_test "Unknown user fails" "verify nobody x" 1
+
+if command -v openssl > /dev/null 2>&1; then
+    echo "sha:{SHA}$(printf 'shapw' | openssl sha1 -binary | base64)" >> "$HTFILE"
+    echo "apr:$(openssl passwd -apr1 aprpw)" >> "$HTFILE"
+    echo "s512:$(openssl passwd -6 s512pw)" >> "$HTFILE"
+    echo "s256:$(openssl passwd -5 s256pw)" >> "$HTFILE"
+
+    run_test "{SHA} entry verifies (file reloaded after external change)" "verify sha shapw"
+    run_test "APR1-MD5 entry verifies" "verify apr aprpw"
+    run_test "SHA-512-crypt entry verifies" "verify s512 s512pw"
+    run_test "SHA-256-crypt entry verifies" "verify s256 s256pw"
+
+    run_test "Backend login upgrades APR1 entry" \
+        "printf 'apr\naprpw\n' | ./pam-auth --backend htpasswd --htpasswd-file $HTFILE --htpasswd-upgrade"
+    run_test "Upgraded entry is bcrypt" "grep -q '^apr:\\\$2y\\\$' $HTFILE"
+    run_test "Upgraded entry still verifies" "verify apr aprpw"
+fi
+
+echo "plain:plainpw" >> "$HTFILE"
+run_test "Plaintext entry is refused by default" "verify plain plainpw" 1
+run_test "Plaintext entry verifies with --htpasswd-plaintext" \
+    "printf 'plainpw\n' | ./pam-auth --htpasswd-plaintext htpasswd verify $HTFILE plain"
+run_test "Plaintext entry rejects a wrong password" \
+    "printf 'nope\n' | ./pam-auth --htpasswd-plaintext htpasswd verify $HTFILE plain" 1
+
+run_test "Delete user" "./pam-auth htpasswd delete $HTFILE alice"
+run_test "Deleted user no longer verifies" "verify alice alicepw" 1
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
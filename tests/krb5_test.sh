@@ -0,0 +1,149 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Kerberos Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+YELLOW='\033[1;33m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+REALM=PAMAUTH.TEST
+KDC_PORT=18888
+SERVICE=host/pam-auth.test
+WORKDIR=$(mktemp -d /tmp/pam-auth-krb5.XXXXXX)
+
+success_count=0
+total_tests=0
+
+# Function to run a test with output capture
+run_test_with_output() {
+    local test_name="$1"
+    local command="$2"
+    local expected_pattern="$3"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    output=$(eval "$command" 2>&1)
+    if echo "$output" | grep -q "$expected_pattern"; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Pattern not found: $expected_pattern)"
+        echo "$output" | tail -5
+    fi
+    echo
+}
+
+for tool in krb5kdc kdb5_util kadmin.local; do
+    if ! command -v $tool > /dev/null 2>&1; then
+        echo -e "${YELLOW}⏭️  SKIPPED: $tool not found - the Kerberos backend was not tested${NC}"
+        echo "💡 Install an MIT KDC to run them:"
+        echo "   Debian/Ubuntu: sudo apt-get install krb5-kdc krb5-admin-server"
+        echo "   Fedora/RHEL:   sudo dnf install krb5-server"
+        rm -rf "$WORKDIR"
+        exit 77
+    fi
+done
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+echo "🏗️ Creating throwaway realm $REALM in $WORKDIR..."
+cat > "$WORKDIR/krb5.conf" <<CONF
+[libdefaults]
+ default_realm = $REALM
+ dns_lookup_kdc = false
+[realms]
+ $REALM = {
+  kdc = 127.0.0.1:$KDC_PORT
+ }
+CONF
+cat > "$WORKDIR/kdc.conf" <<CONF
+[kdcdefaults]
+ kdc_ports = $KDC_PORT
+ kdc_tcp_ports = $KDC_PORT
+[realms]
+ $REALM = {
+  database_name = $WORKDIR/principal
+  key_stash_file = $WORKDIR/stash
+  acl_file = $WORKDIR/kadm5.acl
+  supported_enctypes = aes256-cts-hmac-sha1-96:normal aes128-cts-hmac-sha1-96:normal
+ }
+CONF
+touch "$WORKDIR/kadm5.acl"
+
+export KRB5_CONFIG="$WORKDIR/krb5.conf"
+export KRB5_KDC_PROFILE="$WORKDIR/kdc.conf"
+
+kdb5_util create -s -r $REALM -P masterkey > /dev/null 2>&1 || exit 1
+kadmin.local -q "addprinc -pw alicepw alice" > /dev/null 2>&1
+kadmin.local -q "addprinc -randkey $SERVICE" > /dev/null 2>&1
+kadmin.local -q "ktadd -k $WORKDIR/host.keytab $SERVICE" > /dev/null 2>&1
+# A keytab for the same principal name from a different (spoofed) realm database
+kadmin.local -q "addprinc -randkey $SERVICE-spoof" > /dev/null 2>&1
+
+krb5kdc -n -P "$WORKDIR/kdc.pid" > "$WORKDIR/kdc.log" 2>&1 &
+kdc_pid=$!
+trap 'kill $kdc_pid 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+sleep 1
+echo
+
+krb5_login() {
+    local username="$1" password="$2"
+    shift 2
+    printf '%s\n%s\n' "$username" "$password" | ./pam-auth --backend krb5 \
+        --krb5-config "$WORKDIR/krb5.conf" --krb5-keytab "$WORKDIR/host.keytab" \
+        --krb5-service-principal $SERVICE "$@"
+}
+
+run_test_with_output "TGT obtained and verified with keytab" \
+    "krb5_login alice alicepw" "TGT verified with keytab"
+
+run_test_with_output "Wrong password rejected by KDC" \
+    "krb5_login alice wrong" "Authentication failed"
+
+run_test_with_output "Ticket for a principal missing from the keytab fails verification" \
+    "krb5_login alice alicepw --krb5-service-principal $SERVICE-spoof" "KDC verification failed"
+
+run_test_with_output "Credential cache written" \
+    "krb5_login alice alicepw --krb5-ccache $WORKDIR/krb5cc_%{username}" "Credential cache written"
+
+echo keep > "$WORKDIR/victim"
+ln -s "$WORKDIR/victim" "$WORKDIR/planted"
+run_test_with_output "Credential cache replaces a planted symlink instead of following it" \
+    "krb5_login alice alicepw --krb5-ccache $WORKDIR/planted && grep -qx keep $WORKDIR/victim && [ ! -L $WORKDIR/planted ] && echo intact" "^intact$"
+
+run_test_with_output "Own realm may be named explicitly" \
+    "krb5_login alice@$REALM alicepw" "TGT verified with keytab"
+
+run_test_with_output "Other realms are refused" \
+    "krb5_login alice@OTHER.TEST alicepw" "realm OTHER.TEST is not allowed"
+
+run_test_with_output "Allowed realms reach the KDC" \
+    "krb5_login alice@OTHER.TEST alicepw --krb5-allowed-realm OTHER.TEST" "KDC unreachable"
+
+if command -v klist > /dev/null 2>&1; then
+    run_test_with_output "Credential cache readable by klist" \
+        "klist -c $WORKDIR/krb5cc_alice" "krbtgt/$REALM@$REALM"
+fi
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,140 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Metrics and Health Endpoint Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+RADIUS_PORT=18180
+DOWN_PORT=18181
+UP_PORT=18183
+DEAD_PORT=18189
+METRICS=127.0.0.1:19180
+DOWN_METRICS=127.0.0.1:19181
+UP_METRICS=127.0.0.1:19183
+RADIUS_SECRET=testing123
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-metrics.XXXXXX)
+pids=""
+trap 'kill $pids 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if ! command -v curl > /dev/null 2>&1; then
+    echo "⏭️  SKIPPED: the metrics tests need curl"
+    exit 77
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+DB="$WORKDIR/users.db"
+printf 'alicepw\nalicepw\n' | ./pam-auth --db-file "$DB" db user add alice > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | ./pam-auth --db-file "$DB" db user add bob > /dev/null || exit 1
+./pam-auth --db-file "$DB" db user disable bob > /dev/null || exit 1
+
+# A healthy server answering from the user database
+./pam-auth radius-serve --backend db --db-file "$DB" --listen 127.0.0.1:$RADIUS_PORT \
+    --client 127.0.0.1=$RADIUS_SECRET --metrics-listen $METRICS > "$WORKDIR/server.log" 2>&1 &
+pids="$pids $!"
+# A server whose RADIUS backend is down, with an offline cache
+./pam-auth radius-serve --backend radius --radius-server 127.0.0.1:$DEAD_PORT --radius-secret $RADIUS_SECRET \
+    --radius-timeout 200ms --radius-retries 0 --offline-cache "$WORKDIR/offline.cache" \
+    --listen 127.0.0.1:$DOWN_PORT --client 127.0.0.1=$RADIUS_SECRET --metrics-listen $DOWN_METRICS \
+    > "$WORKDIR/down.log" 2>&1 &
+pids="$pids $!"
+# A server whose RADIUS backend is the healthy server, with an offline cache
+./pam-auth radius-serve --backend radius --radius-server 127.0.0.1:$RADIUS_PORT --radius-secret $RADIUS_SECRET \
+    --radius-timeout 2s --radius-retries 0 --offline-cache "$WORKDIR/up.cache" \
+    --listen 127.0.0.1:$UP_PORT --client 127.0.0.1=$RADIUS_SECRET --metrics-listen $UP_METRICS \
+    > "$WORKDIR/up.log" 2>&1 &
+pids="$pids $!"
+sleep 1
+
+# login PORT USER PASSWORD logs in through radius-serve
+login() {
+    printf '%s\n%s\n' "$2" "$3" | ./pam-auth --backend radius --radius-server 127.0.0.1:$1 \
+        --radius-secret $RADIUS_SECRET --radius-timeout 2s --radius-retries 0
+}
+
+login $RADIUS_PORT alice alicepw > /dev/null
+login $RADIUS_PORT alice wrong > /dev/null
+login $RADIUS_PORT bob bobpw > /dev/null
+login $DOWN_PORT alice alicepw > /dev/null
+curl -s http://$METRICS/metrics > "$WORKDIR/metrics"
+curl -s http://$DOWN_METRICS/metrics > "$WORKDIR/down.metrics"
+
+run_test "/healthz answers" "curl -sf http://$METRICS/healthz | grep -q ok"
+run_test "Successes are counted by backend" \
+    "grep -q 'pam_auth_attempts_total{backend=\"db\",outcome=\"success\",service=\"radius\"} 1' $WORKDIR/metrics"
+run_test "Rejects are counted by backend" \
+    "grep -q 'pam_auth_attempts_total{backend=\"db\",outcome=\"reject\",service=\"radius\"} 2' $WORKDIR/metrics"
+run_test "Latencies are recorded in a histogram" \
+    "grep -q 'pam_auth_attempt_duration_seconds_count{backend=\"db\",service=\"radius\"} 3' $WORKDIR/metrics"
+run_test "Disabled accounts count as lockouts" \
+    "grep -q 'pam_auth_lockouts_total{backend=\"db\",service=\"radius\"} 1' $WORKDIR/metrics"
+run_test "Reachable backends are ready" "curl -sf http://$METRICS/readyz | grep -q '\"ready\":true'"
+run_test "Unreachable backends are not ready" \
+    "[ \$(curl -s -o /dev/null -w '%{http_code}' http://$DOWN_METRICS/readyz) = 503 ]"
+run_test "Readiness names the failing backend" "curl -s http://$DOWN_METRICS/readyz | grep -q 'backend:radius\",\"ok\":false'"
+run_test "RADIUS backends are pinged with Status-Server" "curl -sf http://$UP_METRICS/readyz | grep -q 'backend:radius\",\"ok\":true'"
+run_test "Database backends are pinged" "curl -s http://$METRICS/readyz | grep -q 'backend:db\",\"ok\":true'"
+run_test "Readiness probes do not log in" \
+    "n=\$(grep -c 'PAP request' $WORKDIR/server.log); curl -s http://$UP_METRICS/readyz && curl -s http://$UP_METRICS/readyz && [ \$(grep -c 'PAP request' $WORKDIR/server.log) = \$n ] && [ ! -e $WORKDIR/up.cache ]"
+run_test "Backend errors are counted" \
+    "grep -q 'pam_auth_attempts_total{backend=\"radius\",outcome=\"error\",service=\"radius\"} 1' $WORKDIR/down.metrics"
+run_test "Offline cache misses are counted" \
+    "grep -q 'pam_auth_offline_cache_requests_total{result=\"miss\",service=\"radius\"} 1' $WORKDIR/down.metrics"
+
+# Real PAM exports the transaction pool and checks for its service file
+./pam-auth radius-serve --real-pam --pam-workers 3 --listen 127.0.0.1:$((RADIUS_PORT + 2)) \
+    --client 127.0.0.1=$RADIUS_SECRET --metrics-listen 127.0.0.1:19182 > "$WORKDIR/pam.log" 2>&1 &
+pids="$pids $!"
+sleep 1
+
+run_test "PAM pool metrics are exported with --real-pam" \
+    "curl -s http://127.0.0.1:19182/metrics | grep -q '^pam_auth_pam_pool_queue_size'"
+run_test "Readiness checks real PAM" "curl -s http://127.0.0.1:19182/readyz | grep -q '\"name\":\"pam\"'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
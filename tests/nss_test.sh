@@ -0,0 +1,211 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - NSS Module Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-nss.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [[ "$OSTYPE" != "linux-gnu"* ]] || ! command -v python3 > /dev/null 2>&1 || ! command -v cc > /dev/null 2>&1; then
+    echo "⏭️  SKIPPED: the NSS module tests need Linux with glibc, a C compiler and python3"
+    exit 77
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+echo "Building libnss_pamauth.so.2..."
+cc -Wall -shared -fPIC -Wl,-soname,libnss_pamauth.so.2 -o "$WORKDIR/libnss_pamauth.so.2" cmd/nss_pamauth/nss_pamauth.c || exit 1
+
+DB="$WORKDIR/users.db"
+export PAMAUTH_NSS_CACHE="$WORKDIR/nss"
+pamauth_db() {
+    ./pam-auth --db-file "$DB" db --nss-cache "$PAMAUTH_NSS_CACHE" "$@"
+}
+
+printf 'alicepw\nalicepw\n' | pamauth_db user add alice --name "Alice Example" --group devs,oncall > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | pamauth_db user add bob --group devs --shell /bin/bash --expires 2000-01-02 > /dev/null || exit 1
+
+# The driver dlopens the module and calls one NSS entry point:
+#   pwnam NAME | pwuid UID | grnam NAME | grgid GID | spnam NAME |
+#   pwent | grent | initgroups NAME GID | small-buffer NAME
+# It prints the entry in getent format and exits 2 for NOTFOUND.
+cat > "$WORKDIR/nss_driver.py" <<'PYEOF'
+import ctypes, sys
+
+lib = ctypes.CDLL(sys.argv[1])
+op, args = sys.argv[2], sys.argv[3:]
+SUCCESS, NOTFOUND, TRYAGAIN = 1, 0, -2
+
+class Passwd(ctypes.Structure):
+    _fields_ = [("name", ctypes.c_char_p), ("passwd", ctypes.c_char_p), ("uid", ctypes.c_uint),
+                ("gid", ctypes.c_uint), ("gecos", ctypes.c_char_p), ("dir", ctypes.c_char_p),
+                ("shell", ctypes.c_char_p)]
+
+class Group(ctypes.Structure):
+    _fields_ = [("name", ctypes.c_char_p), ("passwd", ctypes.c_char_p), ("gid", ctypes.c_uint),
+                ("mem", ctypes.POINTER(ctypes.c_char_p))]
+
+class Spwd(ctypes.Structure):
+    _fields_ = [("namp", ctypes.c_char_p), ("pwdp", ctypes.c_char_p), ("lstchg", ctypes.c_long),
+                ("min", ctypes.c_long), ("max", ctypes.c_long), ("warn", ctypes.c_long),
+                ("inact", ctypes.c_long), ("expire", ctypes.c_long), ("flag", ctypes.c_ulong)]
+
+def s(b):
+    return b.decode() if b is not None else ""
+
+def fmt_pw(p):
+    return "%s:%s:%d:%d:%s:%s:%s" % (s(p.name), s(p.passwd), p.uid, p.gid, s(p.gecos), s(p.dir), s(p.shell))
+
+def fmt_gr(g):
+    members, i = [], 0
+    while g.mem[i]:
+        members.append(s(g.mem[i]))
+        i += 1
+    return "%s:%s:%d:%s" % (s(g.name), s(g.passwd), g.gid, ",".join(members))
+
+def fmt_sp(p):
+    expire = "" if p.expire == -1 else str(p.expire)
+    return "%s:%s:%d::::%s" % (s(p.namp), s(p.pwdp), p.lstchg, expire)
+
+def call(fn, key, struct, size=4096):
+    result, buf, err = struct(), ctypes.create_string_buffer(size), ctypes.c_int(0)
+    result.buffer = buf  # the entry points into buf, so keep it alive
+    if key is None:
+        status = fn(ctypes.byref(result), buf, size, ctypes.byref(err))
+    else:
+        status = fn(key, ctypes.byref(result), buf, size, ctypes.byref(err))
+    return status, result, err.value
+
+def finish(status, text):
+    if status == SUCCESS:
+        print(text)
+        sys.exit(0)
+    sys.exit(2 if status == NOTFOUND else 1)
+
+if op == "pwnam":
+    st, r, _ = call(lib._nss_pamauth_getpwnam_r, args[0].encode(), Passwd)
+    finish(st, fmt_pw(r) if st == SUCCESS else "")
+elif op == "pwuid":
+    st, r, _ = call(lib._nss_pamauth_getpwuid_r, ctypes.c_uint(int(args[0])), Passwd)
+    finish(st, fmt_pw(r) if st == SUCCESS else "")
+elif op == "grnam":
+    st, r, _ = call(lib._nss_pamauth_getgrnam_r, args[0].encode(), Group)
+    finish(st, fmt_gr(r) if st == SUCCESS else "")
+elif op == "grgid":
+    st, r, _ = call(lib._nss_pamauth_getgrgid_r, ctypes.c_uint(int(args[0])), Group)
+    finish(st, fmt_gr(r) if st == SUCCESS else "")
+elif op == "spnam":
+    st, r, _ = call(lib._nss_pamauth_getspnam_r, args[0].encode(), Spwd)
+    finish(st, fmt_sp(r) if st == SUCCESS else "")
+elif op in ("pwent", "grent"):
+    kind, struct, show = ("pw", Passwd, fmt_pw) if op == "pwent" else ("gr", Group, fmt_gr)
+    getattr(lib, "_nss_pamauth_set%sent" % kind)(0)
+    while True:
+        st, r, _ = call(getattr(lib, "_nss_pamauth_get%sent_r" % kind), None, struct)
+        if st != SUCCESS:
+            break
+        print(show(r))
+    getattr(lib, "_nss_pamauth_end%sent" % kind)()
+    sys.exit(0 if st == NOTFOUND else 1)
+elif op == "initgroups":
+    start, size = ctypes.c_long(0), ctypes.c_long(1)
+    libc = ctypes.CDLL(None)
+    libc.malloc.restype = ctypes.c_void_p
+    groups = ctypes.c_void_p(libc.malloc(ctypes.sizeof(ctypes.c_uint)))
+    err = ctypes.c_int(0)
+    st = lib._nss_pamauth_initgroups_dyn(args[0].encode(), ctypes.c_uint(int(args[1])), ctypes.byref(start),
+                                         ctypes.byref(size), ctypes.byref(groups), ctypes.c_long(-1),
+                                         ctypes.byref(err))
+    gids = ctypes.cast(groups, ctypes.POINTER(ctypes.c_uint))
+    finish(st, " ".join(str(gids[i]) for i in range(start.value)))
+elif op == "small-buffer":
+    st, _, err = call(lib._nss_pamauth_getpwnam_r, args[0].encode(), Passwd, size=8)
+    sys.exit(0 if st == TRYAGAIN and err == 34 else 1)
+PYEOF
+
+nss() {
+    python3 "$WORKDIR/nss_driver.py" "$WORKDIR/libnss_pamauth.so.2" "$@"
+}
+
+run_test "Missing cache is reported as unavailable" "nss pwnam alice" 1
+run_test "db nss-update writes the cache" "pamauth_db nss-update"
+run_test "getpwnam resolves a database user" "nss pwnam alice | grep -qx 'alice:x:100000:100000:Alice Example:/home/alice:/bin/sh'"
+run_test "getpwuid resolves by UID" "nss pwuid 100001 | grep -q '^bob:x:100001:100001:.*:/bin/bash$'"
+run_test "getpwnam reports unknown users as not found" "nss pwnam nobody" 2
+run_test "getgrnam lists named group members" "nss grnam devs | grep -qx 'devs:x:200000:alice,bob'"
+run_test "getgrgid resolves the private group" "nss grgid 100000 | grep -qx 'alice:x:100000:'"
+run_test "getspnam returns a placeholder password and expiry" "nss spnam bob | grep -qx 'bob:\\*:[0-9]*::::10958'"
+run_test "getpwent enumerates every user" "[ \$(nss pwent | wc -l) -eq 2 ]"
+run_test "getgrent enumerates named and private groups" "[ \$(nss grent | wc -l) -eq 4 ]"
+run_test "initgroups_dyn returns supplementary groups" "nss initgroups alice 100000 | grep -qx '200000 200001'"
+run_test "Too small buffers ask for a retry with ERANGE" "nss small-buffer alice"
+run_test "The module does not link a Go runtime" "! nm -D $WORKDIR/libnss_pamauth.so.2 | grep -q 'runtime\\.'"
+
+# Resolve through glibc itself, as pam-auth's cgo os/user.Lookup does, with
+# an nsswitch.conf naming the module bind mounted in a private mount namespace
+printf 'passwd: files pamauth\ngroup: files pamauth\nshadow: files pamauth\n' > "$WORKDIR/nsswitch.conf"
+with_nsswitch() {
+    unshare -m sh -c 'mount --bind "$0/nsswitch.conf" /etc/nsswitch.conf && LD_LIBRARY_PATH="$0" exec "$@"' "$WORKDIR" "$@"
+}
+
+if with_nsswitch true 2> /dev/null; then
+    run_test "id resolves a database user through nsswitch.conf" "with_nsswitch id alice | grep -q '^uid=100000(alice) gid=100000(alice) groups=.*200000(devs),200001(oncall)'"
+    run_test "pam-auth looks up a database user with os/user" "printf 'alice\nalicepw\n' | with_nsswitch ./pam-auth | grep -q 'User found: alice (UID: 100000, GID: 100000)'"
+    run_test "pam-auth resolves supplementary groups with os/user" "printf 'alice\nalicepw\n' | with_nsswitch ./pam-auth | grep -q '^Groups: .*devs oncall'"
+    run_test "os/user reports unknown users as not found" "printf 'nobody-here\nx\n' | with_nsswitch ./pam-auth | grep -q 'User not found'"
+    namespace_tests=1
+fi
+
+run_test "Disabling a user refreshes the cache" "pamauth_db user disable alice"
+run_test "Disabled users have a locked shadow entry" "nss spnam alice | grep -q '^alice:!:'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ] || exit 1
+if [ -z "$namespace_tests" ]; then
+    echo "⏭️  SKIPPED: the os/user lookups need root to bind mount nsswitch.conf in a private mount namespace"
+    exit 77
+fi
@@ -0,0 +1,130 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Offline Credential Cache Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+RADIUS_PORT=18130
+RADIUS_SECRET=testing123
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-offline.XXXXXX)
+server_pid=""
+trap '[ -n "$server_pid" ] && kill $server_pid 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+HTPASSWD="$WORKDIR/htpasswd"
+CACHE="$WORKDIR/offline.cache"
+AUDIT="$WORKDIR/audit.log"
+printf 'alicepw\nalicepw\n' | ./pam-auth htpasswd add --create "$HTPASSWD" alice > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | ./pam-auth htpasswd add "$HTPASSWD" bob > /dev/null || exit 1
+
+# The local RADIUS stand-in answers from the htpasswd file
+start_server() {
+    ./pam-auth radius-serve --backend htpasswd --htpasswd-file "$HTPASSWD" \
+        --listen 127.0.0.1:$RADIUS_PORT --client 127.0.0.1=$RADIUS_SECRET \
+        --reply '*=Class:staff' > "$WORKDIR/server.log" 2>&1 &
+    server_pid=$!
+    sleep 1
+}
+
+stop_server() {
+    kill $server_pid 2>/dev/null
+    wait $server_pid 2>/dev/null
+    server_pid=""
+}
+
+# login USER PASSWORD [FLAGS...] logs in through the radius backend with the offline cache
+login() {
+    local username="$1" password="$2"
+    shift 2
+    printf '%s\n%s\n' "$username" "$password" | ./pam-auth --backend radius \
+        --radius-server 127.0.0.1:$RADIUS_PORT --radius-secret $RADIUS_SECRET \
+        --radius-timeout 300ms --radius-retries 0 --offline-cache "$CACHE" --audit-log "$AUDIT" "$@"
+}
+
+start_server
+
+run_test "Online login succeeds" "login alice alicepw | grep -q 'authentication successful'"
+run_test "Online login of a second user" "login bob bobpw"
+run_test "Cache key is created private" "[ \$(stat -c %a $CACHE.key) = 600 ]"
+run_test "Cache file is private" "[ \$(stat -c %a $CACHE) = 600 ]"
+run_test "Cache file is encrypted" "! grep -aq -e alice -e argon2id $CACHE"
+run_test "offline list shows cached users" "./pam-auth offline list --offline-cache $CACHE | grep -q '^alice *radius .*usable *staff'"
+run_test "A repeated online login does not rewrite the cache" \
+    "sum=\$(sha256sum < $CACHE) && login alice alicepw && [ \"\$(sha256sum < $CACHE)\" = \"\$sum\" ]"
+run_test "An online reject of another password does not rewrite the cache" \
+    "sum=\$(sha256sum < $CACHE) && ! login alice wrong && [ \"\$(sha256sum < $CACHE)\" = \"\$sum\" ]"
+
+mkdir "$WORKDIR/unwritable"
+run_test "Cache update failures are reported on standard error" \
+    "login alice alicepw --offline-cache $WORKDIR/unwritable --offline-key $CACHE.key 2>&1 > /dev/null | grep -q 'Could not update the offline cache'"
+run_test "Cache update failures stay off standard output" \
+    "! login alice alicepw --offline-cache $WORKDIR/unwritable --offline-key $CACHE.key 2> /dev/null | grep -q 'offline cache'"
+
+stop_server
+
+run_test "Unreachable backend serves cached users" "login alice alicepw | grep -q 'served from the offline cache'"
+run_test "Offline logins keep the cached groups" "login alice alicepw | grep -q 'Groups: staff'"
+run_test "Offline logins are audited as offline" "grep -q '\"offline\":\"true\"' $AUDIT"
+run_test "Offline login with a wrong password fails" "login alice wrong" 1
+run_test "Offline login of an uncached user fails" "login carol carolpw" 1
+run_test "Maximum offline age is enforced" "login alice alicepw --offline-max-age 1ms" 1
+run_test "Cache is unreadable with another key" "login alice alicepw --offline-key $WORKDIR/other.key" 1
+
+start_server
+printf 'newpw\nnewpw\n' | ./pam-auth htpasswd add "$HTPASSWD" alice > /dev/null
+
+run_test "Online reject of the cached password" "login alice alicepw" 1
+run_test "Changed password drops the cache entry" "! ./pam-auth offline list --offline-cache $CACHE | grep -q '^alice '"
+run_test "offline forget removes a user" "./pam-auth offline forget bob --offline-cache $CACHE"
+run_test "offline forget of an uncached user fails" "./pam-auth offline forget bob --offline-cache $CACHE" 1
+
+stop_server
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,86 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - pam_exec Helper Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-exec.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+DB="$WORKDIR/users.db"
+AUDIT="$WORKDIR/audit.log"
+printf 'alicepw\nalicepw\n' | ./pam-auth --db-file "$DB" db user add alice > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | ./pam-auth --db-file "$DB" db user add bob --expires 2000-01-01 > /dev/null || exit 1
+printf 'carolpw\ncarolpw\n' | ./pam-auth htpasswd add --create "$WORKDIR/htpasswd" carol > /dev/null || exit 1
+
+# pam_exec TYPE USER [TOKEN] mimics pam_exec.so expose_authtok, which writes
+# the NUL terminated token to stdin
+pam_exec() {
+    printf '%s\0' "$3" | PAM_TYPE="$1" PAM_USER="$2" PAM_SERVICE=sshd PAM_RHOST=192.0.2.10 PAM_TTY=ssh \
+        ./pam-auth pam-exec --backend db,htpasswd --db-file "$DB" --htpasswd-file "$WORKDIR/htpasswd" --audit-log "$AUDIT"
+}
+
+run_test "auth accepts the right token" "pam_exec auth alice alicepw"
+run_test "auth rejects a wrong token" "pam_exec auth alice wrong" 1
+run_test "auth falls through the backend chain" "pam_exec auth carol carolpw"
+run_test "auth refuses an expired account" "pam_exec auth bob bobpw" 1
+run_test "account accepts an active account" "pam_exec account alice"
+run_test "account refuses an expired account" "pam_exec account bob" 1
+run_test "account defers users only known to backends without account data" "pam_exec account carol"
+run_test "open_session succeeds" "pam_exec open_session alice"
+run_test "close_session succeeds" "pam_exec close_session alice"
+run_test "Unsupported PAM_TYPE is an error" "pam_exec password alice" 2
+run_test "Missing PAM environment is an error" "./pam-auth pam-exec --backend db --db-file $DB" 2
+run_test "System backend is refused" "printf 'x\0' | PAM_TYPE=auth PAM_USER=alice ./pam-auth pam-exec" 2
+run_test "Authentication is audited with service and host" \
+    "grep '\"event\":\"authentication\"' $AUDIT | grep -q '\"service\":\"pam:sshd\".*\"remote\":\"192.0.2.10\"'"
+run_test "Sessions are audited" "grep -q '\"message\":\"session closed\"' $AUDIT"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
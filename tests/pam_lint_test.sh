@@ -0,0 +1,201 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - PAM Lint Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-lint.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+PAMD="$WORKDIR/pam.d"
+MODULES="$WORKDIR/security"
+mkdir -p "$PAMD" "$MODULES"
+for module in pam_unix.so pam_permit.so pam_deny.so pam_totp.so pam_rootok.so pam_mysql.so; do
+    touch "$MODULES/$module"
+done
+
+# lint SERVICE... runs pam lint against the test directory
+lint() {
+    ./pam-auth pam lint --dir "$PAMD" --module-dir "$MODULES" "$@"
+}
+
+# service NAME writes a service file from stdin
+service() {
+    cat > "$PAMD/$1"
+}
+
+service common-auth <<'PAM'
+# Debian style: jump over the fallback deny on success
+auth	[success=1 default=ignore]	pam_unix.so nullok
+auth	requisite			pam_deny.so
+auth	required			pam_permit.so
+PAM
+
+service good <<'PAM'
+@include common-auth
+account required pam_unix.so
+# A rule continued on the next line, with a bracketed argument
+session optional pam_mysql.so \
+    [query=select user from users where name='%u']
+-session optional pam_systemd.so
+PAM
+
+service missing-module <<'PAM'
+auth required pam_unix.so
+auth required pam_nothere.so
+PAM
+
+service syntax <<'PAM'
+auth required pam_unix.so
+auth mandatory pam_unix.so
+auth [success=ok default=explode] pam_unix.so
+logon required pam_unix.so
+auth [success=1 pam_unix.so
+PAM
+
+service missing-include <<'PAM'
+auth include nothere
+PAM
+
+service cycle-a <<'PAM'
+auth include cycle-b
+PAM
+service cycle-b <<'PAM'
+auth include cycle-a
+PAM
+
+service unreachable <<'PAM'
+auth required pam_unix.so
+auth requisite pam_deny.so
+auth required pam_totp.so
+account [success=1 default=die] pam_permit.so
+account required pam_unix.so
+account required pam_permit.so
+PAM
+
+service done-after-failure <<'PAM'
+auth required pam_unix.so
+auth sufficient pam_permit.so
+auth required pam_totp.so
+PAM
+
+service bypass <<'PAM'
+auth sufficient pam_unix.so
+auth required pam_totp.so
+auth required pam_deny.so
+PAM
+
+service su <<'PAM'
+auth sufficient pam_rootok.so
+auth required pam_unix.so
+PAM
+
+service sshd <<'PAM'
+@include common-auth
+PAM
+
+run_test "Lint help" "./pam-auth pam lint --help | grep -q -- '--remote'"
+
+run_test "Valid service passes" "lint good | grep -q 'No problems found in 1 services'"
+
+run_test "Debian style include passes" "lint common-auth | grep -q 'No problems'"
+
+run_test "Missing module fails" "lint missing-module" 1
+
+run_test "Missing module is reported at its line" \
+    "lint missing-module | grep -q \"^$PAMD/missing-module:2: error: module pam_nothere.so not found\""
+
+run_test "Dashed rule may name a missing module" "! lint good | grep -q pam_systemd"
+
+run_test "Unknown control is a syntax error" "lint syntax | grep -q \"syntax:2: error: unknown control \\\"mandatory\\\"\""
+
+run_test "Unknown action is a syntax error" "lint syntax | grep -q 'syntax:3: error: .*unknown action \"explode\"'"
+
+run_test "Unknown type is a syntax error" "lint syntax | grep -q 'syntax:4: error: unknown type \"logon\"'"
+
+run_test "Unterminated bracket is a syntax error" "lint syntax | grep -q 'syntax:5: error: unterminated \['"
+
+run_test "Missing include fails" "lint missing-include | grep -q 'missing-include:1: error: include nothere: .* not found'"
+
+run_test "Include cycle fails" "lint cycle-a | grep -q 'error: include cycle: cycle-a -> cycle-b -> cycle-a'"
+
+run_test "Rule after requisite pam_deny.so is unreachable" \
+    "lint unreachable | grep -q 'unreachable:3: warning: unreachable'"
+
+run_test "Rule jumped over is unreachable" \
+    "lint unreachable | grep -q 'unreachable:5: warning: unreachable' && ! lint unreachable | grep -q 'unreachable:6:'"
+
+run_test "Done after a possible failure does not end the stack" \
+    "! lint done-after-failure | grep -q unreachable"
+
+run_test "Warnings alone exit 0" "lint unreachable"
+
+run_test "Required rule after sufficient is flagged" \
+    "lint bypass | grep -q 'bypass:2: warning: required pam_totp.so is skipped whenever sufficient pam_unix.so (.*bypass:1) succeeds'"
+
+run_test "Fallback pam_deny.so after sufficient is fine" "! lint bypass | grep -q 'bypass:3'"
+
+run_test "pam_rootok.so short-circuit is fine" "lint su | grep -q 'No problems'"
+
+run_test "nullok on a remote service is flagged in the included file" \
+    "lint sshd | grep -q 'common-auth:2: warning: nullok on remote service sshd'"
+
+run_test "nullok on a local service is fine" "! lint good | grep -q nullok"
+
+run_test "Remote services are configurable" "! lint sshd --remote telnet | grep -q nullok"
+
+run_test "Without SERVICE every file is linted" \
+    "lint > $WORKDIR/all.out; grep -q 'missing-module:2' $WORKDIR/all.out && grep -q 'bypass:2' $WORKDIR/all.out"
+
+run_test "Shared files are reported once" "lint | grep -c 'common-auth:2:' | grep -qx 1"
+
+run_test "Unknown service fails" "lint nothere" 1
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,165 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - PAM Module Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-module.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+# pam_wrapper lets an unprivileged test load a private PAM service directory
+if [ -z "$PAM_WRAPPER_LIB" ]; then
+    for candidate in /usr/lib/x86_64-linux-gnu/libpam_wrapper.so /usr/lib/aarch64-linux-gnu/libpam_wrapper.so \
+        /usr/lib64/libpam_wrapper.so /usr/lib/libpam_wrapper.so /usr/local/lib/libpam_wrapper.so; do
+        if [ -f "$candidate" ]; then
+            PAM_WRAPPER_LIB="$candidate"
+            break
+        fi
+    done
+fi
+if [ -z "$PAM_WRAPPER_LIB" ] || ! command -v python3 > /dev/null 2>&1; then
+    echo "⏭️  SKIPPED: pam_wrapper or python3 not found - the PAM module was not tested"
+    echo "💡 Install pam_wrapper (e.g. apt install libpam-wrapper) or set PAM_WRAPPER_LIB"
+    exit 77
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+echo "Building pam_pamauth.so..."
+if ! go build -tags pam -buildmode=c-shared -o "$WORKDIR/pam_pamauth.so" ./cmd/pam_pamauth; then
+    echo "⏭️  SKIPPED: could not build the PAM module (are the libpam headers installed?)"
+    exit 77
+fi
+
+DB="$WORKDIR/users.db"
+printf 'alicepw\nalicepw\n' | ./pam-auth --db-file "$DB" db user add alice > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | ./pam-auth --db-file "$DB" db user add bob --expires 2000-01-01 > /dev/null || exit 1
+printf 'carolpw\ncarolpw\n' | ./pam-auth --db-file "$DB" db user add carol > /dev/null || exit 1
+./pam-auth --db-file "$DB" db user disable carol > /dev/null || exit 1
+
+mkdir -p "$WORKDIR/pam.d"
+cat > "$WORKDIR/pam.d/pamauth" <<EOF
+auth    required $WORKDIR/pam_pamauth.so backend=db db-file=$DB audit-log=$WORKDIR/audit.log
+account required $WORKDIR/pam_pamauth.so backend=db db_file=$DB
+EOF
+cat > "$WORKDIR/pam.d/pamauth-broken" <<EOF
+auth    required $WORKDIR/pam_pamauth.so backend=db no-such-option=1
+EOF
+
+# The driver runs one PAM transaction: SERVICE USER auth|acct ANSWER...
+# Conversation messages are printed as "conv STYLE: TEXT" and the PAM
+# return code becomes the exit status.
+cat > "$WORKDIR/pam_driver.py" <<'PYEOF'
+import ctypes, sys
+
+libpam = ctypes.CDLL("libpam.so.0")
+libc = ctypes.CDLL(None)
+libc.calloc.restype = ctypes.c_void_p
+libc.strdup.restype = ctypes.c_void_p
+libc.strdup.argtypes = [ctypes.c_char_p]
+
+class PamMessage(ctypes.Structure):
+    _fields_ = [("msg_style", ctypes.c_int), ("msg", ctypes.c_char_p)]
+
+class PamResponse(ctypes.Structure):
+    _fields_ = [("resp", ctypes.c_void_p), ("resp_retcode", ctypes.c_int)]
+
+CONV_FUNC = ctypes.CFUNCTYPE(ctypes.c_int, ctypes.c_int,
+                             ctypes.POINTER(ctypes.POINTER(PamMessage)),
+                             ctypes.POINTER(ctypes.POINTER(PamResponse)), ctypes.c_void_p)
+
+class PamConv(ctypes.Structure):
+    _fields_ = [("conv", CONV_FUNC), ("appdata_ptr", ctypes.c_void_p)]
+
+service, user, op = sys.argv[1], sys.argv[2], sys.argv[3]
+answers = [a.encode() for a in sys.argv[4:]]
+
+def conversation(n, messages, response, _):
+    replies = ctypes.cast(libc.calloc(n, ctypes.sizeof(PamResponse)), ctypes.POINTER(PamResponse))
+    for i in range(n):
+        msg = messages[i].contents
+        print("conv %d: %s" % (msg.msg_style, msg.msg.decode()))
+        if msg.msg_style in (1, 2):
+            replies[i].resp = libc.strdup(answers.pop(0) if answers else b"")
+    response[0] = replies
+    return 0
+
+conv = PamConv(CONV_FUNC(conversation), None)
+handle = ctypes.c_void_p()
+ret = libpam.pam_start(service.encode(), user.encode(), ctypes.byref(conv), ctypes.byref(handle))
+if ret == 0:
+    ret = libpam.pam_authenticate(handle, 0) if op == "auth" else libpam.pam_acct_mgmt(handle, 0)
+libpam.pam_end(handle, ret)
+print("result %d" % ret)
+sys.exit(ret)
+PYEOF
+
+pam_driver() {
+    LD_PRELOAD="$PAM_WRAPPER_LIB" PAM_WRAPPER=1 PAM_WRAPPER_SERVICE_DIR="$WORKDIR/pam.d" \
+        python3 "$WORKDIR/pam_driver.py" "$@"
+}
+
+# PAM return codes
+PAM_SERVICE_ERR=3
+PAM_PERM_DENIED=6
+PAM_AUTH_ERR=7
+PAM_USER_UNKNOWN=10
+PAM_ACCT_EXPIRED=13
+
+run_test "pam_sm_authenticate accepts the right password" "pam_driver pamauth alice auth alicepw"
+run_test "pam_sm_authenticate rejects a wrong password" "pam_driver pamauth alice auth wrong" $PAM_AUTH_ERR
+run_test "pam_sm_authenticate rejects an unknown user" "pam_driver pamauth nobody auth x" $PAM_AUTH_ERR
+run_test "Password prompt goes through the conversation" "pam_driver pamauth alice auth alicepw | grep -q 'conv 1: Password:'"
+run_test "pam_sm_acct_mgmt accepts an active account" "pam_driver pamauth alice acct"
+run_test "pam_sm_acct_mgmt refuses an expired account" "pam_driver pamauth bob acct" $PAM_ACCT_EXPIRED
+run_test "Expiry message goes through the conversation" "pam_driver pamauth bob acct | grep -q 'conv 3: Your account has expired'"
+run_test "pam_sm_acct_mgmt refuses a disabled account" "pam_driver pamauth carol acct" $PAM_PERM_DENIED
+run_test "pam_sm_acct_mgmt reports unknown users" "pam_driver pamauth nobody acct" $PAM_USER_UNKNOWN
+run_test "Unknown module arguments are a service error" "pam_driver pamauth-broken alice auth alicepw" $PAM_SERVICE_ERR
+run_test "Logins are written to the audit log" "grep -q '\"service\":\"pam:pamauth\"' $WORKDIR/audit.log"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,181 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - PAM Simulate Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-simulate.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+PAMD="$WORKDIR/pam.d"
+mkdir -p "$PAMD"
+
+# simulate SERVICE FLAGS... evaluates a stack from the test directory
+simulate() {
+    ./pam-auth pam simulate --dir "$PAMD" "$@"
+}
+
+# service NAME writes a service file from stdin
+service() {
+    cat > "$PAMD/$1"
+}
+
+service common-auth <<'PAM'
+auth	[success=1 default=ignore]	pam_unix.so nullok
+auth	requisite			pam_deny.so
+auth	required			pam_permit.so
+PAM
+
+service login <<'PAM'
+auth required pam_faildelay.so
+@include common-auth
+account required pam_unix.so
+PAM
+
+service bypass <<'PAM'
+auth sufficient pam_unix.so
+auth required pam_faillock.so
+PAM
+
+service failed-first <<'PAM'
+auth required pam_securetty.so
+auth sufficient pam_unix.so
+auth required pam_env.so
+PAM
+
+service mfa <<'PAM'
+auth requisite pam_unix.so
+auth [success=done default=die] pam_totp.so
+PAM
+
+service parent <<'PAM'
+auth substack mfa
+auth required pam_env.so
+PAM
+
+service jump-substack <<'PAM'
+auth [success=1 default=ignore] pam_rootok.so
+auth substack mfa
+auth required pam_permit.so
+PAM
+
+service bad-jump <<'PAM'
+auth [success=2 default=ignore] pam_unix.so
+auth required pam_permit.so
+PAM
+
+service reset <<'PAM'
+auth required pam_permit.so
+auth substack reset-sub
+PAM
+service reset-sub <<'PAM'
+auth required pam_unix.so
+auth [success=reset default=bad] pam_recover.so
+PAM
+
+service undefined <<'PAM'
+auth [success=ok] pam_unix.so
+PAM
+
+service broken <<'PAM'
+auth mandatory pam_unix.so
+PAM
+
+run_test "Simulate help" "./pam-auth pam simulate --help | grep -q -- '--results'"
+
+run_test "Successful password jumps over pam_deny" \
+    "simulate login > $WORKDIR/login.out && grep -q 'Decision: success' $WORKDIR/login.out && grep -q 'common-auth:2 .*pam_deny.so *- *skipped' $WORKDIR/login.out"
+
+run_test "Trace shows jump counts" "grep -q 'common-auth:1 .*pam_unix.so *success *1$' $WORKDIR/login.out"
+
+run_test "Wrong password reaches pam_deny" \
+    "simulate login --results pam_unix=auth_err | grep -q 'Decision: auth_err'"
+
+run_test "Results accept the .so suffix" \
+    "simulate bypass --results pam_unix.so=auth_err,pam_faillock.so=maxtries | grep -q 'Decision: maxtries'"
+
+run_test "Account stack with --type" \
+    "simulate login --type account --results pam_unix=acct_expired | grep -q 'Decision: acct_expired'"
+
+run_test "Sufficient success skips a failing required module" \
+    "simulate bypass --results pam_unix=success,pam_faillock=auth_err | grep -q 'Decision: success'"
+
+run_test "Done does not override an earlier failure" \
+    "simulate failed-first --results pam_securetty=auth_err > $WORKDIR/failed.out && grep -q 'Decision: auth_err' $WORKDIR/failed.out && grep -q 'pam_env.so *success *ok' $WORKDIR/failed.out"
+
+run_test "Die in a substack ends only the substack" \
+    "simulate parent --results pam_totp=auth_err > $WORKDIR/parent.out && grep -q 'Decision: auth_err' $WORKDIR/parent.out && grep -q 'pam_env.so *success *ok' $WORKDIR/parent.out"
+
+run_test "A jump counts a substack as one module" \
+    "simulate jump-substack > $WORKDIR/jump.out && grep -q 'pam_totp.so *- *skipped' $WORKDIR/jump.out && grep -q 'pam_permit.so *success *ok' $WORKDIR/jump.out"
+
+run_test "Jump past the end fails" \
+    "simulate bad-jump | grep -q 'Decision: perm_denied'"
+
+run_test "Reset restores the state from the start of the substack" \
+    "simulate reset --results pam_unix=auth_err | grep -q 'Decision: success'"
+
+run_test "Values without an action fail" \
+    "simulate undefined --results pam_unix=auth_err | grep -q 'Decision: perm_denied'"
+
+run_test "Default result applies to unlisted modules" \
+    "simulate login --default-result auth_err | grep -q 'Decision: auth_err'"
+
+run_test "Expected decision exits 0" "simulate login --expect success"
+
+run_test "Unexpected decision exits 1" "simulate login --results pam_unix=auth_err --expect success" 1
+
+run_test "Unknown return value is rejected" "simulate login --results pam_unix=great" 1
+
+run_test "Syntax errors are reported" "simulate broken 2>&1 | grep -q 'broken:1: error: unknown control'"
+
+run_test "Unknown service fails" "simulate nothere" 1
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,162 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Password Policy Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-policy.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+DB="$WORKDIR/users.db"
+HTFILE="$WORKDIR/htpasswd"
+
+# Two new-password prompts answered with $1
+twice() {
+    printf '%s\n%s\n' "$1" "$1"
+}
+
+# A sorted SHA-1 breached list: full hashes with counts, and 10 character prefixes
+python3 - "$WORKDIR" <<'PY'
+import hashlib, sys
+words = ["correct horse battery staple", "Summer-Breeze-42"] + ["leaked%d" % i for i in range(2000)]
+hashes = sorted((hashlib.sha1(w.encode()).hexdigest().upper(), i + 3) for i, w in enumerate(words))
+open(sys.argv[1] + "/breached.txt", "w").write("".join("%s:%d\n" % h for h in hashes))
+open(sys.argv[1] + "/prefixes.txt", "w").write("".join("%s\n" % h[:10] for h, _ in hashes))
+PY
+printf 'zebra\nmarmalade\n' > "$WORKDIR/words.txt"
+
+run_test "Strong password meets the NIST preset" \
+    "printf 'violet tractor hums\n' | ./pam-auth policy check"
+
+run_test "Short password is rejected" \
+    "printf 'short\n' | ./pam-auth policy check | grep -q 'at least 8 characters (has 5)'" 0
+
+run_test "Rejected password exits with status 1" \
+    "printf 'short\n' | ./pam-auth policy check" 1
+
+run_test "Overlong password is rejected" \
+    "printf '%080d\n' 7 | ./pam-auth policy check | grep -q 'at most 64 characters (has 80)'"
+
+run_test "Common password is rejected" \
+    "printf 'qwertyuiop\n' | ./pam-auth policy check | grep -q 'common password'"
+
+run_test "l33t variant of a common word is rejected" \
+    "printf 'P@ssw0rd2024!\n' | ./pam-auth policy check | grep -q 'common word \"password\"'"
+
+run_test "Word list entries are rejected" \
+    "printf 'Marmalade99\n' | ./pam-auth policy check --dictionary $WORKDIR/words.txt | grep -q 'common word \"marmalade\"'"
+
+run_test "User name is rejected" \
+    "printf 'hello-alice-2024\n' | ./pam-auth policy check alice | grep -q 'user name \"alice\"'"
+
+run_test "Reversed user name is rejected" \
+    "printf 'ecila-and-friends\n' | ./pam-auth policy check alice | grep -q 'reversed'"
+
+run_test "Full name is rejected" \
+    "printf 'GoSmithGo123\n' | ./pam-auth policy check alice --gecos 'Alice Smith' | grep -q '\"Smith\" from the full name'"
+
+run_test "Full name is read from the passwd file" \
+    "mkdir -p $WORKDIR/root/etc && echo 'bob:x:1000:1000:Robert Paulson,,,:/home/bob:/bin/sh' > $WORKDIR/root/etc/passwd && printf 'paulson-rules\n' | ./pam-auth --root $WORKDIR/root policy check bob | grep -q 'Paulson'"
+
+run_test "Character classes are off in the NIST preset" \
+    "printf 'alllowercaseletters\n' | ./pam-auth policy check"
+
+run_test "Character classes can be required" \
+    "printf 'alllowercaseletters\n' | ./pam-auth policy check --min-classes 3 | grep -q 'uses 1: lowercase'"
+
+run_test "Breached password is rejected with its count" \
+    "printf 'Summer-Breeze-42\n' | ./pam-auth policy check --breached $WORKDIR/breached.txt | grep -q 'seen 4 times'"
+
+run_test "Every breached list entry is found" \
+    "for i in 0 1 999 1998 1999; do printf 'leaked%s\n' \$i | ./pam-auth policy check --policy none --breached $WORKDIR/breached.txt && exit 1; done; true"
+
+run_test "Password absent from the breached list passes" \
+    "printf 'violet tractor hums\n' | ./pam-auth policy check --breached $WORKDIR/breached.txt"
+
+run_test "Truncated prefix lists are searched" \
+    "printf 'correct horse battery staple\n' | ./pam-auth policy check --policy none --breached $WORKDIR/prefixes.txt" 1
+
+run_test "Missing breached list is an error" \
+    "printf 'violet tractor hums\n' | ./pam-auth policy check --breached $WORKDIR/missing.txt" 1
+
+run_test "Unknown preset is refused" \
+    "printf 'violet tractor hums\n' | ./pam-auth policy check --policy strict" 1
+
+run_test "JSON verdict lists the violations" \
+    "printf 'short\n' | ./pam-auth policy check --json > $WORKDIR/verdict.json; python3 -c 'import json,sys; v=json.load(open(sys.argv[1])); assert not v[\"accepted\"] and v[\"violations\"][0][\"rule\"] == \"length\"' $WORKDIR/verdict.json"
+
+run_test "Stores accept any password without --policy" \
+    "twice shortpw | ./pam-auth --db-file $DB db user add carol --name 'Carol Jones'"
+
+run_test "db user add enforces the policy" \
+    "twice jonesy-2024 | ./pam-auth --db-file $DB db user add dave --name 'Dave Jones' --policy nist" 1
+
+run_test "Rejected user is not created" \
+    "./pam-auth --db-file $DB db user show dave" 1
+
+run_test "db user passwd accepts a policy compliant password" \
+    "twice 'violet tractor hums' | ./pam-auth --db-file $DB db user passwd carol --policy nist --history 3"
+
+run_test "db user passwd refuses the current password" \
+    "twice 'violet tractor hums' | ./pam-auth --db-file $DB db user passwd carol --history 3 | grep -q 'differ from the current password'"
+
+run_test "db user passwd refuses a recent password" \
+    "twice 'amber kettle sings' | ./pam-auth --db-file $DB db user passwd carol --history 3 && twice 'violet tractor hums' | ./pam-auth --db-file $DB db user passwd carol --history 3" 1
+
+run_test "Older passwords are allowed again past the history" \
+    "twice shortpw | ./pam-auth --db-file $DB db user passwd carol --history 2"
+
+run_test "htpasswd add enforces the policy" \
+    "twice alicepw | ./pam-auth htpasswd add --create $HTFILE alice --policy nist" 1
+
+run_test "htpasswd add refuses the current password" \
+    "twice 'violet tractor hums' | ./pam-auth htpasswd add --create $HTFILE alice --history 1 && twice 'violet tractor hums' | ./pam-auth htpasswd add $HTFILE alice --history 1 | grep -q 'differ from the current password'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,310 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - RADIUS Server Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-radius-serve.XXXXXX)
+server_pids=()
+trap 'kill "${server_pids[@]}" 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+RADIUS_PORT=18140
+METRICS_PORT=19140
+RADIUS_SECRET=testing123
+TOTP_SECRET=JBSWY3DPEHPK3PXP
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+# Function to run a test with output capture
+run_test_with_output() {
+    local test_name="$1"
+    local command="$2"
+    local expected_pattern="$3"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    output=$(eval "$command" 2>&1)
+    if echo "$output" | grep -q "$expected_pattern"; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Pattern not found: $expected_pattern)"
+    fi
+    echo
+}
+
+if ! command -v python3 > /dev/null 2>&1; then
+    echo "⚠️  The RADIUS server tests need python3 to send raw requests"
+    exit 1
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+# A minimal RFC 2865 client: prints the response code and attributes, and
+# exits 3 when no valid response arrives
+cat > "$WORKDIR/radclient.py" << 'PYEOF'
+import argparse, base64, hashlib, hmac, os, socket, struct, sys, time
+
+CODES = {1: "Access-Request", 2: "Access-Accept", 3: "Access-Reject", 11: "Access-Challenge", 12: "Status-Server"}
+USER_NAME, USER_PASSWORD, CHAP_PASSWORD, REPLY_MESSAGE, VENDOR_SPECIFIC, STATE, CLASS, CHAP_CHALLENGE, MESSAGE_AUTHENTICATOR = 1, 2, 3, 18, 26, 24, 25, 60, 80
+MICROSOFT, MS_CHAP_CHALLENGE, MS_CHAP2_RESPONSE = 311, 11, 25
+
+parser = argparse.ArgumentParser()
+parser.add_argument("port", type=int)
+parser.add_argument("secret")
+parser.add_argument("--status", action="store_true")
+parser.add_argument("--user")
+parser.add_argument("--pap")
+parser.add_argument("--chap")
+parser.add_argument("--totp")
+parser.add_argument("--print-totp")
+parser.add_argument("--mschapv2-random", action="store_true")
+parser.add_argument("--state")
+parser.add_argument("--msg-auth", choices=["good", "bad", "none"], default="good")
+args = parser.parse_args()
+secret = args.secret.encode()
+
+def attr(kind, value):
+    return struct.pack("BB", kind, len(value) + 2) + value
+
+def microsoft(kind, value):
+    return attr(VENDOR_SPECIFIC, struct.pack(">I", MICROSOFT) + attr(kind, value))
+
+def pap(password, authenticator):
+    data = password.encode()
+    data += b"\0" * (16 - len(data) % 16 if len(data) % 16 or not data else 0)
+    out, last = b"", authenticator
+    for i in range(0, len(data), 16):
+        block = bytes(a ^ b for a, b in zip(data[i:i + 16], hashlib.md5(secret + last).digest()))
+        out, last = out + block, block
+    return out
+
+def totp(seed):
+    key = base64.b32decode(seed)
+    mac = hmac.new(key, struct.pack(">Q", int(time.time()) // 30), hashlib.sha1).digest()
+    offset = mac[-1] & 0x0F
+    return "%06d" % ((struct.unpack(">I", mac[offset:offset + 4])[0] & 0x7FFFFFFF) % 1000000)
+
+if args.print_totp:
+    print(totp(args.print_totp))
+    sys.exit(0)
+
+identifier, authenticator = os.urandom(1)[0], os.urandom(16)
+attrs = b""
+if args.user:
+    attrs += attr(USER_NAME, args.user.encode())
+if args.pap is not None:
+    attrs += attr(USER_PASSWORD, pap(args.pap, authenticator))
+if args.totp:
+    attrs += attr(USER_PASSWORD, pap(totp(args.totp), authenticator))
+if args.chap is not None:
+    chap_id, challenge = os.urandom(1)[0], os.urandom(16)
+    attrs += attr(CHAP_PASSWORD, bytes([chap_id]) + hashlib.md5(bytes([chap_id]) + args.chap.encode() + challenge).digest())
+    attrs += attr(CHAP_CHALLENGE, challenge)
+if args.mschapv2_random:
+    # A random MS-CHAPv2 exchange, whose response never matches
+    attrs += microsoft(MS_CHAP_CHALLENGE, os.urandom(16))
+    attrs += microsoft(MS_CHAP2_RESPONSE, bytes([1, 0]) + os.urandom(48))
+if args.state:
+    attrs += attr(STATE, bytes.fromhex(args.state))
+
+code = 12 if args.status else 1
+if args.msg_auth != "none":
+    attrs += attr(MESSAGE_AUTHENTICATOR, b"\0" * 16)
+packet = struct.pack(">BBH", code, identifier, 20 + len(attrs)) + authenticator + attrs
+if args.msg_auth != "none":
+    mac = hmac.new(secret, packet, hashlib.md5).digest()
+    if args.msg_auth == "bad":
+        mac = bytes([mac[0] ^ 0xFF]) + mac[1:]
+    packet = packet[:-16] + mac
+
+sock = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+sock.settimeout(1)
+sock.sendto(packet, ("127.0.0.1", args.port))
+try:
+    response = sock.recv(4096)
+except socket.timeout:
+    print("no response")
+    sys.exit(3)
+
+rcode, rid, length = struct.unpack(">BBH", response[:4])
+expected = hashlib.md5(response[:4] + authenticator + response[20:length] + secret).digest()
+if rid != identifier or response[4:20] != expected:
+    print("invalid response authenticator")
+    sys.exit(3)
+print(CODES.get(rcode, rcode))
+body, i = response[20:length], 0
+while i < len(body):
+    kind, size = body[i], body[i + 1]
+    value = body[i + 2:i + size]
+    if kind == STATE:
+        print("State: " + value.hex())
+    elif kind in (REPLY_MESSAGE, CLASS):
+        print("%s: %s" % ("Reply-Message" if kind == REPLY_MESSAGE else "Class", value.decode()))
+    i += size
+PYEOF
+
+radclient() {
+    python3 "$WORKDIR/radclient.py" "$@"
+}
+
+# radius_rejects prints the rejects the first server counted for the radius backend
+radius_rejects() {
+    curl -s http://127.0.0.1:$METRICS_PORT/metrics | sed -n 's/^pam_auth_attempts_total{backend="radius",outcome="reject",service="radius"} //p'
+}
+
+# wait_for_server waits until a server answers Status-Server
+wait_for_server() {
+    local port="$1"
+    for _ in $(seq 20); do
+        radclient "$port" $RADIUS_SECRET --status > /dev/null 2>&1 && return 0
+        sleep 0.25
+    done
+    echo "⚠️  RADIUS server on port $port did not start"
+}
+
+HTFILE="$WORKDIR/users"
+printf 'alicepw\nalicepw\n' | ./pam-auth htpasswd add --create "$HTFILE" alice > /dev/null
+echo "carol:carolpw" >> "$HTFILE"
+printf 'davepw\ndavepw\n' | ./pam-auth htpasswd add "$HTFILE" dave > /dev/null
+echo "dave:$TOTP_SECRET" > "$WORKDIR/totp"
+
+echo "📡 Starting RADIUS servers on 127.0.0.1:$RADIUS_PORT-$((RADIUS_PORT + 3))..."
+./pam-auth radius-serve --listen 127.0.0.1:$RADIUS_PORT --client 127.0.0.1=$RADIUS_SECRET \
+    --backend htpasswd --htpasswd-file "$HTFILE" --htpasswd-plaintext --metrics-listen 127.0.0.1:$METRICS_PORT \
+    --reply '*=Class:radius-users' > "$WORKDIR/server.log" 2>&1 &
+server_pids+=($!)
+./pam-auth radius-serve --listen 127.0.0.1:$((RADIUS_PORT + 1)) --client 127.0.0.1=$RADIUS_SECRET \
+    --backend htpasswd --htpasswd-file "$HTFILE" \
+    --require-message-authenticator --totp-secrets "$WORKDIR/totp" > "$WORKDIR/strict.log" 2>&1 &
+server_pids+=($!)
+./pam-auth radius-serve --listen 127.0.0.1:$((RADIUS_PORT + 2)) --client 127.0.0.1=$RADIUS_SECRET > "$WORKDIR/system.log" 2>&1 &
+server_pids+=($!)
+./pam-auth --real-pam radius-serve --listen 127.0.0.1:$((RADIUS_PORT + 3)) --client 127.0.0.1=$RADIUS_SECRET > "$WORKDIR/realpam.log" 2>&1 &
+server_pids+=($!)
+for port in $RADIUS_PORT $((RADIUS_PORT + 1)) $((RADIUS_PORT + 2)) $((RADIUS_PORT + 3)); do
+    wait_for_server $port
+done
+echo
+
+echo "📋 PAP"
+run_test_with_output "PAP login with the right password is accepted" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --pap alicepw" "^Access-Accept"
+run_test_with_output "Reply rules add attributes to the Access-Accept" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --pap alicepw" "^Class: radius-users"
+run_test_with_output "PAP login with a wrong password is rejected" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --pap wrong" "^Access-Reject"
+run_test_with_output "PAP login of an unknown user is rejected" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user nobody --pap alicepw" "^Access-Reject"
+run_test_with_output "System backend checks the shadow file, not just the user" \
+    "radclient $((RADIUS_PORT + 2)) $RADIUS_SECRET --user root --pap anything-at-all" "^Access-Reject"
+run_test_with_output "--real-pam without libpam still checks the shadow file" \
+    "radclient $((RADIUS_PORT + 3)) $RADIUS_SECRET --user root --pap anything-at-all" "^Access-Reject"
+
+echo "📋 CHAP"
+run_test_with_output "CHAP login against a plaintext entry is accepted" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user carol --chap carolpw" "^Access-Accept"
+run_test_with_output "CHAP login with a wrong password is rejected" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user carol --chap wrong" "^Access-Reject"
+run_test_with_output "CHAP login against a bcrypt entry is rejected" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --chap alicepw" "^Access-Reject"
+run_test_with_output "CHAP login without --htpasswd-plaintext is rejected" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user carol --chap carolpw" "^Access-Reject"
+run_test_with_output "Plaintext entries are refused for PAP without --htpasswd-plaintext" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user carol --pap carolpw" "^Access-Reject"
+
+echo "📋 MS-CHAPv2"
+rejects_before=$(radius_rejects)
+run_test_with_output "MS-CHAPv2 response mismatch is rejected" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user carol --mschapv2-random" "^Access-Reject"
+run_test "MS-CHAPv2 attempts are counted like PAP and CHAP" "[ \"\$(radius_rejects)\" = $((${rejects_before:-0} + 1)) ]"
+
+echo "📋 Message-Authenticator"
+run_test "Request with a bad Message-Authenticator is dropped" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --pap alicepw --msg-auth bad" 3
+run_test_with_output "Request without a Message-Authenticator is answered by default" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --user alice --pap alicepw --msg-auth none" "^Access-Accept"
+run_test "Request without one is dropped with --require-message-authenticator" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user alice --pap alicepw --msg-auth none" 3
+run_test "Request signed with another secret is dropped" \
+    "radclient $RADIUS_PORT wrong-secret --user alice --pap alicepw" 3
+run_test_with_output "Status-Server is answered with Access-Accept" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --status" "^Access-Accept"
+run_test "Status-Server without a Message-Authenticator is dropped" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --status --msg-auth none" 3
+run_test "Status-Server with a bad Message-Authenticator is dropped" \
+    "radclient $RADIUS_PORT $RADIUS_SECRET --status --msg-auth bad" 3
+
+echo "📋 Access-Challenge"
+run_test_with_output "Enrolled user gets an Access-Challenge after the password" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap davepw" "^Access-Challenge"
+run_test_with_output "Access-Challenge carries the prompt" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap davepw" "^Reply-Message: Verification code"
+run_test_with_output "Enrolled user with a wrong password gets no challenge" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap wrong" "^Access-Reject"
+
+challenge_state() {
+    radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap davepw | sed -n 's/^State: //p'
+}
+CODE=$(radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --print-totp $TOTP_SECRET)
+run_test_with_output "Correct verification code is accepted" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap $CODE --state \$(challenge_state)" "^Access-Accept"
+run_test_with_output "Verification code cannot be replayed" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap $CODE --state \$(challenge_state)" "^Access-Reject"
+run_test_with_output "Wrong verification code is rejected" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --pap 000000 --state \$(challenge_state)" "^Access-Reject"
+run_test_with_output "Unknown challenge State is rejected" \
+    "radclient $((RADIUS_PORT + 1)) $RADIUS_SECRET --user dave --totp $TOTP_SECRET --state 00112233445566778899aabbccddeeff" "^Access-Reject"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+if [ $success_count -eq $total_tests ]; then
+    echo -e "${GREEN}🎉 ALL RADIUS SERVER TESTS PASSED! 🎉${NC}"
+    exit 0
+else
+    echo "📝 Server logs:"
+    tail -n 20 "$WORKDIR"/*.log
+    exit 1
+fi
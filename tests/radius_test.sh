@@ -0,0 +1,153 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - RADIUS Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+success_count=0
+total_tests=0
+
+RADIUS_PORT=18120
+RADIUS_SECRET=testing123
+WORKDIR=$(mktemp -d /tmp/pam-auth-radius.XXXXXX)
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+# Function to run a test with output capture
+run_test_with_output() {
+    local test_name="$1"
+    local command="$2"
+    local expected_pattern="$3"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    output=$(eval "$command" 2>&1)
+    if echo "$output" | grep -q "$expected_pattern"; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Pattern not found: $expected_pattern)"
+    fi
+    echo
+}
+
+if ! command -v python3 > /dev/null 2>&1; then
+    echo "⚠️  The RADIUS tests need python3 for the legacy server stand-in"
+    exit 1
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+# The stand-in checks passwords against an htpasswd file with a known entry
+HTFILE="$WORKDIR/users"
+printf 'secret\nsecret\n' | ./pam-auth htpasswd add --create "$HTFILE" radtest > /dev/null
+
+echo "📡 Starting local RADIUS stand-in on 127.0.0.1:$RADIUS_PORT..."
+./pam-auth radius-serve --listen 127.0.0.1:$RADIUS_PORT \
+    --backend htpasswd --htpasswd-file "$HTFILE" \
+    --client 127.0.0.1=$RADIUS_SECRET \
+    --reply '*=Class:radius-users' > "$WORKDIR/server.log" 2>&1 &
+server_pid=$!
+
+# A legacy server that answers every request with an Access-Accept but no
+# Message-Authenticator, as a BlastRADIUS forgery would
+LEGACY_PORT=$((RADIUS_PORT + 1))
+python3 - $LEGACY_PORT $RADIUS_SECRET << 'PYEOF' > /dev/null 2>&1 &
+import hashlib, socket, struct, sys
+sock = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+sock.bind(("127.0.0.1", int(sys.argv[1])))
+while True:
+    request, peer = sock.recvfrom(4096)
+    header = struct.pack(">BBH", 2, request[1], 20)
+    sock.sendto(header + hashlib.md5(header + request[4:20] + sys.argv[2].encode()).digest(), peer)
+PYEOF
+legacy_pid=$!
+trap 'kill $server_pid $legacy_pid 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+sleep 1
+echo
+
+radius_login() {
+    local username="$1"
+    shift
+    printf '%s\nsecret\n' "$username" | ./pam-auth --backend radius --radius-secret $RADIUS_SECRET "$@"
+}
+
+run_test_with_output "Access-Accept for existing user" \
+    "radius_login radtest --radius-server 127.0.0.1:$RADIUS_PORT" "authentication successful"
+
+run_test_with_output "Class attribute mapped to group" \
+    "radius_login radtest --radius-server 127.0.0.1:$RADIUS_PORT" "Groups: radius-users"
+
+run_test_with_output "Class attribute renamed with --radius-group" \
+    "radius_login radtest --radius-server 127.0.0.1:$RADIUS_PORT --radius-group radius-users=staff" "Groups: staff"
+
+run_test "Access-Reject for a wrong password" \
+    "printf 'radtest\nwrong\n' | ./pam-auth --backend radius --radius-secret $RADIUS_SECRET --radius-server 127.0.0.1:$RADIUS_PORT" 1
+
+run_test "Access-Reject for unknown user" \
+    "radius_login no-such-user-$$ --radius-server 127.0.0.1:$RADIUS_PORT" 1
+
+run_test_with_output "Failover to second server" \
+    "radius_login radtest --radius-server 127.0.0.1:$((RADIUS_PORT + 9)) --radius-server 127.0.0.1:$RADIUS_PORT --radius-timeout 500ms" "authentication successful"
+
+run_test "Wrong shared secret is rejected" \
+    "radius_login radtest --radius-server 127.0.0.1:$RADIUS_PORT --radius-secret wrong --radius-timeout 300ms --radius-retries 1" 1
+
+run_test "Response without a Message-Authenticator is refused" \
+    "radius_login radtest --radius-server 127.0.0.1:$LEGACY_PORT --radius-timeout 300ms --radius-retries 0" 1
+
+run_test_with_output "Unsigned response is reported" \
+    "radius_login radtest --radius-server 127.0.0.1:$LEGACY_PORT --radius-timeout 300ms --radius-retries 0" "response without Message-Authenticator"
+
+run_test "Missing --radius-server is a configuration error" \
+    "radius_login radtest" 1
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+if [ $success_count -eq $total_tests ]; then
+    echo -e "${GREEN}🎉 ALL RADIUS TESTS PASSED! 🎉${NC}"
+    exit 0
+else
+    echo "📝 Server log:"
+    tail -n 20 "$WORKDIR/server.log"
+    exit 1
+fi
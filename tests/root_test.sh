@@ -0,0 +1,161 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Alternate Root Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-root.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+# A minimal image with accounts the host does not have
+ROOT="$WORKDIR/image"
+mkdir -p "$ROOT/etc/pam.d" "$ROOT/lib/security"
+HASH=$(openssl passwd -6 -salt rootTest s3cret)
+cat > "$ROOT/etc/passwd" <<PASSWD
+root:x:0:0:root:/root:/bin/sh
+imgalice:x:1500:1500:Image Alice,,,:/home/imgalice:/bin/bash
+imgbob:x:1501:1501::/home/imgbob:/bin/sh
+imgcarol:x:1502:1502::/home/imgcarol:/bin/sh
+imgdave:x:1503:1503::/home/imgdave:/bin/sh
+PASSWD
+cat > "$ROOT/etc/group" <<GROUP
+root:x:0:
+imgalice:x:1500:
+imgbob:x:1501:
+imgdevs:x:2000:imgbob,imgalice
+GROUP
+cat > "$ROOT/etc/shadow" <<SHADOW
+root:*:19000:0:99999:7:::
+imgalice:$HASH:19000:0:99999:7:::
+imgbob:!$HASH:19000:0:99999:7:::
+imgcarol::19000:0:99999:7:::
+imgdave:\$gy\$j9T\$abcdefghijklmnop\$abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG:19000:0:99999:7:::
+SHADOW
+cat > "$ROOT/etc/nsswitch.conf" <<NSS
+passwd: files
+group: files
+shadow: files
+NSS
+cat > "$ROOT/etc/pam.d/login" <<PAM
+auth required pam_unix.so
+auth required pam_image_only.so
+PAM
+touch "$ROOT/lib/security/pam_unix.so"
+
+# login USER PASSWORD runs the interactive flow against the image
+login() {
+    printf '%s\n%s\n' "$1" "$2" | ./pam-auth --root "$ROOT"
+}
+
+run_test "Root flag is global" "./pam-auth doctor --help | grep -q -- '--root'"
+
+run_test "Users are looked up in the image" "login imgalice s3cret | grep -q 'User found: imgalice (UID: 1500, GID: 1500)'"
+
+run_test "Password is verified against the image shadow file" \
+    "login imgalice s3cret | grep -q 'Password authentication successful for user: imgalice'"
+
+run_test "Wrong password is refused" "login imgalice wrong | grep -q 'Authentication failed for user: imgalice'"
+
+run_test "getent is not used" "! login imgalice s3cret | grep -q getent"
+
+run_test "Groups come from the image group file" "login imgalice s3cret | grep -q '^Groups: imgalice imgdevs$'"
+
+run_test "GECOS name is shown" "login imgalice s3cret | grep -q '^Name: Image Alice$'"
+
+run_test "Locked account is refused" "login imgbob s3cret | grep -q 'Account is locked'"
+
+run_test "Account without a password is refused" "login imgcarol '' | grep -q 'Account has no password'"
+
+run_test "Unsupported hash is reported" "login imgdave s3cret | grep -q 'Cannot verify unknown password hash'"
+
+run_test "Host users are not visible" "login daemon s3cret | grep -q 'User not found'"
+
+run_test "Real PAM is refused with --root" "./pam-auth --root $ROOT --real-pam" 1
+run_test "Kerberos credential caches are refused with --root" "./pam-auth --root $ROOT --backend krb5 --krb5-ccache $WORKDIR/cc" 1
+
+run_test "Missing root directory is refused" "./pam-auth --root $WORKDIR/nothere doctor" 1
+
+run_test "pam lint reads pam.d and modules in the image" \
+    "./pam-auth --root $ROOT pam lint login | grep -q \"$ROOT/etc/pam.d/login:2: error: module pam_image_only.so not found\""
+
+run_test "pam lint finds modules installed in the image" "! ./pam-auth --root $ROOT pam lint login | grep -q 'pam_unix.so not found'"
+
+run_test "pam simulate reads the image" \
+    "./pam-auth --root $ROOT pam simulate login --results pam_image_only=auth_err | grep -q 'Decision: auth_err'"
+
+run_test "doctor inspects the image" \
+    "./pam-auth --root $ROOT doctor --json | python3 -c 'import json,sys; c={c[\"name\"]: c for c in json.load(sys.stdin)[\"checks\"]}; assert \"$ROOT/etc/shadow\" in c[\"shadow\"][\"detail\"] and c[\"nsswitch\"][\"status\"] == \"ok\" and c[\"pam service login\"][\"status\"] == \"fail\"'"
+
+# An image whose files are reached through symlinks that would lead to the
+# host's files if they were followed outside the image
+LINKED="$WORKDIR/linked"
+mkdir -p "$LINKED/etc" "$LINKED/data" "$LINKED/run" "$LINKED/var/log"
+cp "$ROOT/etc/passwd" "$ROOT/etc/group" "$ROOT/etc/shadow" "$LINKED/data/"
+ln -s /data/passwd "$LINKED/etc/passwd"
+ln -s ../../../../../../../../data/group "$LINKED/etc/group"
+ln -s /data/shadow "$LINKED/etc/shadow"
+ln -s /etc/nsswitch.conf "$LINKED/etc/nsswitch.conf"
+ln -s /run "$LINKED/var/run"
+: > "$LINKED/run/utmp"
+printf 's3cret\ns3cret\n' | ./pam-auth htpasswd add --create "$WORKDIR/htpasswd" imgalice > /dev/null || exit 1
+
+run_test "Absolute symlinks resolve inside the image" \
+    "printf 'imgalice\ns3cret\n' | ./pam-auth --root $LINKED | grep -q 'Password authentication successful for user: imgalice'"
+
+run_test "Relative symlinks cannot climb out of the image" \
+    "printf 'imgalice\ns3cret\n' | ./pam-auth --root $LINKED | grep -q '^Groups: imgalice imgdevs$'"
+
+run_test "Symlink loops are not followed to the host" \
+    "./pam-auth --root $LINKED doctor --json | python3 -c 'import json,sys; c={c[\"name\"]: c for c in json.load(sys.stdin)[\"checks\"]}; assert c[\"nsswitch\"][\"status\"] != \"ok\"'"
+
+run_test "--utmp writes the image's utmp behind /var/run -> /run" \
+    "PAM_USER=imgalice PAM_TYPE=open_session PAM_TTY=/dev/pts/7 PAM_SERVICE=sshd ./pam-auth --root $LINKED --utmp pam-exec --backend htpasswd --htpasswd-file $WORKDIR/htpasswd && [ \$(stat -c %s $LINKED/run/utmp) -eq 384 ]"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
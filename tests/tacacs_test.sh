@@ -0,0 +1,276 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - TACACS+ Server Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-tacacs.XXXXXX)
+server_pids=()
+trap 'kill "${server_pids[@]}" 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+TACACS_PORT=14900
+TACACS_KEY=tac-key
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+# Function to run a test with output capture
+run_test_with_output() {
+    local test_name="$1"
+    local command="$2"
+    local expected_pattern="$3"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    output=$(eval "$command" 2>&1)
+    if echo "$output" | grep -q "$expected_pattern"; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Pattern not found: $expected_pattern)"
+    fi
+    echo
+}
+
+if ! command -v python3 > /dev/null 2>&1; then
+    echo "⚠️  The TACACS+ tests need python3 to act as the network device"
+    exit 1
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+# A minimal RFC 8907 client: prints the reply status (and authorization
+# arguments), and exits 3 when the server sends no reply
+cat > "$WORKDIR/tacclient.py" << 'PYEOF'
+import hashlib, os, socket, struct, sys
+
+AUTHEN, AUTHOR, ACCT = 1, 2, 3
+AUTHEN_STATUS = {1: "PASS", 2: "FAIL", 3: "GETDATA", 4: "GETUSER", 5: "GETPASS", 6: "RESTART", 7: "ERROR"}
+AUTHOR_STATUS = {1: "PASS_ADD", 2: "PASS_REPL", 0x10: "FAIL", 0x11: "ERROR"}
+ACCT_STATUS = {1: "SUCCESS", 2: "ERROR"}
+ACCT_FLAGS = {"start": 0x02, "stop": 0x04, "watchdog": 0x08}
+
+args = sys.argv[1:]
+unencrypted = "--unencrypted" in args
+args = [a for a in args if a != "--unencrypted"]
+port, key, op, rest = int(args[0]), args[1].encode(), args[2], args[3:]
+session = struct.unpack(">I", os.urandom(4))[0]
+
+def pad(version, seq, length):
+    out, last = b"", b""
+    while len(out) < length:
+        last = hashlib.md5(struct.pack(">I", session) + key + bytes([version, seq]) + last).digest()
+        out += last
+    return out[:length]
+
+def send(sock, kind, seq, body, version=0xc0):
+    flags = 0x01 if unencrypted else 0
+    if not unencrypted:
+        body = bytes(a ^ b for a, b in zip(body, pad(version, seq, len(body))))
+    sock.sendall(struct.pack(">BBBBII", version, kind, seq, flags, session, len(body)) + body)
+
+def recv(sock):
+    header = b""
+    while len(header) < 12:
+        chunk = sock.recv(12 - len(header))
+        if not chunk:
+            print("no reply")
+            sys.exit(3)
+        header += chunk
+    version, kind, seq, flags, _, length = struct.unpack(">BBBBII", header)
+    body = b""
+    while len(body) < length:
+        body += sock.recv(length - len(body))
+    if not flags & 0x01:
+        body = bytes(a ^ b for a, b in zip(body, pad(version, seq, len(body))))
+    return seq, body
+
+def strings(*values):
+    return b"".join(v.encode() for v in values)
+
+def continue_body(message, flags=0):
+    return struct.pack(">HHB", len(message), 0, flags) + message.encode()
+
+sock = socket.create_connection(("127.0.0.1", port), timeout=5)
+if op in ("pap", "ascii"):
+    user, password = rest
+    if op == "pap":
+        body = struct.pack("8B", 1, 1, 2, 1, len(user), 3, 9, len(password)) + strings(user, "tty", "127.0.0.1", password)
+        send(sock, AUTHEN, 1, body, version=0xc1)
+        seq, reply = recv(sock)
+    else:
+        body = struct.pack("8B", 1, 1, 1, 1, 0, 3, 9, 0) + strings("tty", "127.0.0.1")
+        send(sock, AUTHEN, 1, body)
+        for answer in (user, password):
+            seq, reply = recv(sock)
+            print("prompt: %s %s" % (AUTHEN_STATUS.get(reply[0]), reply[6:6 + struct.unpack(">H", reply[2:4])[0]].decode()))
+            send(sock, AUTHEN, seq + 1, continue_body(answer))
+        seq, reply = recv(sock)
+    print(AUTHEN_STATUS.get(reply[0], reply[0]))
+elif op in ("author", "acct"):
+    if op == "acct":
+        flags, rest = ACCT_FLAGS[rest[0]], rest[1:]
+    user, priv, avpairs = rest[0], int(rest[1]), rest[2:]
+    fields = struct.pack("8B", 6, priv, 1, 1, len(user), 3, 9, len(avpairs)) + bytes(len(a) for a in avpairs)
+    body = fields + strings(user, "tty", "127.0.0.1", *avpairs)
+    if op == "acct":
+        body = bytes([flags]) + body
+    send(sock, AUTHOR if op == "author" else ACCT, 1, body)
+    seq, reply = recv(sock)
+    if op == "author":
+        count, msg_len, data_len = reply[1], *struct.unpack(">HH", reply[2:6])
+        lengths, offset = reply[6:6 + count], 6 + count + msg_len + data_len
+        print(AUTHOR_STATUS.get(reply[0], reply[0]))
+        for n in lengths:
+            print(reply[offset:offset + n].decode())
+            offset += n
+    else:
+        print(ACCT_STATUS.get(reply[4], reply[4]))
+PYEOF
+
+tacclient() {
+    python3 "$WORKDIR/tacclient.py" "$@"
+}
+
+# wait_for_server waits until a server accepts connections
+wait_for_server() {
+    local port="$1"
+    for _ in $(seq 20); do
+        (echo > /dev/tcp/127.0.0.1/$port) 2> /dev/null && return 0
+        sleep 0.25
+    done
+    echo "⚠️  TACACS+ server on port $port did not start"
+}
+
+DB="$WORKDIR/users.db"
+AUDIT="$WORKDIR/audit.jsonl"
+printf 'alicepw\nalicepw\n' | ./pam-auth --db-file "$DB" db user add alice --group netadmins > /dev/null
+printf 'bobpw\nbobpw\n' | ./pam-auth --db-file "$DB" db user add bob > /dev/null
+printf 'carolpw\ncarolpw\n' | ./pam-auth --db-file "$DB" db user add carol --group helpdesk > /dev/null
+
+echo "🖧 Starting TACACS+ servers on 127.0.0.1:$TACACS_PORT-$((TACACS_PORT + 3))..."
+./pam-auth tacacs-serve --listen 127.0.0.1:$TACACS_PORT --client 127.0.0.1=$TACACS_KEY \
+    --backend db --db-file "$DB" --priv-lvl netadmins=15 --priv-lvl helpdesk=7 \
+    --audit-log "$AUDIT" > "$WORKDIR/server.log" 2>&1 &
+server_pids+=($!)
+./pam-auth tacacs-serve --listen 127.0.0.1:$((TACACS_PORT + 1)) --client 127.0.0.1=$TACACS_KEY \
+    --backend db --db-file "$DB" --priv-lvl netadmins=15 --default-priv-lvl 1 > "$WORKDIR/default.log" 2>&1 &
+server_pids+=($!)
+./pam-auth tacacs-serve --listen 127.0.0.1:$((TACACS_PORT + 2)) --client 127.0.0.1=$TACACS_KEY > "$WORKDIR/system.log" 2>&1 &
+server_pids+=($!)
+./pam-auth --real-pam tacacs-serve --listen 127.0.0.1:$((TACACS_PORT + 3)) --client 127.0.0.1=$TACACS_KEY > "$WORKDIR/realpam.log" 2>&1 &
+server_pids+=($!)
+for port in $TACACS_PORT $((TACACS_PORT + 1)) $((TACACS_PORT + 2)) $((TACACS_PORT + 3)); do
+    wait_for_server $port
+done
+echo
+
+echo "📋 Authentication"
+run_test_with_output "PAP login with the right password passes" \
+    "tacclient $TACACS_PORT $TACACS_KEY pap alice alicepw" "^PASS$"
+run_test_with_output "PAP login with a wrong password fails" \
+    "tacclient $TACACS_PORT $TACACS_KEY pap alice wrong" "^FAIL$"
+run_test_with_output "PAP login of an unknown user fails" \
+    "tacclient $TACACS_PORT $TACACS_KEY pap nobody alicepw" "^FAIL$"
+run_test_with_output "ASCII login asks for the username" \
+    "tacclient $TACACS_PORT $TACACS_KEY ascii alice alicepw" "^prompt: GETUSER Username:"
+run_test_with_output "ASCII login asks for the password" \
+    "tacclient $TACACS_PORT $TACACS_KEY ascii alice alicepw" "^prompt: GETPASS Password:"
+run_test_with_output "ASCII login with the right password passes" \
+    "tacclient $TACACS_PORT $TACACS_KEY ascii alice alicepw" "^PASS$"
+run_test_with_output "ASCII login with a wrong password fails" \
+    "tacclient $TACACS_PORT $TACACS_KEY ascii alice wrong" "^FAIL$"
+run_test_with_output "System backend checks the shadow file, not just the user" \
+    "tacclient $((TACACS_PORT + 2)) $TACACS_KEY pap root anything-at-all" "^FAIL$"
+run_test_with_output "--real-pam without libpam still checks the shadow file" \
+    "tacclient $((TACACS_PORT + 3)) $TACACS_KEY pap root anything-at-all" "^FAIL$"
+run_test "Packets obfuscated with another key do not log in" \
+    "tacclient $TACACS_PORT wrong-key pap alice alicepw | grep -qx PASS" 1
+run_test "Unobfuscated packets are refused" \
+    "tacclient $TACACS_PORT $TACACS_KEY pap alice alicepw --unencrypted" 3
+run_test "Failed logins are audited" \
+    "grep '\"event\":\"authentication\"' $AUDIT | grep '\"user\":\"alice\"' | grep -q '\"outcome\":\"failure\"'"
+run_test "Successful logins are audited" \
+    "grep '\"event\":\"authentication\"' $AUDIT | grep '\"user\":\"alice\"' | grep -q '\"outcome\":\"success\"'"
+
+echo "📋 Authorization"
+run_test_with_output "Shell authorization grants the group's privilege level" \
+    "tacclient $TACACS_PORT $TACACS_KEY author alice 1 service=shell cmd=" "^priv-lvl=15$"
+run_test_with_output "Highest mapped level is granted" \
+    "tacclient $TACACS_PORT $TACACS_KEY author carol 7 service=shell cmd=" "^priv-lvl=7$"
+run_test_with_output "Requests above the user's level are denied" \
+    "tacclient $TACACS_PORT $TACACS_KEY author carol 15 service=shell cmd=" "^FAIL$"
+run_test_with_output "Users without a mapped group are denied by default" \
+    "tacclient $TACACS_PORT $TACACS_KEY author bob 0 service=shell cmd=" "^FAIL$"
+run_test_with_output "Unknown users are denied by default" \
+    "tacclient $TACACS_PORT $TACACS_KEY author nobody 0 service=shell cmd=" "^FAIL$"
+run_test_with_output "--default-priv-lvl does not cover unknown users" \
+    "tacclient $((TACACS_PORT + 1)) $TACACS_KEY author nobody 0 service=shell cmd=" "^FAIL$"
+run_test_with_output "--default-priv-lvl does not cover users who never logged in" \
+    "tacclient $((TACACS_PORT + 1)) $TACACS_KEY author bob 0 service=shell cmd=" "^FAIL$"
+run_test_with_output "--default-priv-lvl applies once the user logged in" \
+    "tacclient $((TACACS_PORT + 1)) $TACACS_KEY pap bob bobpw && tacclient $((TACACS_PORT + 1)) $TACACS_KEY author bob 0 service=shell cmd=" "^priv-lvl=1$"
+run_test "Denied authorization is audited" \
+    "grep '\"event\":\"authorization\"' $AUDIT | grep '\"user\":\"bob\"' | grep -q '\"outcome\":\"failure\"'"
+
+echo "📋 Accounting"
+run_test_with_output "Accounting start is stored" \
+    "tacclient $TACACS_PORT $TACACS_KEY acct start alice 15 task_id=42 service=shell" "^SUCCESS$"
+run_test_with_output "Accounting stop is stored" \
+    "tacclient $TACACS_PORT $TACACS_KEY acct stop alice 15 task_id=42 service=shell elapsed_time=5" "^SUCCESS$"
+run_test "Accounting start reaches the audit log" \
+    "grep '\"event\":\"accounting\"' $AUDIT | grep '\"outcome\":\"start\"' | grep -q '\"task_id\":\"42\"'"
+run_test "Accounting stop reaches the audit log" \
+    "grep '\"event\":\"accounting\"' $AUDIT | grep '\"outcome\":\"stop\"' | grep -q '\"elapsed_time\":\"5\"'"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+if [ $success_count -eq $total_tests ]; then
+    echo -e "${GREEN}🎉 ALL TACACS+ TESTS PASSED! 🎉${NC}"
+    exit 0
+else
+    echo "📝 Server logs:"
+    tail -n 20 "$WORKDIR"/*.log
+    exit 1
+fi
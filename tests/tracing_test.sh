@@ -0,0 +1,162 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - OpenTelemetry Tracing Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+COLLECTOR_PORT=18190
+RADIUS_PORT=18191
+RADIUS_SECRET=testing123
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-tracing.XXXXXX)
+pids=""
+trap 'kill $pids 2>/dev/null; rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if ! command -v python3 > /dev/null 2>&1; then
+    echo "⏭️  SKIPPED: the tracing tests need python3 for a stand-in OTLP collector"
+    exit 77
+fi
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+SPANS="$WORKDIR/spans"
+touch "$SPANS"
+
+# A stand-in OTLP/HTTP collector appending every export to $SPANS
+python3 -c "
+from http.server import BaseHTTPRequestHandler, HTTPServer
+class Collector(BaseHTTPRequestHandler):
+    def do_POST(self):
+        body = self.rfile.read(int(self.headers['Content-Length']))
+        with open('$SPANS', 'ab') as f:
+            f.write(self.path.encode() + b'\n' + body + b'\n')
+        self.send_response(200)
+        self.send_header('Content-Type', 'application/x-protobuf')
+        self.send_header('Content-Length', '0')
+        self.end_headers()
+    def log_message(self, *args):
+        pass
+HTTPServer(('127.0.0.1', $COLLECTOR_PORT), Collector).serve_forever()
+" &
+pids="$pids $!"
+sleep 1
+
+HTPASSWD="$WORKDIR/htpasswd"
+DB="$WORKDIR/users.db"
+printf 'alicepw\nalicepw\n' | ./pam-auth htpasswd add --create "$HTPASSWD" alice > /dev/null || exit 1
+printf 'bobpw\nbobpw\n' | ./pam-auth --db-file "$DB" db user add bob > /dev/null || exit 1
+
+COLLECTOR=http://127.0.0.1:$COLLECTOR_PORT
+
+# traced COMMAND... runs a command with an empty span file
+traced() {
+    : > "$SPANS"
+    "$@"
+}
+
+# has TEXT checks that the exported spans contain TEXT
+has() {
+    grep -aq -- "$1" "$SPANS"
+}
+
+# has_hex HEX checks that the exported spans contain the raw bytes HEX
+has_hex() {
+    od -An -tx1 -v "$SPANS" | tr -d ' \n' | grep -q "$1"
+}
+
+# login USER PASSWORD [FLAGS...] logs in through the htpasswd,db chain
+login() {
+    printf '%s\n%s\n' "$1" "$2" | ./pam-auth --backend htpasswd,db --htpasswd-file "$HTPASSWD" --db-file "$DB" "${@:3}"
+}
+
+traced login alice alicepw --otlp-endpoint $COLLECTOR > /dev/null
+run_test "Spans are exported to /v1/traces" "has /v1/traces"
+run_test "Login span is exported" "has login"
+run_test "Every backend in the chain gets a span" "has backend && has htpasswd"
+run_test "Audit stage is traced" "has audit"
+run_test "Service name is pam-auth" "has pam-auth"
+run_test "Username is recorded" "has enduser.id && has alice"
+run_test "Password is never recorded" "! has alicepw"
+
+traced login bob bobpw --otlp-endpoint $COLLECTOR > /dev/null
+run_test "Account policy is traced" "has policy"
+run_test "Failed chain members are recorded" "has reject"
+
+traced login alice wrong --otlp-endpoint $COLLECTOR > /dev/null
+run_test "Failed logins are exported before exit" "has login && ! has wrong"
+
+TRACE_ID=4bf92f3577b34da6a3ce929d0e0e4736
+traced env TRACEPARENT=00-$TRACE_ID-00f067aa0ba902b7-01 \
+    bash -c "printf 'alice\nalicepw\n' | ./pam-auth --backend htpasswd --htpasswd-file $HTPASSWD --otlp-endpoint $COLLECTOR" > /dev/null
+run_test "TRACEPARENT continues the caller's trace" "has_hex $TRACE_ID"
+
+traced env OTEL_EXPORTER_OTLP_ENDPOINT=$COLLECTOR \
+    bash -c "printf 'alice\nalicepw\n' | ./pam-auth --backend htpasswd --htpasswd-file $HTPASSWD" > /dev/null
+run_test "OTEL_EXPORTER_OTLP_ENDPOINT is honoured" "has login"
+
+traced bash -c "printf 'alice\nalicepw\n' | ./pam-auth --backend htpasswd --htpasswd-file $HTPASSWD" > /dev/null
+run_test "Nothing is exported without an endpoint" "[ ! -s $SPANS ]"
+
+traced env PAM_TYPE=auth PAM_USER=alice bash -c "printf 'alicepw\0' | ./pam-auth pam-exec \
+    --backend htpasswd --htpasswd-file $HTPASSWD --otlp-endpoint $COLLECTOR"
+run_test "pam-exec is traced" "has 'pam-exec auth'"
+
+: > "$SPANS"
+./pam-auth radius-serve --backend htpasswd --htpasswd-file "$HTPASSWD" --listen 127.0.0.1:$RADIUS_PORT \
+    --client 127.0.0.1=$RADIUS_SECRET --otlp-endpoint $COLLECTOR > "$WORKDIR/server.log" 2>&1 &
+server_pid=$!
+pids="$pids $server_pid"
+sleep 1
+printf 'alice\nalicepw\n' | ./pam-auth --backend radius --radius-server 127.0.0.1:$RADIUS_PORT \
+    --radius-secret $RADIUS_SECRET > /dev/null
+kill -INT $server_pid
+wait $server_pid 2>/dev/null
+run_test "radius-serve traces Access-Requests" "has 'radius Access-Request'"
+run_test "RADIUS secrets are never recorded" "! has $RADIUS_SECRET && ! has alicepw"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
@@ -0,0 +1,186 @@
+#!/bin/bash
+
+# Change to project root directory
+cd "$(dirname "$0")/.."
+
+echo "=========================================="
+echo "PAM Auth - Login Records Test Suite"
+echo "=========================================="
+echo
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m' # No Color
+
+WORKDIR=$(mktemp -d /tmp/pam-auth-utmp.XXXXXX)
+trap 'rm -rf "$WORKDIR"' EXIT
+
+success_count=0
+total_tests=0
+
+# Function to run a test
+run_test() {
+    local test_name="$1"
+    local command="$2"
+    local expected_exit_code="${3:-0}"
+
+    echo -e "${BLUE}🧪 Testing: $test_name${NC}"
+    ((total_tests++))
+
+    eval "$command" > /dev/null 2>&1
+    actual_exit_code=$?
+
+    if [ $actual_exit_code -eq $expected_exit_code ]; then
+        echo -e "${GREEN}✅ PASS${NC}: $test_name"
+        ((success_count++))
+    else
+        echo -e "${RED}❌ FAIL${NC}: $test_name (Exit code: $actual_exit_code, Expected: $expected_exit_code)"
+    fi
+    echo
+}
+
+if [ ! -x ./pam-auth ]; then
+    echo "Building pam-auth..."
+    go build -o pam-auth . || exit 1
+fi
+
+export TZ=UTC
+
+# An image whose login records exist but are empty, as systemd-tmpfiles
+# leaves them
+ROOT="$WORKDIR/image"
+mkdir -p "$ROOT/etc" "$ROOT/var/log" "$ROOT/var/run"
+HASH=$(openssl passwd -6 -salt utmpTest s3cret)
+cat > "$ROOT/etc/passwd" <<PASSWD
+root:x:0:0:root:/root:/bin/sh
+utmpalice:x:1500:1500:Utmp Alice:/home/utmpalice:/bin/bash
+PASSWD
+cat > "$ROOT/etc/group" <<GROUP
+root:x:0:
+utmpalice:x:1500:
+GROUP
+cat > "$ROOT/etc/shadow" <<SHADOW
+root:*:19000:0:99999:7:::
+utmpalice:$HASH:19000:0:99999:7:::
+SHADOW
+printf 's3cret\ns3cret\n' | ./pam-auth htpasswd add --create "$WORKDIR/htpasswd" utmpalice > /dev/null || exit 1
+
+UTMP="$ROOT/var/run/utmp"
+WTMP="$ROOT/var/log/wtmp"
+BTMP="$ROOT/var/log/btmp"
+
+# reset empties the login records
+reset() {
+    : > "$UTMP"; : > "$WTMP"; : > "$BTMP"
+}
+
+# login USER PASSWORD [FLAGS...] runs the interactive flow against the image
+login() {
+    local user="$1" password="$2"
+    shift 2
+    printf '%s\n%s\n' "$user" "$password" | ./pam-auth --root "$ROOT" "$@"
+}
+
+# pamexec TYPE TTY runs the pam_exec helper from its own parent process,
+# as each PAM application would
+pamexec() {
+    PAM_USER=utmpalice PAM_TYPE="$1" PAM_TTY="$2" PAM_RHOST=203.0.113.9 PAM_SERVICE=sshd \
+        sh -c '"$@"; status=$?; echo $$ > "'"$WORKDIR"'/ppid"; exit $status' sh \
+        ./pam-auth --root "$ROOT" --utmp pam-exec --backend htpasswd --htpasswd-file "$WORKDIR/htpasswd"
+}
+
+# records FILE prints the number of records in a login record file
+records() {
+    echo $(( $(stat -c %s "$1") / 384 ))
+}
+
+# json FILTER evaluates a python expression over the history JSON as h
+json() {
+    ./pam-auth --root "$ROOT" history --json | python3 -c "import json,sys; h=json.load(sys.stdin); assert $1"
+}
+
+run_test "Utmp flag is global" "./pam-auth history --help | grep -q -- '--utmp'"
+
+reset
+login utmpalice wrong > /dev/null
+login utmpalice s3cret > /dev/null
+run_test "Nothing is recorded without --utmp" "[ \$(records $WTMP) -eq 0 ] && [ \$(records $BTMP) -eq 0 ]"
+
+reset
+login utmpalice wrong --utmp > /dev/null
+run_test "Failed interactive login goes to btmp" "[ \$(records $BTMP) -eq 1 ]"
+
+run_test "Failed login is a LOGIN_PROCESS record without a terminal" \
+    "json 'h[\"failures\"][0][\"type\"]==6 and h[\"failures\"][0][\"line\"]==\"pam-auth:notty\" and h[\"failures\"][0][\"user\"]==\"utmpalice\"'"
+
+run_test "Failed login is not a session" "[ \$(records $WTMP) -eq 0 ]"
+
+login utmpalice s3cret --utmp > /dev/null
+run_test "Interactive login spans no session and is not recorded" \
+    "[ \$(records $UTMP) -eq 0 ] && [ \$(records $WTMP) -eq 0 ] && [ \$(records $BTMP) -eq 1 ]"
+
+reset
+pamexec open_session /dev/pts/7
+run_test "Session open writes utmp and wtmp" "[ \$(records $UTMP) -eq 1 ] && [ \$(records $WTMP) -eq 1 ]"
+
+run_test "Session is a USER_PROCESS on the terminal from the remote host" \
+    "json 'h[\"sessions\"][0][\"line\"]==\"pts/7\" and h[\"sessions\"][0][\"host\"]==\"203.0.113.9\" and h[\"sessions\"][0][\"end\"]==\"still logged in\"'"
+
+run_test "Session carries the PID of the PAM application" \
+    "json 'h[\"sessions\"][0][\"pid\"]==$(cat "$WORKDIR/ppid")'"
+
+if command -v who > /dev/null; then
+    run_test "who lists the session" "who $UTMP | grep -q '^utmpalice *pts/7 .*(203.0.113.9)'"
+fi
+
+pamexec close_session /dev/pts/7
+run_test "Session close ends the wtmp session" \
+    "json 'len(h[\"sessions\"])==1 and h[\"sessions\"][0][\"end\"]==\"logout\"'"
+
+run_test "Session close reuses the utmp entry" "[ \$(records $UTMP) -eq 1 ]"
+
+if command -v who > /dev/null; then
+    run_test "who no longer lists the session" "[ -z \"\$(who $UTMP)\" ]"
+fi
+
+if command -v last > /dev/null; then
+    # last ignores logouts stamped within the current second
+    sleep 1
+    run_test "last reads the session" "last -w -f $WTMP | grep -q '^utmpalice *pts/7 *203.0.113.9 .* - '"
+fi
+
+pamexec open_session ssh
+run_test "Session without a pty is named after the service" \
+    "json 'h[\"sessions\"][0][\"line\"]==\"sshd:notty\"'"
+
+run_test "Session without a pty stays out of utmp" "[ \$(records $UTMP) -eq 1 ]"
+
+run_test "Next login shows the recorded session" \
+    "login utmpalice s3cret | grep -q '^Last Login: .* from 203.0.113.9 on sshd:notty$'"
+
+printf 'wrong' | pamexec auth ssh 2> /dev/null
+run_test "Rejected pam-exec auth goes to btmp" \
+    "json 'len(h[\"failures\"])==1 and h[\"failures\"][0][\"host\"]==\"203.0.113.9\" and h[\"failures\"][0][\"pid\"]==$(cat "$WORKDIR/ppid")'"
+
+printf 's3cret' | pamexec auth ssh
+run_test "Accepted pam-exec auth is not a failure" "[ \$(records $BTMP) -eq 1 ]"
+
+if command -v lastb > /dev/null; then
+    run_test "lastb reads the failure" "lastb -w -f $BTMP | grep -q '^utmpalice *sshd:notty *203.0.113.9'"
+fi
+
+rm -f "$UTMP" "$WTMP" "$BTMP"
+run_test "Missing login records are not created" \
+    "pamexec open_session /dev/pts/7 && login utmpalice wrong --utmp > /dev/null; [ ! -e $UTMP ] && [ ! -e $WTMP ] && [ ! -e $BTMP ]"
+
+echo "=========================================="
+echo "🎯 TEST SUMMARY"
+echo "=========================================="
+echo -e "  Total Tests: $total_tests"
+echo -e "  Passed: ${GREEN}$success_count${NC}"
+echo -e "  Failed: ${RED}$((total_tests - success_count))${NC}"
+echo
+
+[ $success_count -eq $total_tests ]
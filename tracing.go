@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// otlpEndpoint is the --otlp-endpoint URL traces are exported to
+var otlpEndpoint string
+
+// stopTracing flushes and stops the exporter once tracing has started
+var stopTracing func(context.Context) error
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export OpenTelemetry traces over OTLP/HTTP to this URL, e.g. http://localhost:4318 (default from OTEL_EXPORTER_OTLP_ENDPOINT)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		return startTracing()
+	}
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		flushTraces()
+	}
+}
+
+// startTracing installs the OTLP exporter when --otlp-endpoint or the
+// standard OTEL_EXPORTER_OTLP_* variables name a collector
+func startTracing() error {
+	endpoint := otlpEndpoint
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+
+	var options []otlptracehttp.Option
+	if endpoint != "" {
+		// The exporter takes the URL as is, so add the default traces path
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		if _, rest, _ := strings.Cut(endpoint, "://"); !strings.Contains(rest, "/") {
+			endpoint += "/v1/traces"
+		}
+		options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return fmt.Errorf("OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("pam-auth"), semconv.ServiceVersion(version)))
+	if err != nil {
+		return err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	stopTracing = provider.Shutdown
+	return nil
+}
+
+// flushTraces exports pending spans; call it before os.Exit
+func flushTraces() {
+	if stopTracing == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := stopTracing(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ Exporting traces failed: %v\n", err)
+	}
+	stopTracing = nil
+}
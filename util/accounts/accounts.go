@@ -0,0 +1,153 @@
+package accounts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/sysroot"
+)
+
+// Category flags an account in the report
+type Category string
+
+// Report categories; an account can be in several
+const (
+	Locked       Category = "locked"
+	Expired      Category = "expired"
+	NeverExpires Category = "never-expires"
+	Passwordless Category = "passwordless"
+)
+
+// Categories lists every category in report order
+var Categories = []Category{Locked, Expired, NeverExpires, Passwordless}
+
+// Default login.defs bounds of regular user IDs
+const (
+	defaultUIDMin = 1000
+	defaultUIDMax = 60000
+)
+
+// Account is a flagged account
+type Account struct {
+	Name       string     `json:"name"`
+	UID        int        `json:"uid"`
+	Shell      string     `json:"shell"`
+	Categories []Category `json:"categories"`
+	// Aging is the shadow aging state, see sysroot.AgingState
+	Aging   sysroot.AgingState `json:"aging"`
+	Details []string           `json:"details"`
+}
+
+// Has reports whether the account is in category
+func (a Account) Has(category Category) bool {
+	for _, c := range a.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Options selects the accounts Report inspects
+type Options struct {
+	// All includes system accounts outside UID_MIN..UID_MAX of login.defs;
+	// root is always included
+	All bool
+	// Now is the time aging is evaluated at (default time.Now())
+	Now time.Time
+}
+
+// Report returns the accounts of root's passwd file that are locked,
+// expired, passwordless or whose password never expires, and the number of
+// accounts inspected
+func Report(root sysroot.Root, opts Options) ([]Account, int, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	users, err := root.PasswdEntries()
+	if err != nil {
+		return nil, 0, err
+	}
+	shadows, err := root.ShadowEntries()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", root.Path(sysroot.ShadowFile), err)
+	}
+	defs, err := root.LoginDefs()
+	if err != nil {
+		return nil, 0, err
+	}
+	uidMin := loginDefsInt(defs, "UID_MIN", defaultUIDMin)
+	uidMax := loginDefsInt(defs, "UID_MAX", defaultUIDMax)
+
+	var flagged []Account
+	inspected := 0
+	for _, user := range users {
+		if !opts.All && user.UID != 0 && (user.UID < uidMin || user.UID > uidMax) {
+			continue
+		}
+		inspected++
+		if account := check(user, shadows, opts.Now); len(account.Categories) > 0 {
+			flagged = append(flagged, account)
+		}
+	}
+	return flagged, inspected, nil
+}
+
+// check categorizes one account
+func check(user sysroot.Passwd, shadows map[string]sysroot.Shadow, now time.Time) Account {
+	account := Account{Name: user.Name, UID: user.UID, Shell: user.Shell, Aging: sysroot.AgingOK}
+	flag := func(category Category, detail string) {
+		if !account.Has(category) {
+			account.Categories = append(account.Categories, category)
+		}
+		account.Details = append(account.Details, detail)
+	}
+
+	entry, ok := shadows[user.Name]
+	switch {
+	case ok:
+	case user.Password == "x":
+		flag(Locked, "no shadow entry")
+		return account
+	default:
+		// The hash is kept in the passwd file
+		entry = sysroot.Shadow{Name: user.Name, Password: user.Password, LastChange: -1, Min: -1, Max: -1, Warn: -1, Inactive: -1, Expire: -1}
+	}
+
+	switch {
+	case entry.Passwordless():
+		flag(Passwordless, "no password is needed to log in")
+	case entry.Locked():
+		flag(Locked, "password hash is disabled")
+	}
+
+	aging := entry.Aging(now)
+	account.Aging = aging.State
+	switch aging.State {
+	case sysroot.AgingAccountExpired, sysroot.AgingInactive:
+		flag(Expired, aging.Message())
+	case sysroot.AgingChangeRequired:
+		if entry.LastChange == 0 {
+			account.Details = append(account.Details, "password must be changed at next login")
+		} else {
+			flag(Expired, aging.Message())
+		}
+	}
+
+	usable := !entry.Locked() && !entry.Passwordless()
+	if usable && entry.NeverExpires() && entry.LastChange != 0 {
+		flag(NeverExpires, "password never expires")
+	}
+	return account
+}
+
+// loginDefsInt reads a numeric login.defs setting
+func loginDefsInt(defs map[string]string, key string, fallback int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(defs[key]))
+	if err != nil {
+		return fallback
+	}
+	return n
+}
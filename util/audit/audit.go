@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is a single audit log entry, written as one JSON object per line
+type Record struct {
+	Time       time.Time         `json:"time"`
+	Event      string            `json:"event"`
+	Service    string            `json:"service"`
+	Username   string            `json:"user,omitempty"`
+	Remote     string            `json:"remote,omitempty"`
+	Port       string            `json:"port,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Message    string            `json:"message,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Event names used across pam-auth
+const (
+	EventAuthentication = "authentication"
+	EventAuthorization  = "authorization"
+	EventAccounting     = "accounting"
+	EventSession        = "session"
+)
+
+// Logger appends Records to a file. A nil *Logger discards everything, so
+// callers do not need to check whether auditing is enabled.
+type Logger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens (or creates) the audit log at path in append mode
+func Open(path string) (*Logger, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{file: file}, nil
+}
+
+// Log writes r to the audit log, filling in the time when unset
+func (l *Logger) Log(r Record) error {
+	if l == nil {
+		return nil
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(line)
+	return err
+}
+
+// Close closes the underlying file
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Result describes the outcome of a single authentication attempt
+type Result struct {
+	// Username is the account the attempt was made for
+	Username string
+	// Backend names the backend that produced the result
+	Backend string
+	// Success reports whether the credentials were accepted
+	Success bool
+	// Groups lists the groups the user belongs to (only set on success)
+	Groups []string
+	// Message carries an optional human readable reason
+	Message string
+	// Unavailable reports that the backend could not be reached, so a
+	// failure says nothing about the credentials
+	Unavailable bool
+	// Offline reports that the result was served from the offline
+	// credential cache instead of the backend
+	Offline bool
+	// Locked reports that the password was right but the account policy
+	// refused the account (disabled or expired)
+	Locked bool
+	// Err is ErrTimeout or ErrCanceled when the attempt was aborted
+	Err error
+}
+
+// Outcome classifies the result as "success", "reject", "error" when the
+// backend could not be reached or "timeout" when the attempt was aborted
+func (r Result) Outcome() string {
+	switch {
+	case r.Err != nil:
+		return "timeout"
+	case r.Success:
+		return "success"
+	case r.Unavailable:
+		return "error"
+	}
+	return "reject"
+}
+
+// Authenticator verifies a username and password against a backend.
+// Implementations stop waiting on servers, child processes and PAM
+// conversations once ctx is done and return an Aborted result.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) Result
+}
+
+// AuthenticatorFunc adapts a plain function to the Authenticator interface
+type AuthenticatorFunc func(ctx context.Context, username, password string) Result
+
+// Authenticate calls f(ctx, username, password)
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, username, password string) Result {
+	return f(ctx, username, password)
+}
+
+// Errors reported in Result.Err when ctx ends an attempt
+var (
+	ErrTimeout  = errors.New("authentication timed out")
+	ErrCanceled = errors.New("authentication canceled")
+)
+
+// WithTimeout returns a context bounded by timeout, or only cancelable when
+// timeout is zero
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// Aborted returns the failed Result for an attempt stopped because ctx is done
+func Aborted(ctx context.Context, backend, username string) Result {
+	err := ErrCanceled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ErrTimeout
+	}
+	return Result{Username: username, Backend: backend, Message: err.Error(), Err: err}
+}
+
+// PasswordSource is implemented by backends that can supply a user's
+// cleartext password, which challenge-response protocols such as CHAP need
+type PasswordSource interface {
+	CleartextPassword(username string) (string, bool)
+}
+
+// GroupSource is implemented by backends that can list a user's groups
+// without verifying a password first
+type GroupSource interface {
+	Groups(username string) []string
+}
+
+// Pinger is implemented by backends that can check they are reachable
+// without attempting a login, which readiness probes use
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Failure returns an unsuccessful Result for username with the given reason
+func Failure(backend, username, message string) Result {
+	return Result{Username: username, Backend: backend, Message: message}
+}
+
+// Unreachable returns a failed Result for a backend that could not be contacted
+func Unreachable(backend, username, message string) Result {
+	return Result{Username: username, Backend: backend, Message: message, Unavailable: true}
+}
+
+// UserInfo is the account information a backend keeps for a user
+type UserInfo struct {
+	Username string
+	UID      string
+	GID      string
+	HomeDir  string
+	Name     string
+	Shell    string
+	Groups   []string
+	// Attributes holds free-form key/value data attached to the account
+	Attributes map[string]string
+	// Expires is the account expiry time (zero means never)
+	Expires  time.Time
+	Disabled bool
+}
+
+// InfoSource is implemented by backends that store account details
+// themselves rather than relying on system accounts
+type InfoSource interface {
+	UserInfo(username string) (UserInfo, error)
+}
+
+// ErrUnknownUser is wrapped by InfoSource implementations for users they do not hold
+var ErrUnknownUser = errors.New("no such user")
+
+// Account policy errors returned by CheckAccount
+var (
+	ErrAccountDisabled = errors.New("account disabled")
+	ErrAccountExpired  = errors.New("account expired")
+)
+
+// CheckAccount applies the account policy to info at now, refusing
+// disabled and expired accounts
+func CheckAccount(info UserInfo, now time.Time) error {
+	if info.Disabled {
+		return ErrAccountDisabled
+	}
+	if !info.Expires.IsZero() && !now.Before(info.Expires) {
+		return fmt.Errorf("%w on %s", ErrAccountExpired, info.Expires.Format("2006-01-02"))
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/htpasswd"
+	"github.com/bariiss/pam-auth/util/krb5"
+	"github.com/bariiss/pam-auth/util/offline"
+	"github.com/bariiss/pam-auth/util/radius"
+	"github.com/bariiss/pam-auth/util/userdb"
+)
+
+// Names lists the backends New can build, in addition to "system"
+var Names = []string{"radius", "krb5", "htpasswd", "db"}
+
+// ErrSystem is returned by New for the system backend, which only the
+// pam-auth binary itself can provide
+var ErrSystem = errors.New("the system backend is not available here")
+
+// Config selects and configures a non-system backend. Field names mirror
+// the pam-auth command line flags.
+type Config struct {
+	// Name is the backend: system, radius, krb5, htpasswd or db, or a
+	// comma separated chain of non-system backends
+	Name string
+	// Timeout bounds each authentication attempt (zero means no limit)
+	Timeout time.Duration
+
+	RADIUSServers  []string
+	RADIUSSecret   string
+	RADIUSTimeout  time.Duration
+	RADIUSRetries  int
+	RADIUSGroupMap []string
+	// RADIUSPrompt answers Access-Challenge messages
+	RADIUSPrompt func(message string) (string, error)
+
+	KRB5Realm           string
+	KRB5AllowedRealms   []string
+	KRB5Config          string
+	KRB5KDCs            []string
+	KRB5Keytab          string
+	KRB5ServicePrinc    string
+	KRB5AllowUnverified bool
+	KRB5CCache          string
+
+	HtpasswdFile      string
+	HtpasswdUpgrade   bool
+	HtpasswdPlaintext bool
+
+	DBFile string
+
+	// OfflineCache enables the offline credential cache for the radius and
+	// krb5 backends
+	OfflineCache  string
+	OfflineKey    string
+	OfflineTTL    time.Duration
+	OfflineMaxAge time.Duration
+}
+
+// DefaultConfig returns the configuration the command line flags default to
+func DefaultConfig() Config {
+	return Config{
+		Name:          "system",
+		RADIUSTimeout: radius.DefaultClientTimeout,
+		RADIUSRetries: 2,
+		KRB5Keytab:    "/etc/krb5.keytab",
+		DBFile:        "/var/lib/pam-auth/users.db",
+		OfflineTTL:    offline.DefaultTTL,
+		OfflineMaxAge: offline.DefaultMaxOfflineAge,
+	}
+}
+
+// Set applies a single option given by its flag name without the leading
+// dashes, e.g. Set("radius-server", "10.0.0.1"). Underscores are accepted in
+// place of dashes. Repeatable flags append.
+func (c *Config) Set(name, value string) error {
+	var err error
+	switch strings.ReplaceAll(name, "_", "-") {
+	case "backend":
+		c.Name = value
+	case "timeout":
+		c.Timeout, err = time.ParseDuration(value)
+	case "radius-server":
+		c.RADIUSServers = append(c.RADIUSServers, value)
+	case "radius-secret":
+		c.RADIUSSecret = value
+	case "radius-timeout":
+		c.RADIUSTimeout, err = time.ParseDuration(value)
+	case "radius-retries":
+		c.RADIUSRetries, err = strconv.Atoi(value)
+	case "radius-group":
+		c.RADIUSGroupMap = append(c.RADIUSGroupMap, value)
+	case "krb5-realm":
+		c.KRB5Realm = value
+	case "krb5-allowed-realm":
+		c.KRB5AllowedRealms = append(c.KRB5AllowedRealms, value)
+	case "krb5-config":
+		c.KRB5Config = value
+	case "krb5-kdc":
+		c.KRB5KDCs = append(c.KRB5KDCs, value)
+	case "krb5-keytab":
+		c.KRB5Keytab = value
+	case "krb5-service-principal":
+		c.KRB5ServicePrinc = value
+	case "krb5-allow-unverified":
+		c.KRB5AllowUnverified, err = parseBool(value)
+	case "krb5-ccache":
+		c.KRB5CCache = value
+	case "htpasswd-file":
+		c.HtpasswdFile = value
+	case "htpasswd-upgrade":
+		c.HtpasswdUpgrade, err = parseBool(value)
+	case "htpasswd-plaintext":
+		c.HtpasswdPlaintext, err = parseBool(value)
+	case "db-file":
+		c.DBFile = value
+	case "offline-cache":
+		c.OfflineCache = value
+	case "offline-key":
+		c.OfflineKey = value
+	case "offline-ttl":
+		c.OfflineTTL, err = time.ParseDuration(value)
+	case "offline-max-age":
+		c.OfflineMaxAge, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("unknown option: %s", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return nil
+}
+
+// parseBool accepts an empty value as true so options can be given bare
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// New builds the backend described by cfg. A comma separated Name such as
+// "db,radius" builds a Chain. The caller should close the result if it
+// implements io.Closer.
+func New(cfg Config) (auth.Authenticator, error) {
+	names := strings.Split(cfg.Name, ",")
+	if len(names) == 1 {
+		return newSingle(cfg)
+	}
+
+	var chain Chain
+	for _, name := range names {
+		single := cfg
+		single.Name = strings.TrimSpace(name)
+		authenticator, err := newSingle(single)
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+		chain = append(chain, authenticator)
+	}
+	return chain, nil
+}
+
+// newSingle builds one named backend
+func newSingle(cfg Config) (auth.Authenticator, error) {
+	switch cfg.Name {
+	case "system", "":
+		return nil, ErrSystem
+	case "radius":
+		client, err := newRADIUSClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return withOfflineCache(cfg, client)
+	case "krb5":
+		authenticator, err := newKerberosAuthenticator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return withOfflineCache(cfg, authenticator)
+	case "htpasswd":
+		if cfg.HtpasswdFile == "" {
+			return nil, fmt.Errorf("the htpasswd backend needs --htpasswd-file")
+		}
+		file, err := htpasswd.Open(cfg.HtpasswdFile, false)
+		if err != nil {
+			return nil, err
+		}
+		file.UpgradeLegacy = cfg.HtpasswdUpgrade
+		file.Plaintext = cfg.HtpasswdPlaintext
+		return file, nil
+	case "db":
+		if _, err := os.Stat(cfg.DBFile); err != nil {
+			return nil, fmt.Errorf("the db backend needs an existing --db-file: %w", err)
+		}
+		return userdb.Open(cfg.DBFile)
+	}
+	return nil, fmt.Errorf("unknown backend: %s", cfg.Name)
+}
+
+// withOfflineCache wraps a directory backend in the offline credential cache
+// when --offline-cache is set. The key defaults to the cache path plus ".key".
+func withOfflineCache(cfg Config, authenticator auth.Authenticator) (auth.Authenticator, error) {
+	if cfg.OfflineCache == "" {
+		return authenticator, nil
+	}
+	keyPath := cfg.OfflineKey
+	if keyPath == "" {
+		keyPath = cfg.OfflineCache + ".key"
+	}
+	key, err := offline.LoadKey(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("offline cache key: %w", err)
+	}
+	cache := offline.New(authenticator, cfg.OfflineCache, key)
+	cache.TTL = cfg.OfflineTTL
+	cache.MaxOfflineAge = cfg.OfflineMaxAge
+	// Standard output may carry the pam-exec or exec protocol
+	cache.Warnings = os.Stderr
+	return cache, nil
+}
+
+// newRADIUSClient builds the radius backend
+func newRADIUSClient(cfg Config) (*radius.Client, error) {
+	if len(cfg.RADIUSServers) == 0 {
+		return nil, fmt.Errorf("the radius backend needs at least one --radius-server")
+	}
+	if cfg.RADIUSSecret == "" {
+		return nil, fmt.Errorf("the radius backend needs --radius-secret")
+	}
+
+	servers := make([]string, len(cfg.RADIUSServers))
+	for i, server := range cfg.RADIUSServers {
+		if !strings.Contains(server, ":") || strings.HasSuffix(server, "]") {
+			server += ":1812"
+		}
+		servers[i] = server
+	}
+
+	groupMap := make(map[string]string)
+	for _, spec := range cfg.RADIUSGroupMap {
+		value, group, ok := strings.Cut(spec, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid --radius-group %q: expected VALUE=GROUP", spec)
+		}
+		groupMap[value] = group
+	}
+
+	hostname, _ := os.Hostname()
+	return &radius.Client{
+		Servers:       servers,
+		Secret:        []byte(cfg.RADIUSSecret),
+		Timeout:       cfg.RADIUSTimeout,
+		Retries:       cfg.RADIUSRetries,
+		NASIdentifier: hostname,
+		GroupMap:      groupMap,
+		Prompt:        cfg.RADIUSPrompt,
+	}, nil
+}
+
+// newKerberosAuthenticator builds the krb5 backend
+func newKerberosAuthenticator(cfg Config) (*krb5.Authenticator, error) {
+	keytabPath := cfg.KRB5Keytab
+	if cfg.KRB5AllowUnverified {
+		if _, err := os.Stat(keytabPath); err != nil {
+			keytabPath = ""
+		}
+	}
+	return krb5.New(krb5.Options{
+		Realm:            cfg.KRB5Realm,
+		AllowedRealms:    cfg.KRB5AllowedRealms,
+		ConfigPath:       cfg.KRB5Config,
+		KDCs:             cfg.KRB5KDCs,
+		KeytabPath:       keytabPath,
+		ServicePrincipal: cfg.KRB5ServicePrinc,
+		AllowUnverified:  cfg.KRB5AllowUnverified,
+		CCachePath:       cfg.KRB5CCache,
+	})
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/tracing"
+)
+
+// ErrNoAccountData is returned by Chain.UserInfo when no backend in the
+// chain keeps account details
+var ErrNoAccountData = errors.New("no backend in the chain keeps account data")
+
+// Chain tries several backends in order and accepts the first success
+type Chain []auth.Authenticator
+
+// Authenticate implements auth.Authenticator. When every backend rejects the
+// credentials the messages are combined, and the failure is marked
+// unavailable if any backend could not be reached. An aborted attempt stops
+// the chain. Each backend is traced in its own span.
+func (c Chain) Authenticate(ctx context.Context, username, password string) auth.Result {
+	var messages []string
+	unavailable := false
+	for _, authenticator := range c {
+		result := tracing.Authenticate(ctx, "backend", authenticator, username, password)
+		if result.Success || result.Err != nil {
+			return result
+		}
+		if result.Message != "" {
+			messages = append(messages, result.Backend+": "+result.Message)
+		}
+		unavailable = unavailable || result.Unavailable
+	}
+	result := auth.Failure("chain", username, strings.Join(messages, "; "))
+	result.Unavailable = unavailable
+	return result
+}
+
+// Groups implements auth.GroupSource using the first backend that knows the user
+func (c Chain) Groups(username string) []string {
+	for _, authenticator := range c {
+		if source, ok := authenticator.(auth.GroupSource); ok {
+			if groups := source.Groups(username); len(groups) > 0 {
+				return groups
+			}
+		}
+	}
+	return nil
+}
+
+// UserInfo implements auth.InfoSource using the first backend that knows the
+// user. A user unknown to every account-keeping backend may still exist in
+// one without account data, so that case reports ErrNoAccountData.
+func (c Chain) UserInfo(username string) (auth.UserInfo, error) {
+	var err error
+	opaque := false
+	for _, authenticator := range c {
+		source, ok := authenticator.(auth.InfoSource)
+		if !ok {
+			opaque = true
+			continue
+		}
+		info, lookupErr := source.UserInfo(username)
+		if lookupErr == nil {
+			return info, nil
+		}
+		if err == nil || !errors.Is(lookupErr, auth.ErrUnknownUser) {
+			err = lookupErr
+		}
+	}
+	if err == nil || (opaque && errors.Is(err, auth.ErrUnknownUser)) {
+		err = ErrNoAccountData
+	}
+	return auth.UserInfo{}, err
+}
+
+// Ping implements auth.Pinger by pinging every backend that supports it
+func (c Chain) Ping(ctx context.Context) error {
+	var errs []error
+	for _, authenticator := range c {
+		if pinger, ok := authenticator.(auth.Pinger); ok {
+			errs = append(errs, pinger.Ping(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every backend that holds resources
+func (c Chain) Close() error {
+	var errs []error
+	for _, authenticator := range c {
+		if closer, ok := authenticator.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,254 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+)
+
+// Outcome classifies one authentication attempt
+type Outcome string
+
+// Outcomes in report order, as returned by auth.Result.Outcome. Errors are
+// attempts the backend could not answer; rejects are answered attempts with
+// wrong credentials.
+const (
+	Success Outcome = "success"
+	Reject  Outcome = "reject"
+	Error   Outcome = "error"
+	Timeout Outcome = "timeout"
+)
+
+// Outcomes lists every outcome in report order
+var Outcomes = []Outcome{Success, Reject, Error, Timeout}
+
+// Credential is one username and password to log in with
+type Credential struct {
+	Username string
+	Password string
+}
+
+// LoadUsers reads "username:password" lines from path, skipping blank lines
+// and # comments
+func LoadUsers(path string) ([]Credential, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var users []Credential
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, password, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("%s:%d: expected username:password", path, lineNo)
+		}
+		users = append(users, Credential{Username: username, Password: password})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users", path)
+	}
+	return users, nil
+}
+
+// Options controls a benchmark run
+type Options struct {
+	// Concurrency is the number of attempts in flight at once
+	Concurrency int
+	// Duration stops the run after this long (zero means no limit)
+	Duration time.Duration
+	// Requests stops the run after this many attempts (zero means no limit)
+	Requests int
+	// Timeout bounds each attempt (zero means no limit)
+	Timeout time.Duration
+	// Window is the interval error rates are tracked over for spike detection
+	Window time.Duration
+}
+
+// Stats summarises the latencies of one outcome
+type Stats struct {
+	Count int
+	Min   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Window counts the attempts that finished in one spike detection interval
+type Window struct {
+	// Start is the offset of the window from the start of the run
+	Start  time.Duration
+	Total  int
+	Errors int
+}
+
+// ErrorRate is the fraction of the window's attempts that were errors or timeouts
+func (w Window) ErrorRate() float64 {
+	if w.Total == 0 {
+		return 0
+	}
+	return float64(w.Errors) / float64(w.Total)
+}
+
+// Report is the result of a benchmark run
+type Report struct {
+	Elapsed  time.Duration
+	Total    int
+	Outcomes map[Outcome]Stats
+	Windows  []Window
+}
+
+// Throughput is the number of attempts per second
+func (r Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Total) / r.Elapsed.Seconds()
+}
+
+// Spikes returns the windows whose error rate reached threshold with at
+// least minErrors errors
+func (r Report) Spikes(threshold float64, minErrors int) []Window {
+	var spikes []Window
+	for _, window := range r.Windows {
+		if window.Errors >= minErrors && window.Errors > 0 && window.ErrorRate() >= threshold {
+			spikes = append(spikes, window)
+		}
+	}
+	return spikes
+}
+
+// sample is one finished attempt
+type sample struct {
+	outcome Outcome
+	latency time.Duration
+	done    time.Duration
+}
+
+// Run drives authenticator with users round robin until opts.Duration has
+// passed, opts.Requests attempts were made or ctx is done. Attempts canceled
+// through ctx are left out of the report.
+func Run(ctx context.Context, authenticator auth.Authenticator, users []Credential, opts Options) Report {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Second
+	}
+	// Attempts in flight when the duration ends are allowed to finish;
+	// canceling ctx stops them too
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.Duration > 0 {
+		feedCtx, cancel = context.WithTimeout(feedCtx, opts.Duration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := 0; opts.Requests == 0 || i < opts.Requests; i++ {
+			select {
+			case next <- i:
+			case <-feedCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		wg      sync.WaitGroup
+	)
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				user := users[i%len(users)]
+				attemptCtx, cancel := auth.WithTimeout(ctx, opts.Timeout)
+				began := time.Now()
+				result := authenticator.Authenticate(attemptCtx, user.Username, user.Password)
+				finished := time.Now()
+				cancel()
+				if errors.Is(result.Err, auth.ErrCanceled) {
+					continue
+				}
+
+				mu.Lock()
+				samples = append(samples, sample{outcome: Outcome(result.Outcome()), latency: finished.Sub(began), done: finished.Sub(start)})
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return summarise(samples, time.Since(start), opts.Window)
+}
+
+// summarise builds the report from the finished attempts
+func summarise(samples []sample, elapsed, window time.Duration) Report {
+	report := Report{Elapsed: elapsed, Total: len(samples), Outcomes: make(map[Outcome]Stats)}
+
+	latencies := make(map[Outcome][]time.Duration)
+	report.Windows = make([]Window, int(elapsed/window)+1)
+	for i := range report.Windows {
+		report.Windows[i].Start = time.Duration(i) * window
+	}
+	for _, s := range samples {
+		latencies[s.outcome] = append(latencies[s.outcome], s.latency)
+		w := &report.Windows[min(int(s.done/window), len(report.Windows)-1)]
+		w.Total++
+		if s.outcome == Error || s.outcome == Timeout {
+			w.Errors++
+		}
+	}
+	for outcome, values := range latencies {
+		report.Outcomes[outcome] = latencyStats(values)
+	}
+	return report
+}
+
+// latencyStats computes the summary of values, which it sorts
+func latencyStats(values []time.Duration) Stats {
+	slices.Sort(values)
+	var sum time.Duration
+	for _, v := range values {
+		sum += v
+	}
+	return Stats{
+		Count: len(values),
+		Min:   values[0],
+		Mean:  sum / time.Duration(len(values)),
+		P50:   percentile(values, 50),
+		P90:   percentile(values, 90),
+		P99:   percentile(values, 99),
+		Max:   values[len(values)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted values
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,280 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	gcrypt "github.com/GehirnInc/crypt"
+	_ "github.com/GehirnInc/crypt/apr1_crypt"
+	_ "github.com/GehirnInc/crypt/md5_crypt"
+	_ "github.com/GehirnInc/crypt/sha256_crypt"
+	_ "github.com/GehirnInc/crypt/sha512_crypt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scheme names returned by Scheme
+const (
+	SchemeBcrypt      = "bcrypt"
+	SchemeSHA512Crypt = "sha512-crypt"
+	SchemeSHA256Crypt = "sha256-crypt"
+	SchemeMD5Crypt    = "md5-crypt"
+	SchemeAPR1        = "apr1"
+	SchemeSHA1        = "sha1"
+	SchemeArgon2id    = "argon2id"
+	SchemeYescrypt    = "yescrypt"
+	SchemeUnknown     = "unknown"
+)
+
+// ErrUnsupported is returned for hashes in an unknown format
+var ErrUnsupported = errors.New("unsupported password hash format")
+
+// Scheme identifies the hashing scheme of a crypt(3) or htpasswd style hash
+func Scheme(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return SchemeBcrypt
+	case strings.HasPrefix(hash, "$6$"):
+		return SchemeSHA512Crypt
+	case strings.HasPrefix(hash, "$5$"):
+		return SchemeSHA256Crypt
+	case strings.HasPrefix(hash, "$1$"):
+		return SchemeMD5Crypt
+	case strings.HasPrefix(hash, "$apr1$"):
+		return SchemeAPR1
+	case strings.HasPrefix(hash, "{SHA}"):
+		return SchemeSHA1
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return SchemeArgon2id
+	case strings.HasPrefix(hash, "$y$"):
+		return SchemeYescrypt
+	}
+	return SchemeUnknown
+}
+
+// Verify reports whether password matches hash. A non-nil error means the
+// hash could not be checked at all.
+func Verify(hash, password string) (bool, error) {
+	switch Scheme(hash) {
+	case SchemeBcrypt:
+		// Go's bcrypt only understands $2a$; $2y$ and $2b$ are the same algorithm
+		normalized := "$2a$" + hash[4:]
+		err := bcrypt.CompareHashAndPassword([]byte(normalized), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case SchemeSHA1:
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1, nil
+	case SchemeArgon2id:
+		return verifyArgon2id(hash, password)
+	case SchemeYescrypt:
+		return verifyYescrypt(hash, password)
+	case SchemeSHA512Crypt, SchemeSHA256Crypt, SchemeMD5Crypt, SchemeAPR1:
+		if !gcrypt.IsHashSupported(hash) {
+			return false, ErrUnsupported
+		}
+		err := gcrypt.NewFromHash(hash).Verify(hash, []byte(password))
+		if errors.Is(err, gcrypt.ErrKeyMismatch) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnsupported
+}
+
+// HashSchemes are the schemes Hash generates
+var HashSchemes = []string{SchemeYescrypt, SchemeSHA512Crypt, SchemeBcrypt, SchemeArgon2id}
+
+// HashOptions tune Hash; zero values select each scheme's default
+type HashOptions struct {
+	// Cost is the yescrypt cost (1 to 11), the sha512-crypt rounds, the
+	// bcrypt cost or the argon2id iterations
+	Cost int
+	// Salt is the salt as it appears in the hash; empty draws a random one
+	Salt string
+	// Memory is the argon2id memory in KiB
+	Memory uint32
+}
+
+// Hash hashes password with scheme into a crypt(3) or PHC string
+func Hash(scheme, password string, opts HashOptions) (string, error) {
+	switch scheme {
+	case SchemeYescrypt:
+		params, err := YescryptCost(opts.Cost)
+		if err != nil {
+			return "", err
+		}
+		return Yescrypt(password, opts.Salt, params)
+	case SchemeSHA512Crypt:
+		return SHA512Crypt(password, opts.Salt, opts.Cost)
+	case SchemeBcrypt:
+		if opts.Salt != "" {
+			return "", errors.New("bcrypt salts are always random")
+		}
+		hash, err := Bcrypt(password, opts.Cost)
+		if err != nil {
+			return "", err
+		}
+		// libxcrypt and OpenBSD write bcrypt hashes with the $2b$ prefix
+		return "$2b$" + strings.TrimPrefix(hash, "$2a$"), nil
+	case SchemeArgon2id:
+		params := DefaultArgon2Params
+		if opts.Cost < 0 {
+			return "", fmt.Errorf("argon2id iterations must be at least 1, got %d", opts.Cost)
+		}
+		if opts.Cost != 0 {
+			params.Iterations = uint32(opts.Cost)
+		}
+		if opts.Memory != 0 {
+			params.Memory = opts.Memory
+		}
+		if err := params.check(); err != nil {
+			return "", err
+		}
+		if opts.Salt == "" {
+			return Argon2id(password, params)
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(opts.Salt)
+		if err != nil || len(salt) < 8 {
+			return "", errors.New("argon2id salt must be at least 8 bytes of unpadded base64")
+		}
+		return argon2idWithSalt(password, salt, params), nil
+	}
+	return "", fmt.Errorf("cannot generate %s hashes, expected one of %s", scheme, strings.Join(HashSchemes, ", "))
+}
+
+// SHA512Crypt hashes password with sha512-crypt ("$6$"). An empty salt
+// draws 16 random characters; rounds 0 keeps the default of 5000.
+func SHA512Crypt(password, salt string, rounds int) (string, error) {
+	if salt == "" {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		for i, b := range raw {
+			raw[i] = itoa64[b&0x3f]
+		}
+		salt = string(raw)
+	}
+	if len(salt) > 16 || strings.Trim(salt, itoa64) != "" {
+		return "", errors.New("sha512-crypt salt must be 1 to 16 characters of [./0-9A-Za-z]")
+	}
+	setting := "$6$" + salt
+	if rounds != 0 {
+		if rounds < 1000 || rounds > 999999999 {
+			return "", fmt.Errorf("sha512-crypt rounds must be between 1000 and 999999999, got %d", rounds)
+		}
+		setting = fmt.Sprintf("$6$rounds=%d$%s", rounds, salt)
+	}
+	return gcrypt.SHA512.New().Generate([]byte(password), []byte(setting))
+}
+
+// Bcrypt hashes password with bcrypt at the given cost (0 selects the default)
+func Bcrypt(password string, cost int) (string, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return "", fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Argon2Params are the tuning parameters of an argon2id hash
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the RFC 9106 second recommended option
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}
+
+// maxArgon2Memory bounds the memory of an argon2id hash to 4 GiB, so a
+// hostile stored hash cannot exhaust the host
+const maxArgon2Memory = 4 << 20
+
+// check reports why params are outside what RFC 9106 allows, where
+// x/crypto would panic or silently use other values
+func (p Argon2Params) check() error {
+	switch {
+	case p.Iterations < 1:
+		return errors.New("argon2id needs at least 1 iteration")
+	case p.Parallelism < 1:
+		return errors.New("argon2id needs at least 1 lane")
+	case p.Memory < 8*uint32(p.Parallelism):
+		return fmt.Errorf("argon2id memory must be at least 8 KiB per lane, %d KiB for %d lanes", 8*uint32(p.Parallelism), p.Parallelism)
+	case p.Memory > maxArgon2Memory:
+		return fmt.Errorf("argon2id memory must be at most %d KiB", maxArgon2Memory)
+	case p.KeyLength < 1:
+		return errors.New("argon2id needs a key of at least 1 byte")
+	}
+	return nil
+}
+
+// Argon2id hashes password into a PHC string such as
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+func Argon2id(password string, params Argon2Params) (string, error) {
+	if err := params.check(); err != nil {
+		return "", err
+	}
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return argon2idWithSalt(password, salt, params), nil
+}
+
+// argon2idWithSalt hashes password with a given salt
+func argon2idWithSalt(password string, salt []byte, params Argon2Params) string {
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// verifyArgon2id checks password against a PHC argon2id string
+func verifyArgon2id(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrUnsupported
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrUnsupported
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return false, ErrUnsupported
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnsupported
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, ErrUnsupported
+	}
+	params.KeyLength = uint32(len(expected))
+	if params.check() != nil {
+		return false, ErrUnsupported
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
@@ -0,0 +1,655 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// yescrypt flags, as in the reference implementation
+const (
+	yescryptWORM    = 0x001
+	yescryptRW      = 0x002
+	yescryptPrehash = 0x10000000
+	// yescryptDefaults is the only pwxform flavor defined so far: 6 rounds,
+	// gather 4, simple 2 and a 12 KiB S-box
+	yescryptDefaults = yescryptRW | 0x004 | 0x010 | 0x020 | 0x080
+)
+
+// pwxform parameters of yescryptDefaults
+const (
+	pwxSimple = 2
+	pwxGather = 4
+	pwxRounds = 6
+	sWidth    = 8
+	// sWords is the size of the S-box in 32-bit words, sMask selects an
+	// S-box entry from a word
+	sWords = 3 * (1 << sWidth) * pwxSimple * 2
+	sMask  = ((1 << sWidth) - 1) * pwxSimple * 8
+)
+
+// itoa64 is the alphabet of crypt(3) hashes
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// YescryptParams are the tuning parameters of a yescrypt hash
+type YescryptParams struct {
+	// N is the number of 128*R byte blocks, a power of two
+	N uint64
+	R uint32
+	P uint32
+	// T adds time without adding memory
+	T     uint32
+	flags uint32
+}
+
+// YescryptCost returns the parameters libxcrypt picks for a cost between 1
+// and 11; the default cost 5 uses 16 MiB
+func YescryptCost(cost int) (YescryptParams, error) {
+	if cost == 0 {
+		cost = 5
+	}
+	if cost < 1 || cost > 11 {
+		return YescryptParams{}, fmt.Errorf("yescrypt cost must be between 1 and 11, got %d", cost)
+	}
+	params := YescryptParams{R: 32, N: 1 << (cost + 7), P: 1, flags: yescryptDefaults}
+	if cost <= 2 {
+		params.R, params.N = 8, 1<<(cost+9)
+	}
+	return params, nil
+}
+
+// Yescrypt hashes password into a "$y$" crypt(3) string. An empty salt
+// draws 16 random bytes; otherwise salt is in the crypt(3) alphabet, as
+// it appears in the hash.
+func Yescrypt(password, salt string, params YescryptParams) (string, error) {
+	if params.flags == 0 {
+		params.flags = yescryptDefaults
+	}
+	if salt == "" {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		salt = encode64(raw)
+	}
+	setting, err := params.encode()
+	if err != nil {
+		return "", err
+	}
+	return yescryptCrypt(password, setting+salt)
+}
+
+// verifyYescrypt checks password against a "$y$" hash
+func verifyYescrypt(hash, password string) (bool, error) {
+	end := strings.LastIndexByte(hash, '$')
+	if end < 0 {
+		return false, ErrUnsupported
+	}
+	computed, err := yescryptCrypt(password, hash[:end])
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1, nil
+}
+
+// yescryptCrypt hashes password with the "$y$PARAMS$SALT" setting
+func yescryptCrypt(password, setting string) (string, error) {
+	if !strings.HasPrefix(setting, "$y$") {
+		return "", ErrUnsupported
+	}
+	params, rest, err := decodeYescryptParams(setting[3:])
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(rest, "$") {
+		return "", ErrUnsupported
+	}
+	saltText := rest[1:]
+	salt, ok := decode64(saltText)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid yescrypt salt", ErrUnsupported)
+	}
+
+	key, err := yescryptKDF([]byte(password), salt, params)
+	if err != nil {
+		return "", err
+	}
+	return setting + "$" + encode64(key), nil
+}
+
+// encode formats the parameters part of a setting, "$y$j9T$"
+func (p YescryptParams) encode() (string, error) {
+	if err := p.check(); err != nil {
+		return "", err
+	}
+	flavor := p.flags
+	if flavor >= yescryptRW {
+		flavor = yescryptRW + (flavor >> 2)
+	}
+	var b strings.Builder
+	b.WriteString("$y$")
+	b.WriteString(encode64Uint32(flavor, 0))
+	b.WriteString(encode64Uint32(uint32(bits.TrailingZeros64(p.N)), 1))
+	b.WriteString(encode64Uint32(p.R, 1))
+	have := uint32(0)
+	if p.P != 1 {
+		have |= 1
+	}
+	if p.T != 0 {
+		have |= 2
+	}
+	if have != 0 {
+		b.WriteString(encode64Uint32(have, 1))
+		if p.P != 1 {
+			b.WriteString(encode64Uint32(p.P, 2))
+		}
+		if p.T != 0 {
+			b.WriteString(encode64Uint32(p.T, 1))
+		}
+	}
+	b.WriteByte('$')
+	return b.String(), nil
+}
+
+// check rejects parameters the KDF cannot run with
+func (p YescryptParams) check() error {
+	switch {
+	case p.N < 2 || p.N&(p.N-1) != 0:
+		return errors.New("yescrypt N must be a power of two")
+	case p.R == 0 || p.P == 0:
+		return errors.New("yescrypt r and p must be positive")
+	case uint64(p.R)*uint64(p.P) >= 1<<30 || p.N > 1<<32 || p.N*uint64(p.R) > 1<<32:
+		return errors.New("yescrypt parameters are too large")
+	case p.flags&yescryptRW != 0 && p.flags != yescryptDefaults:
+		return fmt.Errorf("%w: yescrypt flavor %#x", ErrUnsupported, p.flags)
+	case p.flags&yescryptRW != 0 && p.N/uint64(p.P) <= 1:
+		return errors.New("yescrypt N/p must be at least 2")
+	case p.flags > yescryptDefaults:
+		return fmt.Errorf("%w: yescrypt flavor %#x", ErrUnsupported, p.flags)
+	}
+	return nil
+}
+
+// decodeYescryptParams parses the parameters part of a setting and returns
+// what follows it
+func decodeYescryptParams(s string) (YescryptParams, string, error) {
+	var params YescryptParams
+	var ok bool
+	field := func(min uint32) uint32 {
+		var v uint32
+		if ok {
+			v, s, ok = decode64Uint32(s, min)
+		}
+		return v
+	}
+	ok = true
+	flavor := field(0)
+	nLog2 := field(1)
+	params.R = field(1)
+	params.P = 1
+	if ok && s != "" && s[0] != '$' {
+		have := field(1)
+		if have&^uint32(0xf) != 0 {
+			ok = false
+		}
+		if have&1 != 0 {
+			params.P = field(2)
+		}
+		if have&2 != 0 {
+			params.T = field(1)
+		}
+		if have&4 != 0 || have&8 != 0 {
+			// Hash upgrades and ROMs are not supported
+			return params, "", fmt.Errorf("%w: yescrypt upgrade or ROM parameters", ErrUnsupported)
+		}
+	}
+	if !ok || nLog2 > 63 {
+		return params, "", fmt.Errorf("%w: invalid yescrypt parameters", ErrUnsupported)
+	}
+	params.N = 1 << nLog2
+	switch {
+	case flavor < yescryptRW:
+		params.flags = flavor
+	case flavor <= yescryptRW+(0x3fc>>2):
+		params.flags = yescryptRW + (flavor-yescryptRW)<<2
+	default:
+		return params, "", fmt.Errorf("%w: yescrypt flavor %d", ErrUnsupported, flavor)
+	}
+	if err := params.check(); err != nil {
+		return params, "", err
+	}
+	return params, s, nil
+}
+
+// yescryptKDF derives the 32 byte key stored in the hash
+func yescryptKDF(password, salt []byte, p YescryptParams) ([]byte, error) {
+	if p.flags&yescryptRW != 0 && p.N/uint64(p.P) >= 0x100 && p.N/uint64(p.P)*uint64(p.R) >= 0x20000 {
+		// Large parameters first hash the password with 1/64 of the
+		// memory, so that cheap guesses cannot be told apart early
+		pre := p
+		pre.N >>= 6
+		pre.T = 0
+		pre.flags |= yescryptPrehash
+		password = yescryptBody(password, salt, pre)
+	}
+	return yescryptBody(password, salt, p), nil
+}
+
+// yescryptBody is one pass of yescrypt_kdf_body
+func yescryptBody(password, salt []byte, p YescryptParams) []byte {
+	r := int(p.R)
+	if p.flags != 0 {
+		key := "yescrypt"
+		if p.flags&yescryptPrehash != 0 {
+			key = "yescrypt-prehash"
+		}
+		password = hmacSHA256([]byte(key), password)
+	}
+
+	B := pbkdf2.Key(password, salt, 1, 128*r*int(p.P), sha256.New)
+	if p.flags != 0 {
+		password = append([]byte(nil), B[:32]...)
+	}
+
+	V := make([]uint32, 32*r*int(p.N))
+	XY := make([]uint32, 64*r)
+	if p.P == 1 || p.flags&yescryptRW != 0 {
+		smix(B, r, p, V, XY, password)
+	} else {
+		for i := 0; i < int(p.P); i++ {
+			single := p
+			single.P = 1
+			smix(B[128*r*i:128*r*(i+1)], r, single, V, XY, nil)
+		}
+	}
+
+	key := pbkdf2.Key(password, B, 1, 32, sha256.New)
+	if p.flags != 0 && p.flags&yescryptPrehash == 0 {
+		// ClientKey and StoredKey as in SCRAM
+		clientKey := hmacSHA256(key, []byte("Client Key"))
+		stored := sha256.Sum256(clientKey)
+		key = stored[:]
+	}
+	return key
+}
+
+// hmacSHA256 computes HMAC-SHA256(key, message)
+func hmacSHA256(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// pwxformCtx holds the S-boxes of one smix lane
+type pwxformCtx struct {
+	S          []uint32
+	s0, s1, s2 int
+	w          int
+}
+
+// smix runs SMix on the p lanes of B. password is updated in place with
+// the first lane's S-box state in RW mode.
+func smix(B []byte, r int, p YescryptParams, V, XY []uint32, password []byte) {
+	s := 32 * r
+	N := p.N
+	nChunk := N / uint64(p.P)
+	nloopAll := nChunk
+	if p.flags&yescryptRW != 0 {
+		if p.T <= 1 {
+			if p.T != 0 {
+				nloopAll *= 2
+			}
+			nloopAll = (nloopAll + 2) / 3
+		} else {
+			nloopAll *= uint64(p.T) - 1
+		}
+	} else if p.T != 0 {
+		if p.T == 1 {
+			nloopAll += (nloopAll + 1) / 2
+		}
+		nloopAll *= uint64(p.T)
+	}
+	nloopRW := uint64(0)
+	if p.flags&yescryptRW != 0 {
+		nloopRW = nloopAll / uint64(p.P)
+	}
+	nChunk &^= 1
+	nloopAll = (nloopAll + 1) &^ 1
+	nloopRW = (nloopRW + 1) &^ 1
+
+	ctxs := make([]*pwxformCtx, p.P)
+	vChunk := uint64(0)
+	for i := 0; i < int(p.P); i++ {
+		np := nChunk
+		if i == int(p.P)-1 {
+			np = N - vChunk
+		}
+		Bp := B[128*r*i : 128*r*(i+1)]
+		Vp := V[uint64(s)*vChunk:]
+		if p.flags&yescryptRW != 0 {
+			ctx := &pwxformCtx{S: make([]uint32, sWords)}
+			smix1(Bp, 1, sWords/32, p.flags&^yescryptRW, ctx.S, XY, nil)
+			ctx.s2 = 0
+			ctx.s1 = ctx.s2 + (1<<sWidth)*pwxSimple*2
+			ctx.s0 = ctx.s1 + (1<<sWidth)*pwxSimple*2
+			ctxs[i] = ctx
+			if i == 0 {
+				copy(password, hmacSHA256(Bp[128*r-64:], password))
+			}
+		}
+		smix1(Bp, r, np, p.flags, Vp, XY, ctxs[i])
+		smix2(Bp, r, p2floor(np), nloopRW, p.flags, Vp, XY, ctxs[i])
+		vChunk += nChunk
+	}
+	for i := 0; i < int(p.P); i++ {
+		smix2(B[128*r*i:128*r*(i+1)], r, N, nloopAll-nloopRW, p.flags&^yescryptRW, V, XY, ctxs[i])
+	}
+}
+
+// loadBlocks copies B into X with the SIMD shuffled word order of the
+// reference implementation, which pwxform depends on
+func loadBlocks(X []uint32, B []byte, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			X[k*16+i] = binary.LittleEndian.Uint32(B[(k*16+i*5%16)*4:])
+		}
+	}
+}
+
+// storeBlocks undoes loadBlocks
+func storeBlocks(B []byte, X []uint32, r int) {
+	for k := 0; k < 2*r; k++ {
+		for i := 0; i < 16; i++ {
+			binary.LittleEndian.PutUint32(B[(k*16+i*5%16)*4:], X[k*16+i])
+		}
+	}
+}
+
+// smix1 fills V with N successive states of B
+func smix1(B []byte, r int, N uint64, flags uint32, V, XY []uint32, ctx *pwxformCtx) {
+	s := 32 * r
+	X, Y := XY[:s], XY[s:2*s]
+	loadBlocks(X, B, r)
+	for i := uint64(0); i < N; i++ {
+		copy(V[i*uint64(s):(i+1)*uint64(s)], X)
+		if flags&yescryptRW != 0 && i > 1 {
+			j := wrap(integerify(X, r), i)
+			xorBlocks(X, V[j*uint64(s):(j+1)*uint64(s)])
+		}
+		if ctx != nil {
+			blockmixPwxform(X, r, ctx)
+		} else {
+			blockmixSalsa8(X, Y, r)
+		}
+	}
+	storeBlocks(B, X, r)
+}
+
+// smix2 mixes B with nloop pseudo-random states of V, writing them back
+// in RW mode
+func smix2(B []byte, r int, N, nloop uint64, flags uint32, V, XY []uint32, ctx *pwxformCtx) {
+	s := uint64(32 * r)
+	X, Y := XY[:s], XY[s:2*s]
+	loadBlocks(X, B, r)
+	for i := uint64(0); i < nloop; i++ {
+		j := integerify(X, r) & (N - 1)
+		Vj := V[j*s : (j+1)*s]
+		xorBlocks(X, Vj)
+		if flags&yescryptRW != 0 {
+			copy(Vj, X)
+		}
+		if ctx != nil {
+			blockmixPwxform(X, r, ctx)
+		} else {
+			blockmixSalsa8(X, Y, r)
+		}
+	}
+	storeBlocks(B, X, r)
+}
+
+// integerify reads the first 64 bits of the last block; word 13 is the
+// second word after shuffling
+func integerify(X []uint32, r int) uint64 {
+	last := X[(2*r-1)*16:]
+	return uint64(last[13])<<32 | uint64(last[0])
+}
+
+// p2floor returns the largest power of two not above x
+func p2floor(x uint64) uint64 {
+	for y := x & (x - 1); y != 0; y = x & (x - 1) {
+		x = y
+	}
+	return x
+}
+
+// wrap maps x into the window of the last p2floor(i) states before i
+func wrap(x, i uint64) uint64 {
+	n := p2floor(i)
+	return (x & (n - 1)) + (i - n)
+}
+
+// xorBlocks sets dst to dst xor src
+func xorBlocks(dst, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// blockmixSalsa8 is scrypt's BlockMix with Salsa20/8
+func blockmixSalsa8(B, Y []uint32, r int) {
+	var X [16]uint32
+	copy(X[:], B[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		xorBlocks(X[:], B[i*16:(i+1)*16])
+		salsa20(X[:], 8)
+		copy(Y[i*16:], X[:])
+	}
+	for i := 0; i < r; i++ {
+		copy(B[i*16:(i+1)*16], Y[2*i*16:(2*i+1)*16])
+		copy(B[(i+r)*16:(i+r+1)*16], Y[(2*i+1)*16:(2*i+2)*16])
+	}
+}
+
+// blockmixPwxform is yescrypt's BlockMix with pwxform and Salsa20/2
+func blockmixPwxform(B []uint32, r int, ctx *pwxformCtx) {
+	const pwxWords = pwxGather * pwxSimple * 2
+	r1 := 2 * r
+	var X [pwxWords]uint32
+	copy(X[:], B[(r1-1)*pwxWords:])
+	for i := 0; i < r1; i++ {
+		if r1 > 1 {
+			xorBlocks(X[:], B[i*pwxWords:(i+1)*pwxWords])
+		}
+		pwxform(X[:], ctx)
+		copy(B[i*pwxWords:], X[:])
+	}
+	i := r1 - 1
+	salsa20(B[i*16:(i+1)*16], 2)
+}
+
+// pwxform runs the parallel wide transformation on one 64 byte block
+func pwxform(X []uint32, ctx *pwxformCtx) {
+	S := ctx.S
+	s0, s1, s2, w := ctx.s0, ctx.s1, ctx.s2, ctx.w
+	for i := 0; i < pwxRounds; i++ {
+		for j := 0; j < pwxGather; j++ {
+			lane := X[j*pwxSimple*2:]
+			p0 := s0 + int(lane[0]&sMask)/4
+			p1 := s1 + int(lane[1]&sMask)/4
+			for k := 0; k < pwxSimple; k++ {
+				v0 := uint64(S[p0+2*k+1])<<32 | uint64(S[p0+2*k])
+				v1 := uint64(S[p1+2*k+1])<<32 | uint64(S[p1+2*k])
+				x := uint64(lane[2*k+1]) * uint64(lane[2*k])
+				x += v0
+				x ^= v1
+				lane[2*k], lane[2*k+1] = uint32(x), uint32(x>>32)
+				if i != 0 && i != pwxRounds-1 {
+					S[s2+2*w], S[s2+2*w+1] = uint32(x), uint32(x>>32)
+					w++
+				}
+			}
+		}
+	}
+	ctx.s0, ctx.s1, ctx.s2 = s2, s0, s1
+	ctx.w = w & ((1<<sWidth)*pwxSimple - 1)
+}
+
+// salsa20 applies the Salsa20 core with the given number of rounds to a
+// shuffled block
+func salsa20(B []uint32, rounds int) {
+	var x [16]uint32
+	for i := 0; i < 16; i++ {
+		x[i*5%16] = B[i]
+	}
+	rotl := bits.RotateLeft32
+	for i := 0; i < rounds; i += 2 {
+		x[4] ^= rotl(x[0]+x[12], 7)
+		x[8] ^= rotl(x[4]+x[0], 9)
+		x[12] ^= rotl(x[8]+x[4], 13)
+		x[0] ^= rotl(x[12]+x[8], 18)
+		x[9] ^= rotl(x[5]+x[1], 7)
+		x[13] ^= rotl(x[9]+x[5], 9)
+		x[1] ^= rotl(x[13]+x[9], 13)
+		x[5] ^= rotl(x[1]+x[13], 18)
+		x[14] ^= rotl(x[10]+x[6], 7)
+		x[2] ^= rotl(x[14]+x[10], 9)
+		x[6] ^= rotl(x[2]+x[14], 13)
+		x[10] ^= rotl(x[6]+x[2], 18)
+		x[3] ^= rotl(x[15]+x[11], 7)
+		x[7] ^= rotl(x[3]+x[15], 9)
+		x[11] ^= rotl(x[7]+x[3], 13)
+		x[15] ^= rotl(x[11]+x[7], 18)
+
+		x[1] ^= rotl(x[0]+x[3], 7)
+		x[2] ^= rotl(x[1]+x[0], 9)
+		x[3] ^= rotl(x[2]+x[1], 13)
+		x[0] ^= rotl(x[3]+x[2], 18)
+		x[6] ^= rotl(x[5]+x[4], 7)
+		x[7] ^= rotl(x[6]+x[5], 9)
+		x[4] ^= rotl(x[7]+x[6], 13)
+		x[5] ^= rotl(x[4]+x[7], 18)
+		x[11] ^= rotl(x[10]+x[9], 7)
+		x[8] ^= rotl(x[11]+x[10], 9)
+		x[9] ^= rotl(x[8]+x[11], 13)
+		x[10] ^= rotl(x[9]+x[8], 18)
+		x[12] ^= rotl(x[15]+x[14], 7)
+		x[13] ^= rotl(x[12]+x[15], 9)
+		x[14] ^= rotl(x[13]+x[12], 13)
+		x[15] ^= rotl(x[14]+x[13], 18)
+	}
+	for i := 0; i < 16; i++ {
+		B[i] += x[i*5%16]
+	}
+}
+
+// encode64 encodes src in the crypt(3) alphabet, three bytes at a time
+// with the least significant bits first
+func encode64(src []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(src); {
+		value, nbits := uint32(0), 0
+		for nbits < 24 && i < len(src) {
+			value |= uint32(src[i]) << nbits
+			nbits += 8
+			i++
+		}
+		for ; nbits > 0; nbits -= 6 {
+			b.WriteByte(itoa64[value&0x3f])
+			value >>= 6
+		}
+	}
+	return b.String()
+}
+
+// decode64 undoes encode64, refusing strings encode64 cannot produce
+func decode64(src string) ([]byte, bool) {
+	var dst []byte
+	for len(src) > 0 {
+		value, nbits := uint32(0), 0
+		for nbits < 24 && len(src) > 0 {
+			c := strings.IndexByte(itoa64, src[0])
+			if c < 0 {
+				return nil, false
+			}
+			value |= uint32(c) << nbits
+			nbits += 6
+			src = src[1:]
+		}
+		if nbits < 12 {
+			return nil, false
+		}
+		for ; nbits >= 8; nbits -= 8 {
+			dst = append(dst, byte(value))
+			value >>= 8
+		}
+		if value != 0 {
+			return nil, false
+		}
+	}
+	return dst, true
+}
+
+// encode64Uint32 encodes a parameter with a variable length prefix code
+func encode64Uint32(src, min uint32) string {
+	start, end, chars, nbits := uint32(0), uint32(47), 1, uint32(0)
+	src -= min
+	for {
+		count := (end + 1 - start) << nbits
+		if src < count {
+			break
+		}
+		start = end + 1
+		end = start + (62-end)/2
+		src -= count
+		chars++
+		nbits += 6
+	}
+	out := []byte{itoa64[start+(src>>nbits)]}
+	for ; chars > 1; chars-- {
+		nbits -= 6
+		out = append(out, itoa64[(src>>nbits)&0x3f])
+	}
+	return string(out)
+}
+
+// decode64Uint32 undoes encode64Uint32 and returns the rest of s
+func decode64Uint32(s string, min uint32) (uint32, string, bool) {
+	next := func() (uint32, bool) {
+		if s == "" {
+			return 0, false
+		}
+		c := strings.IndexByte(itoa64, s[0])
+		s = s[1:]
+		return uint32(c), c >= 0
+	}
+	c, ok := next()
+	if !ok {
+		return 0, s, false
+	}
+	start, end, chars, nbits := uint32(0), uint32(47), 1, uint32(0)
+	value := min
+	for c > end {
+		value += (end + 1 - start) << nbits
+		start = end + 1
+		end = start + (62-end)/2
+		chars++
+		nbits += 6
+	}
+	value += (c - start) << nbits
+	for ; chars > 1; chars-- {
+		if c, ok = next(); !ok {
+			return 0, s, false
+		}
+		nbits -= 6
+		value += c << nbits
+	}
+	return value, s, true
+}
@@ -0,0 +1,440 @@
+package doctor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/bariiss/pam-auth/util/pamconf"
+	"github.com/bariiss/pam-auth/util/sysroot"
+)
+
+// Status is the verdict of a single check
+type Status string
+
+// Check verdicts. Skip marks checks that do not apply to this system.
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	// Fix suggests how to resolve a warning or failure
+	Fix string `json:"fix,omitempty"`
+}
+
+// Build describes what this binary was compiled with
+type Build struct {
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	GoVersion string   `json:"go_version"`
+	RealPAM   bool     `json:"real_pam"`
+	Backends  []string `json:"backends"`
+}
+
+// Report is the outcome of Run
+type Report struct {
+	Build  Build   `json:"build"`
+	Checks []Check `json:"checks"`
+}
+
+// Failed reports whether any check failed
+func (r Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Options selects what Run inspects
+type Options struct {
+	// Service is the PAM service file to validate (default "login", the
+	// service real PAM authentication uses)
+	Service string
+	// Backends lists the backends compiled into the binary
+	Backends []string
+	// Root holds the system files to inspect (default the host)
+	Root sysroot.Root
+}
+
+// Locations searched for NSS modules and unix_chkpwd
+var (
+	libDirs = []string{
+		"/lib", "/lib64", "/usr/lib", "/usr/lib64",
+		"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu",
+		"/lib/aarch64-linux-gnu", "/usr/lib/aarch64-linux-gnu",
+	}
+	chkpwdPaths = []string{"/usr/sbin/unix_chkpwd", "/sbin/unix_chkpwd", "/usr/bin/unix_chkpwd"}
+)
+
+// Run inspects the system and returns the report
+func Run(opts Options) Report {
+	if opts.Service == "" {
+		opts.Service = "login"
+	}
+	report := Report{Build: Build{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		RealPAM:   pam.RealPAMCompiled,
+		Backends:  opts.Backends,
+	}}
+
+	report.Checks = append(report.Checks,
+		checkBuild(),
+		checkPrivileges(),
+		checkShadow(opts.Root),
+		checkChkpwd(opts.Root),
+		checkNSSwitch(opts.Root),
+		checkPAMService(opts.Root, opts.Service),
+		checkSELinux(),
+		checkAppArmor(),
+	)
+	return report
+}
+
+// linuxOnly returns a skipped check on other platforms
+func linuxOnly(name string) (Check, bool) {
+	if runtime.GOOS == "linux" {
+		return Check{}, false
+	}
+	return Check{Name: name, Status: Skip, Detail: "only checked on Linux"}, true
+}
+
+// checkBuild reports whether real PAM support was compiled in
+func checkBuild() Check {
+	if check, skip := linuxOnly("build"); skip {
+		return check
+	}
+	if pam.RealPAMCompiled {
+		return Check{Name: "build", Status: OK, Detail: "real PAM support compiled in (pam build tag)"}
+	}
+	return Check{
+		Name:   "build",
+		Status: Warn,
+		Detail: "built without the pam tag: --real-pam falls back to getent and cannot verify passwords",
+		Fix:    "install the libpam headers (libpam0g-dev or pam-devel) and rebuild with `make linux-pam` (go build -tags pam)",
+	}
+}
+
+// capabilityNames maps capability bits to their names (linux/capability.h)
+var capabilityNames = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_FSETID",
+	"CAP_KILL", "CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP", "CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK",
+	"CAP_IPC_OWNER", "CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE", "CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD", "CAP_LEASE", "CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL", "CAP_SETFCAP", "CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG",
+	"CAP_WAKE_ALARM", "CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// capabilities that matter for authentication and the servers
+var relevantCapabilities = []string{
+	"CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_SETUID", "CAP_SETGID", "CAP_AUDIT_WRITE", "CAP_NET_BIND_SERVICE",
+}
+
+// EffectiveCapabilities returns the names of the effective capabilities of
+// this process from /proc/self/status
+func EffectiveCapabilities() ([]string, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CapEff: %w", err)
+		}
+		var names []string
+		for bit, name := range capabilityNames {
+			if mask&(1<<bit) != 0 {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no CapEff line in /proc/self/status")
+}
+
+// checkPrivileges reports the effective uid and capabilities
+func checkPrivileges() Check {
+	if check, skip := linuxOnly("privileges"); skip {
+		return check
+	}
+	caps, err := EffectiveCapabilities()
+	if err != nil {
+		return Check{Name: "privileges", Status: Warn, Detail: err.Error(), Fix: "mount /proc to inspect capabilities"}
+	}
+
+	held := make(map[string]bool)
+	for _, name := range caps {
+		held[name] = true
+	}
+	var relevant []string
+	for _, name := range relevantCapabilities {
+		if held[name] {
+			relevant = append(relevant, name)
+		}
+	}
+	summary := "none"
+	if len(relevant) > 0 {
+		summary = strings.Join(relevant, ", ")
+	}
+	detail := fmt.Sprintf("euid %d, effective capabilities: %s", os.Geteuid(), summary)
+
+	if held["CAP_DAC_READ_SEARCH"] || held["CAP_DAC_OVERRIDE"] {
+		return Check{Name: "privileges", Status: OK, Detail: detail}
+	}
+	return Check{
+		Name:   "privileges",
+		Status: Warn,
+		Detail: detail + " - PAM can only verify the password of the invoking user",
+		Fix:    "run as root (sudo), or grant the binary `setcap cap_dac_read_search+ep pam-auth`",
+	}
+}
+
+// checkShadow reports whether the shadow database is readable
+func checkShadow(root sysroot.Root) Check {
+	if check, skip := linuxOnly("shadow"); skip {
+		return check
+	}
+	path := root.Path(sysroot.ShadowFile)
+	file, err := os.Open(path)
+	if err == nil {
+		file.Close()
+		return Check{Name: "shadow", Status: OK, Detail: path + " is readable"}
+	}
+	if os.IsNotExist(err) {
+		return Check{Name: "shadow", Status: Warn, Detail: path + " does not exist", Fix: "run pwconv to move password hashes into /etc/shadow"}
+	}
+	return Check{
+		Name:   "shadow",
+		Status: Warn,
+		Detail: fmt.Sprintf("%s is not readable by uid %d: pam_unix must use unix_chkpwd", path, os.Getuid()),
+		Fix:    "run as root, or add the service account to the shadow group (usermod -aG shadow USER)",
+	}
+}
+
+// checkChkpwd reports whether pam_unix's setuid helper is installed
+func checkChkpwd(root sysroot.Root) Check {
+	if check, skip := linuxOnly("unix_chkpwd"); skip {
+		return check
+	}
+	for _, path := range root.Paths(chkpwdPaths) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Mode()&(os.ModeSetuid|os.ModeSetgid) == 0 {
+			return Check{
+				Name:   "unix_chkpwd",
+				Status: Warn,
+				Detail: path + " is neither setuid nor setgid: unprivileged callers cannot verify passwords",
+				Fix:    "chmod u+s " + path + " (or g+s with group shadow, as your distribution ships it)",
+			}
+		}
+		return Check{Name: "unix_chkpwd", Status: OK, Detail: fmt.Sprintf("%s (%s)", path, info.Mode())}
+	}
+	return Check{
+		Name:   "unix_chkpwd",
+		Status: Warn,
+		Detail: "unix_chkpwd not found: pam_unix cannot verify passwords without root",
+		Fix:    "install the package providing pam_unix (libpam-modules-bin on Debian, pam on Fedora)",
+	}
+}
+
+// NSSwitchSources returns the sources configured for each database in an
+// nsswitch.conf file
+func NSSwitchSources(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sources := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		database, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			// Skip [NOTFOUND=return] style actions
+			if !strings.HasPrefix(field, "[") {
+				sources[strings.TrimSpace(database)] = append(sources[strings.TrimSpace(database)], field)
+			}
+		}
+	}
+	return sources, scanner.Err()
+}
+
+// checkNSSwitch reports the passwd, group and shadow sources and whether
+// the pam-auth NSS module they reference is installed
+func checkNSSwitch(root sysroot.Root) Check {
+	if check, skip := linuxOnly("nsswitch"); skip {
+		return check
+	}
+	sources, err := NSSwitchSources(root.Path(sysroot.NSSwitchFile))
+	if err != nil {
+		return Check{Name: "nsswitch", Status: Warn, Detail: err.Error(), Fix: "create /etc/nsswitch.conf with at least \"passwd: files\" and \"group: files\""}
+	}
+
+	var parts, missing []string
+	usesPamauth := false
+	for _, database := range []string{"passwd", "group", "shadow"} {
+		list := sources[database]
+		if len(list) == 0 {
+			missing = append(missing, database)
+			continue
+		}
+		parts = append(parts, database+": "+strings.Join(list, " "))
+		for _, source := range list {
+			usesPamauth = usesPamauth || source == "pamauth"
+		}
+	}
+	detail := strings.Join(parts, "; ")
+
+	if usesPamauth && findFile(root.Paths(libDirs), "libnss_pamauth.so.2") == "" {
+		return Check{
+			Name:   "nsswitch",
+			Status: Fail,
+			Detail: detail + " - the pamauth source is configured but libnss_pamauth.so.2 is not installed",
+			Fix:    "build and install the NSS module (make nss-module) or remove pamauth from /etc/nsswitch.conf",
+		}
+	}
+	if len(missing) > 0 {
+		return Check{
+			Name:   "nsswitch",
+			Status: Warn,
+			Detail: fmt.Sprintf("%s - no sources for %s (glibc falls back to its defaults)", detail, strings.Join(missing, ", ")),
+			Fix:    "add explicit lines such as \"" + missing[0] + ": files\" to /etc/nsswitch.conf",
+		}
+	}
+	return Check{Name: "nsswitch", Status: OK, Detail: detail}
+}
+
+// checkPAMService lints the service file and every file it includes
+func checkPAMService(root sysroot.Root, service string) Check {
+	name := "pam service " + service
+	if check, skip := linuxOnly(name); skip {
+		return check
+	}
+	dir := root.Path(sysroot.PAMDir)
+	path := filepath.Join(dir, service)
+	diags, err := pamconf.Lint(service, pamconf.Options{Dir: dir, ModuleDirs: root.Paths(pamconf.ModuleDirs)})
+	if err != nil {
+		return Check{
+			Name:   name,
+			Status: Fail,
+			Detail: err.Error(),
+			Fix:    "install the PAM configuration for " + service + " or pass --service with an existing /etc/pam.d file",
+		}
+	}
+
+	var errs, warnings []string
+	for _, d := range diags {
+		if d.Severity == pamconf.Error {
+			errs = append(errs, d.String())
+		} else {
+			warnings = append(warnings, d.String())
+		}
+	}
+	fix := "run `pam-auth pam lint " + service + "` and fix the listed lines"
+	switch {
+	case len(errs) > 0:
+		return Check{Name: name, Status: Fail, Detail: strings.Join(errs, "; "), Fix: fix}
+	case len(warnings) > 0:
+		return Check{Name: name, Status: Warn, Detail: strings.Join(warnings, "; "), Fix: fix}
+	}
+	return Check{Name: name, Status: OK, Detail: path + ": all modules found, no problems"}
+}
+
+// checkSELinux reports the SELinux mode
+func checkSELinux() Check {
+	if check, skip := linuxOnly("selinux"); skip {
+		return check
+	}
+	data, err := os.ReadFile("/sys/fs/selinux/enforce")
+	if err != nil {
+		return Check{Name: "selinux", Status: OK, Detail: "disabled"}
+	}
+	if strings.TrimSpace(string(data)) == "1" {
+		return Check{
+			Name:   "selinux",
+			Status: OK,
+			Detail: "enforcing - if logins fail only here, look for denials with `ausearch -m avc -ts recent`",
+		}
+	}
+	return Check{Name: "selinux", Status: OK, Detail: "permissive"}
+}
+
+// checkAppArmor reports whether AppArmor confines this process
+func checkAppArmor() Check {
+	if check, skip := linuxOnly("apparmor"); skip {
+		return check
+	}
+	enabled, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	if err != nil || strings.TrimSpace(string(enabled)) != "Y" {
+		return Check{Name: "apparmor", Status: OK, Detail: "disabled"}
+	}
+
+	profile := "unconfined"
+	for _, path := range []string{"/proc/self/attr/apparmor/current", "/proc/self/attr/current"} {
+		if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+			profile = strings.TrimRight(strings.TrimSpace(string(data)), "\x00")
+			break
+		}
+	}
+	if strings.HasSuffix(profile, "(enforce)") {
+		return Check{
+			Name:   "apparmor",
+			Status: Warn,
+			Detail: "enabled, this process is confined by " + profile,
+			Fix:    "allow /etc/shadow, /etc/pam.d and unix_chkpwd in the profile, or switch it to complain mode with aa-complain",
+		}
+	}
+	return Check{Name: "apparmor", Status: OK, Detail: "enabled, this process is " + profile}
+}
+
+// findFile returns the first dirs/name that exists
+func findFile(dirs []string, name string) string {
+	for _, dir := range dirs {
+		if path := filepath.Join(dir, name); exists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// exists reports whether path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
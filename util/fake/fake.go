@@ -0,0 +1,67 @@
+package fake
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+)
+
+// DefaultPassword is the password the fake backend accepts by default
+const DefaultPassword = "fake"
+
+// Authenticator is a deterministic in-memory backend for benchmarks and
+// tests. It accepts every username with Password after a fixed Latency and
+// reports a steady fraction of attempts as unreachable, so repeated runs
+// give comparable numbers.
+type Authenticator struct {
+	// Password is accepted for every username
+	Password string
+	// Latency is added to every attempt
+	Latency time.Duration
+	// ErrorRate is the fraction (0 to 1) of attempts reported unreachable
+	ErrorRate float64
+	// Groups are returned on success
+	Groups []string
+
+	attempts atomic.Uint64
+}
+
+// New returns a fake backend accepting password
+func New(password string) *Authenticator {
+	return &Authenticator{Password: password, Groups: []string{"fake"}}
+}
+
+// Authenticate implements auth.Authenticator
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) auth.Result {
+	attempt := a.attempts.Add(1)
+	if a.Latency > 0 {
+		timer := time.NewTimer(a.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return auth.Aborted(ctx, "fake", username)
+		}
+	} else if ctx.Err() != nil {
+		return auth.Aborted(ctx, "fake", username)
+	}
+
+	if a.failing(attempt) {
+		return auth.Unreachable("fake", username, "simulated backend error")
+	}
+	if password != a.Password {
+		return auth.Failure("fake", username, "invalid credentials")
+	}
+	return auth.Result{Username: username, Backend: "fake", Success: true, Groups: a.Groups}
+}
+
+// failing spreads ErrorRate evenly over the attempts: attempt n fails when
+// the running count of expected errors grows by one at n
+func (a *Authenticator) failing(attempt uint64) bool {
+	if a.ErrorRate <= 0 {
+		return false
+	}
+	return uint64(float64(attempt)*a.ErrorRate) != uint64(float64(attempt-1)*a.ErrorRate)
+}
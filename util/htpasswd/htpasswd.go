@@ -0,0 +1,302 @@
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/crypt"
+)
+
+// ErrNoSuchUser is returned when a user has no entry in the file
+var ErrNoSuchUser = errors.New("no such user in htpasswd file")
+
+// File is an Apache htpasswd file. It is reloaded automatically when it
+// changes on disk; comments and entry order are preserved when writing.
+type File struct {
+	// Path is the location of the htpasswd file
+	Path string
+	// UpgradeLegacy re-hashes non-bcrypt entries to bcrypt after a successful login
+	UpgradeLegacy bool
+	// BcryptCost is the cost for new hashes (0 selects the bcrypt default)
+	BcryptCost int
+	// Plaintext accepts entries written by htpasswd -p, which store the
+	// password as it is. They are the only ones CHAP and MS-CHAPv2 can use,
+	// but look like crypt(3) DES hashes, so enable it only for files
+	// without those.
+	Plaintext bool
+
+	mu      sync.Mutex
+	lines   []string
+	index   map[string]int
+	modTime time.Time
+	size    int64
+}
+
+// Open loads the htpasswd file at path. With create set a missing file is
+// treated as empty and created on the first write.
+func Open(path string, create bool) (*File, error) {
+	f := &File{Path: path}
+	if err := f.reload(); err != nil {
+		if !(create && errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		f.index = make(map[string]int)
+	}
+	return f, nil
+}
+
+// reload re-reads the file when its size or modification time changed.
+// Callers must hold f.mu (or own f exclusively).
+func (f *File) reload() error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	if f.index != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if user, _, ok := strings.Cut(trimmed, ":"); ok {
+				index[user] = len(lines)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	f.lines, f.index = lines, index
+	f.modTime, f.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// hash returns the stored hash for username
+func (f *File) hash(username string) (string, bool) {
+	i, ok := f.index[username]
+	if !ok {
+		return "", false
+	}
+	_, hash, _ := strings.Cut(strings.TrimSpace(f.lines[i]), ":")
+	// Some tools append extra colon separated fields after the hash
+	hash, _, _ = strings.Cut(hash, ":")
+	return hash, true
+}
+
+// Hash returns username's stored hash
+func (f *File) Hash(username string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.reload(); err != nil {
+		return "", err
+	}
+	hash, ok := f.hash(username)
+	if !ok {
+		return "", ErrNoSuchUser
+	}
+	return hash, nil
+}
+
+// Verify checks username's password and reports the hash scheme that was used
+func (f *File) Verify(username, password string) (bool, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.reload(); err != nil {
+		return false, "", err
+	}
+	hash, ok := f.hash(username)
+	if !ok {
+		return false, "", ErrNoSuchUser
+	}
+	if f.plaintext(hash) {
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, SchemePlain, nil
+	}
+	match, err := crypt.Verify(hash, password)
+	return match, crypt.Scheme(hash), err
+}
+
+// SchemePlain is the scheme Verify reports for plaintext entries
+const SchemePlain = "plain"
+
+// plaintext reports whether hash is a password stored as it is
+func (f *File) plaintext(hash string) bool {
+	return f.Plaintext && hash != "" && crypt.Scheme(hash) == crypt.SchemeUnknown
+}
+
+// CleartextPassword implements auth.PasswordSource for plaintext entries
+func (f *File) CleartextPassword(username string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.reload(); err != nil {
+		return "", false
+	}
+	hash, ok := f.hash(username)
+	if !ok || !f.plaintext(hash) {
+		return "", false
+	}
+	return hash, true
+}
+
+// Authenticate implements auth.Authenticator
+func (f *File) Authenticate(ctx context.Context, username, password string) auth.Result {
+	if ctx.Err() != nil {
+		return auth.Aborted(ctx, "htpasswd", username)
+	}
+	match, scheme, err := f.Verify(username, password)
+	if err != nil {
+		return auth.Failure("htpasswd", username, err.Error())
+	}
+	if !match {
+		return auth.Failure("htpasswd", username, "password mismatch")
+	}
+
+	if f.UpgradeLegacy && scheme != crypt.SchemeBcrypt {
+		if err := f.Set(username, password); err != nil {
+			fmt.Printf("⚠️ Could not upgrade %s hash for %s: %v\n", scheme, username, err)
+		} else {
+			fmt.Printf("🔁 Upgraded %s hash for %s to bcrypt\n", scheme, username)
+		}
+	}
+	return auth.Result{Username: username, Backend: "htpasswd", Success: true}
+}
+
+// Ping implements auth.Pinger by checking that the file can still be read
+func (f *File) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reload()
+}
+
+// Set adds or replaces username's entry with a bcrypt hash of password
+func (f *File) Set(username, password string) error {
+	if username == "" || strings.ContainsAny(username, ":\n") {
+		return fmt.Errorf("invalid username: %q", username)
+	}
+	hash, err := crypt.Bcrypt(password, f.BcryptCost)
+	if err != nil {
+		return err
+	}
+	// Apache writes bcrypt entries with the $2y$ prefix
+	hash = "$2y$" + strings.TrimPrefix(hash, "$2a$")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.reload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	entry := username + ":" + hash
+	if i, ok := f.index[username]; ok {
+		f.lines[i] = entry
+	} else {
+		f.index[username] = len(f.lines)
+		f.lines = append(f.lines, entry)
+	}
+	return f.save()
+}
+
+// Delete removes username's entry
+func (f *File) Delete(username string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.reload(); err != nil {
+		return err
+	}
+
+	i, ok := f.index[username]
+	if !ok {
+		return ErrNoSuchUser
+	}
+	f.lines = append(f.lines[:i], f.lines[i+1:]...)
+	delete(f.index, username)
+	for user, j := range f.index {
+		if j > i {
+			f.index[user] = j - 1
+		}
+	}
+	return f.save()
+}
+
+// Users lists the users in file order
+func (f *File) Users() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	users := make([]string, 0, len(f.index))
+	for _, line := range f.lines {
+		trimmed := strings.TrimSpace(line)
+		if user, _, ok := strings.Cut(trimmed, ":"); ok && !strings.HasPrefix(trimmed, "#") {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// save writes the file atomically, keeping the permissions of the original.
+// Callers must hold f.mu.
+func (f *File) save() error {
+	mode := os.FileMode(0640)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	var buf bytes.Buffer
+	for _, line := range f.lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".htpasswd-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return err
+	}
+
+	// Record the new state so the next access does not reload needlessly
+	if info, err := os.Stat(f.Path); err == nil {
+		f.modTime, f.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package krb5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// marshalCCache encodes a version 4 FILE credential cache holding the TGT
+// from asRep, in the format read by MIT krb5 and gokrb5's credentials package
+func marshalCCache(realm string, cname types.PrincipalName, asRep messages.ASRep) ([]byte, error) {
+	ticket, err := asRep.Ticket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	part := asRep.DecryptedEncPart
+
+	var b bytes.Buffer
+	b.Write([]byte{5, 4})
+
+	// Header: a single DeltaTime tag with zero offset
+	put16(&b, 12)
+	put16(&b, 1)
+	put16(&b, 8)
+	put32(&b, 0)
+	put32(&b, 0)
+
+	writePrincipal(&b, realm, cname)
+
+	// Credential entry
+	writePrincipal(&b, realm, cname)
+	writePrincipal(&b, part.SRealm, part.SName)
+	put16(&b, uint16(part.Key.KeyType))
+	writeData(&b, part.Key.KeyValue)
+	putTime(&b, part.AuthTime)
+	putTime(&b, part.StartTime)
+	putTime(&b, part.EndTime)
+	putTime(&b, part.RenewTill)
+	b.WriteByte(0) // is_skey
+
+	flags := make([]byte, 4)
+	copy(flags, part.Flags.Bytes)
+	b.Write(flags)
+
+	put32(&b, 0) // addresses
+	put32(&b, 0) // authdata
+	writeData(&b, ticket)
+	writeData(&b, nil) // second ticket
+	return b.Bytes(), nil
+}
+
+// writePrincipal encodes a principal with its name type, realm and components
+func writePrincipal(b *bytes.Buffer, realm string, name types.PrincipalName) {
+	put32(b, uint32(name.NameType))
+	put32(b, uint32(len(name.NameString)))
+	writeData(b, []byte(realm))
+	for _, component := range name.NameString {
+		writeData(b, []byte(component))
+	}
+}
+
+// writeData encodes a 32 bit length followed by the bytes
+func writeData(b *bytes.Buffer, data []byte) {
+	put32(b, uint32(len(data)))
+	b.Write(data)
+}
+
+// putTime encodes a time as 32 bit seconds since the epoch (zero stays zero)
+func putTime(b *bytes.Buffer, t time.Time) {
+	if t.IsZero() {
+		put32(b, 0)
+		return
+	}
+	put32(b, uint32(t.Unix()))
+}
+
+func put16(b *bytes.Buffer, v uint16) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func put32(b *bytes.Buffer, v uint32) {
+	binary.Write(b, binary.BigEndian, v)
+}
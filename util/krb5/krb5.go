@@ -0,0 +1,294 @@
+package krb5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/krberror"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Authenticator verifies passwords by obtaining a TGT from the KDC. When a
+// keytab is configured the TGT is used to request a ticket for the local
+// host principal, which is decrypted with the keytab: only the real KDC
+// knows that key, so a spoofed KDC that accepts any password is detected.
+type Authenticator struct {
+	// Realm is appended to usernames without an explicit @REALM
+	Realm string
+	// AllowedRealms are the other realms usernames may name with @REALM;
+	// logins for any other realm are refused
+	AllowedRealms []string
+	// Config is the parsed krb5.conf used to locate KDCs
+	Config *config.Config
+	// Keytab holds the key of ServicePrincipal
+	Keytab *keytab.Keytab
+	// ServicePrincipal is the host principal used for verification, e.g. host/server.example.com
+	ServicePrincipal string
+	// AllowUnverified accepts a TGT without the keytab check (vulnerable to KDC spoofing)
+	AllowUnverified bool
+	// CCachePath, when set, stores the TGT in a credential cache for the user.
+	// "%{uid}" and "%{username}" are replaced like in MIT's ccache_name.
+	CCachePath string
+}
+
+// Options configures New
+type Options struct {
+	Realm            string
+	AllowedRealms    []string
+	ConfigPath       string
+	KDCs             []string
+	KeytabPath       string
+	ServicePrincipal string
+	AllowUnverified  bool
+	CCachePath       string
+}
+
+// New builds an Authenticator from options. When KDCs are given the
+// configuration is generated instead of reading ConfigPath.
+func New(opts Options) (*Authenticator, error) {
+	var cfg *config.Config
+	var err error
+	if len(opts.KDCs) > 0 {
+		if opts.Realm == "" {
+			return nil, errors.New("a realm is required when KDCs are given explicitly")
+		}
+		cfg, err = config.NewFromString(generateConfig(opts.Realm, opts.KDCs))
+	} else {
+		path := opts.ConfigPath
+		if path == "" {
+			path = os.Getenv("KRB5_CONFIG")
+		}
+		if path == "" {
+			path = "/etc/krb5.conf"
+		}
+		cfg, err = config.Load(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading Kerberos configuration: %w", err)
+	}
+
+	realm := opts.Realm
+	if realm == "" {
+		realm = cfg.LibDefaults.DefaultRealm
+	}
+	if realm == "" {
+		return nil, errors.New("no Kerberos realm configured")
+	}
+
+	a := &Authenticator{
+		Realm:            realm,
+		AllowedRealms:    opts.AllowedRealms,
+		Config:           cfg,
+		ServicePrincipal: opts.ServicePrincipal,
+		AllowUnverified:  opts.AllowUnverified,
+		CCachePath:       opts.CCachePath,
+	}
+
+	if opts.KeytabPath != "" {
+		a.Keytab, err = keytab.Load(opts.KeytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading keytab: %w", err)
+		}
+		if a.ServicePrincipal == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, err
+			}
+			a.ServicePrincipal = "host/" + strings.ToLower(hostname)
+		}
+	} else if !a.AllowUnverified {
+		return nil, errors.New("a keytab is required to verify tickets (or explicitly allow unverified logins)")
+	}
+	return a, nil
+}
+
+// generateConfig returns a minimal krb5.conf for realm served by kdcs
+func generateConfig(realm string, kdcs []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[libdefaults]\n default_realm = %s\n dns_lookup_kdc = false\n dns_lookup_realm = false\n", realm)
+	fmt.Fprintf(&b, "[realms]\n %s = {\n", realm)
+	for _, kdc := range kdcs {
+		fmt.Fprintf(&b, "  kdc = %s\n", kdc)
+	}
+	b.WriteString(" }\n")
+	return b.String()
+}
+
+// Authenticate implements auth.Authenticator. gokrb5 cannot be interrupted,
+// so a done ctx abandons the exchange, which ends on its own network timeouts
+// without writing the credential cache.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) auth.Result {
+	done := make(chan auth.Result, 1)
+	go func() {
+		done <- a.authenticate(ctx, username, password)
+	}()
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return auth.Aborted(ctx, "krb5", username)
+	}
+}
+
+// Ping implements auth.Pinger by opening a TCP connection to a KDC of the
+// realm, which proves it is reachable without an AS exchange
+func (a *Authenticator) Ping(ctx context.Context) error {
+	count, kdcs, err := a.Config.GetKDCs(a.Realm, true)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	for i := 1; i <= count; i++ {
+		kdc := kdcs[i]
+		if _, _, err := net.SplitHostPort(kdc); err != nil {
+			kdc = net.JoinHostPort(kdc, "88")
+		}
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", kdc); err == nil {
+			return conn.Close()
+		}
+	}
+	return fmt.Errorf("no KDC of %s is reachable: %w", a.Realm, err)
+}
+
+// authenticate obtains and verifies a TGT for username
+func (a *Authenticator) authenticate(ctx context.Context, username, password string) auth.Result {
+	name, realm := username, a.Realm
+	if at := strings.LastIndex(username, "@"); at >= 0 {
+		name, realm = username[:at], username[at+1:]
+	}
+	if realm != a.Realm && !slices.Contains(a.AllowedRealms, realm) {
+		return auth.Failure("krb5", username, fmt.Sprintf("realm %s is not allowed", realm))
+	}
+
+	cl := client.NewWithPassword(name, realm, password, a.Config, client.DisablePAFXFAST(true))
+	asReq, err := messages.NewASReqForTGT(realm, a.Config, cl.Credentials.CName())
+	if err != nil {
+		return auth.Failure("krb5", username, err.Error())
+	}
+	asRep, err := cl.ASExchange(realm, asReq, 0)
+	if kerr, ok := err.(krberror.Krberror); ok && kerr.RootCause == krberror.NetworkingError {
+		return auth.Unreachable("krb5", username, fmt.Sprintf("KDC unreachable: %v", err))
+	}
+	if err != nil {
+		return auth.Failure("krb5", username, fmt.Sprintf("KDC rejected credentials: %v", err))
+	}
+	fmt.Printf("🎫 Obtained TGT for %s@%s\n", name, realm)
+
+	if a.Keytab != nil {
+		if err := a.verifyTGT(cl, realm, asRep, name); err != nil {
+			return auth.Failure("krb5", username, fmt.Sprintf("KDC verification failed: %v", err))
+		}
+		fmt.Printf("🛡️ TGT verified with keytab for %s\n", a.ServicePrincipal)
+	} else {
+		fmt.Println("⚠️ TGT not verified against a keytab - KDC spoofing is possible")
+	}
+
+	if ctx.Err() != nil {
+		return auth.Aborted(ctx, "krb5", username)
+	}
+	// Local accounts only answer for principals of the default realm:
+	// alice@OTHER is not the local alice
+	if a.CCachePath != "" && realm != a.Realm {
+		fmt.Printf("⚠️ No credential cache written for %s@%s outside realm %s\n", name, realm, a.Realm)
+	} else if a.CCachePath != "" {
+		path, err := a.storeCCache(name, realm, asRep)
+		if err != nil {
+			fmt.Printf("⚠️ Could not write credential cache: %v\n", err)
+		} else {
+			fmt.Printf("💾 Credential cache written to %s\n", path)
+		}
+	}
+
+	return auth.Result{Username: username, Backend: "krb5", Success: true}
+}
+
+// verifyTGT requests a ticket for the host principal and decrypts it with the keytab
+func (a *Authenticator) verifyTGT(cl *client.Client, realm string, asRep messages.ASRep, name string) error {
+	spn := types.NewPrincipalName(nametype.KRB_NT_SRV_HST, a.ServicePrincipal)
+	_, tgsRep, err := cl.TGSREQGenerateAndExchange(spn, realm, asRep.Ticket, asRep.DecryptedEncPart.Key, false)
+	if err != nil {
+		return fmt.Errorf("requesting ticket for %s: %w", a.ServicePrincipal, err)
+	}
+
+	ticket := tgsRep.Ticket
+	if err := ticket.DecryptEncPart(a.Keytab, &spn); err != nil {
+		return fmt.Errorf("service ticket not encrypted with the keytab key: %w", err)
+	}
+
+	cname := ticket.DecryptedEncPart.CName.PrincipalNameString()
+	if cname != name || ticket.DecryptedEncPart.CRealm != realm {
+		return fmt.Errorf("service ticket issued to %s@%s", cname, ticket.DecryptedEncPart.CRealm)
+	}
+	return nil
+}
+
+// storeCCache writes the TGT to the credential cache for the user
+func (a *Authenticator) storeCCache(name, realm string, asRep messages.ASRep) (string, error) {
+	path := a.CCachePath
+	uid, gid := -1, -1
+	if u, err := user.Lookup(name); err == nil {
+		path = strings.ReplaceAll(path, "%{uid}", u.Uid)
+		fmt.Sscan(u.Uid, &uid)
+		fmt.Sscan(u.Gid, &gid)
+	} else if strings.Contains(path, "%{uid}") {
+		return "", fmt.Errorf("no local account for %s to resolve %%{uid}", name)
+	}
+	path = strings.ReplaceAll(path, "%{username}", name)
+	path = strings.TrimPrefix(path, "FILE:")
+
+	data, err := marshalCCache(realm, types.NewPrincipalName(1, name), asRep)
+	if err != nil {
+		return "", err
+	}
+	if os.Geteuid() != 0 {
+		uid, gid = -1, -1
+	}
+	if err := writeFileAtomic(path, data, 0600, uid, gid); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeFileAtomic writes data to a new temporary file next to path, owned
+// by uid and gid unless they are -1, and renames it over path. The file is
+// created exclusively and changed through its descriptor, so links planted
+// in a shared directory such as /tmp are never followed.
+func writeFileAtomic(path string, data []byte, mode os.FileMode, uid, gid int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if uid >= 0 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
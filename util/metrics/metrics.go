@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/pam"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultCheckTimeout bounds each readiness check
+const DefaultCheckTimeout = 5 * time.Second
+
+// Metrics collects the Prometheus metrics of the pam-auth servers. A nil
+// *Metrics records nothing, so servers can call it unconditionally.
+type Metrics struct {
+	registry *prometheus.Registry
+	attempts *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	lockouts *prometheus.CounterVec
+	offline  *prometheus.CounterVec
+}
+
+// New returns Metrics with the Go runtime and process collectors registered
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pam_auth_attempts_total",
+			Help: "Authentication attempts by service, backend and outcome (success, reject, error, timeout).",
+		}, []string{"service", "backend", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pam_auth_attempt_duration_seconds",
+			Help:    "Time taken by the authentication backends.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"service", "backend"}),
+		lockouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pam_auth_lockouts_total",
+			Help: "Correct passwords refused because the account is disabled or expired.",
+		}, []string{"service", "backend"}),
+		offline: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pam_auth_offline_cache_requests_total",
+			Help: "Attempts made while the backend was unreachable, by whether the offline cache served them (hit) or not (miss).",
+		}, []string{"service", "result"}),
+	}
+	m.registry.MustRegister(m.attempts, m.latency, m.lockouts, m.offline,
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// Observe records the result of one backend call made by service
+func (m *Metrics) Observe(service string, result auth.Result, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	backend := result.Backend
+	if backend == "" {
+		backend = "unknown"
+	}
+	m.attempts.WithLabelValues(service, backend, result.Outcome()).Inc()
+	m.latency.WithLabelValues(service, backend).Observe(elapsed.Seconds())
+	if result.Locked {
+		m.lockouts.WithLabelValues(service, backend).Inc()
+	}
+	switch {
+	case result.Offline:
+		m.offline.WithLabelValues(service, "hit").Inc()
+	case result.Unavailable:
+		m.offline.WithLabelValues(service, "miss").Inc()
+	}
+}
+
+// WatchPAMPool exports the saturation and counters of the real PAM
+// transaction pool
+func (m *Metrics) WatchPAMPool() {
+	gauge := func(name, help string, value func(pam.PoolStats) int) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(pam.PAMPoolStats()))
+		})
+	}
+	counter := func(name, help string, value func(pam.PoolStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(pam.PAMPoolStats()))
+		})
+	}
+	m.registry.MustRegister(
+		gauge("pam_auth_pam_pool_workers", "OS threads running PAM transactions.",
+			func(s pam.PoolStats) int { return s.Workers }),
+		gauge("pam_auth_pam_pool_busy", "PAM transactions in progress.",
+			func(s pam.PoolStats) int { return s.Busy }),
+		gauge("pam_auth_pam_pool_queued", "Requests waiting for a PAM worker.",
+			func(s pam.PoolStats) int { return s.Queued }),
+		gauge("pam_auth_pam_pool_queue_size", "Requests that may wait for a PAM worker.",
+			func(s pam.PoolStats) int { return s.QueueSize }),
+		counter("pam_auth_pam_pool_completed_total", "PAM transactions that accepted the user.",
+			func(s pam.PoolStats) uint64 { return s.Completed }),
+		counter("pam_auth_pam_pool_failed_total", "PAM transactions that rejected the user or failed.",
+			func(s pam.PoolStats) uint64 { return s.Failed }),
+		counter("pam_auth_pam_pool_refused_total", "Requests refused because the PAM queue was full.",
+			func(s pam.PoolStats) uint64 { return s.Rejected }),
+		counter("pam_auth_pam_pool_abandoned_total", "Requests whose caller gave up before PAM answered.",
+			func(s pam.PoolStats) uint64 { return s.Abandoned }),
+	)
+}
+
+// Check is a readiness check run by /readyz
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// checkResult is the /readyz report for one check
+type checkResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Handler serves /metrics, /healthz (the process is up) and /readyz (every
+// check passes, answered with 503 otherwise)
+func (m *Metrics) Handler(checks []Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready := true
+		results := make([]checkResult, 0, len(checks))
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), DefaultCheckTimeout)
+			err := check.Run(ctx)
+			cancel()
+			result := checkResult{Name: check.Name, OK: err == nil}
+			if err != nil {
+				result.Error = err.Error()
+				ready = false
+			}
+			results = append(results, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(struct {
+			Ready  bool          `json:"ready"`
+			Checks []checkResult `json:"checks"`
+		}{ready, results})
+	})
+	return mux
+}
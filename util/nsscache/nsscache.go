@@ -0,0 +1,130 @@
+package nsscache
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPath is the directory where pam-auth writes the cache read by
+// libnss_pamauth
+const DefaultPath = "/var/lib/pam-auth/nss"
+
+// Files of the cache directory, in the formats of passwd(5), group(5) and
+// shadow(5) so the NSS module can parse them with fgetpwent_r and friends
+const (
+	PasswdFile = "passwd"
+	GroupFile  = "group"
+	ShadowFile = "shadow"
+)
+
+// User is a passwd and shadow entry. No password hash is exported: the
+// password is verified by pam-auth, so the shadow entry only carries the
+// lock state and aging fields.
+type User struct {
+	Name  string
+	UID   uint32
+	GID   uint32
+	Gecos string
+	Home  string
+	Shell string
+	// Locked users get "!" instead of "*" as their shadow password
+	Locked bool
+	// LastChange is the day of the last password change since the epoch
+	LastChange int64
+	// Expire is the account expiry day since the epoch, or -1 for never
+	Expire int64
+}
+
+// Group is a group entry
+type Group struct {
+	Name    string
+	GID     uint32
+	Members []string
+}
+
+// Cache is the complete set of entries exported to NSS
+type Cache struct {
+	Users  []User
+	Groups []Group
+}
+
+// Save writes the cache files to the directory dir. Each file is replaced
+// atomically and is world readable like /etc/passwd since none contains
+// secrets.
+func (c *Cache) Save(dir string) error {
+	files, err := c.render()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range []string{GroupFile, ShadowFile, PasswdFile} {
+		if err := writeFile(filepath.Join(dir, name), files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render formats the cache files, refusing fields the colon separated
+// formats cannot hold
+func (c *Cache) render() (map[string][]byte, error) {
+	var passwd, group, shadow bytes.Buffer
+	for _, u := range c.Users {
+		for _, field := range []string{u.Name, u.Gecos, u.Home, u.Shell} {
+			if strings.ContainsAny(field, ":\n") {
+				return nil, fmt.Errorf("user %s: %q cannot be exported to NSS", u.Name, field)
+			}
+		}
+		fmt.Fprintf(&passwd, "%s:x:%d:%d:%s:%s:%s\n", u.Name, u.UID, u.GID, u.Gecos, u.Home, u.Shell)
+
+		password, expire := "*", ""
+		if u.Locked {
+			password = "!"
+		}
+		if u.Expire >= 0 {
+			expire = fmt.Sprint(u.Expire)
+		}
+		fmt.Fprintf(&shadow, "%s:%s:%d:::::%s:\n", u.Name, password, u.LastChange, expire)
+	}
+	for _, g := range c.Groups {
+		for _, field := range append([]string{g.Name}, g.Members...) {
+			if strings.ContainsAny(field, ":,\n") {
+				return nil, fmt.Errorf("group %s: %q cannot be exported to NSS", g.Name, field)
+			}
+		}
+		fmt.Fprintf(&group, "%s:x:%d:%s\n", g.Name, g.GID, strings.Join(g.Members, ","))
+	}
+	return map[string][]byte{PasswdFile: passwd.Bytes(), GroupFile: group.Bytes(), ShadowFile: shadow.Bytes()}, nil
+}
+
+// writeFile replaces path with data through a temporary file
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Day converts t to days since the epoch as used in shadow(5)
+func Day(t time.Time) int64 {
+	return t.Unix() / 86400
+}
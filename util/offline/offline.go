@@ -0,0 +1,393 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/crypt"
+)
+
+// Defaults for Cache.TTL and Cache.MaxOfflineAge
+const (
+	DefaultTTL           = 7 * 24 * time.Hour
+	DefaultMaxOfflineAge = 3 * 24 * time.Hour
+)
+
+// refreshInterval is how stale the last online time and an entry's expiry
+// may get before an otherwise unchanged online answer rewrites the cache
+const refreshInterval = time.Minute
+
+// magic starts every cache file and is authenticated with the contents
+var magic = []byte("pam-auth offline cache v1\n")
+
+// ErrBadKey is returned when the cache cannot be decrypted with the key
+var ErrBadKey = errors.New("offline cache cannot be decrypted with this key")
+
+// Entry is what the cache remembers about a user after an online login
+type Entry struct {
+	Username string `json:"username"`
+	Backend  string `json:"backend"`
+	// Verifier is an argon2id hash of the last accepted password
+	Verifier string   `json:"verifier"`
+	Groups   []string `json:"groups,omitempty"`
+	// Verified is the time of the last successful online login
+	Verified time.Time `json:"verified"`
+	// Expires is when the entry stops being usable offline
+	Expires time.Time `json:"expires"`
+}
+
+// contents is the decrypted cache file
+type contents struct {
+	// LastOnline is the last time any backend answered
+	LastOnline time.Time         `json:"last_online"`
+	Entries    map[string]*Entry `json:"entries"`
+}
+
+// Cache wraps a directory backend and keeps salted argon2id verifiers of
+// the passwords it accepted in an encrypted file. While the backend is
+// unreachable, users with a fresh entry can still log in; such results
+// have Offline set.
+type Cache struct {
+	// Backend is the wrapped authenticator
+	Backend auth.Authenticator
+	// Path is the encrypted cache file
+	Path string
+	// Key is the AES-256 key the file is sealed with (see LoadKey)
+	Key []byte
+	// TTL is how long an entry stays usable after the user's last online login
+	TTL time.Duration
+	// MaxOfflineAge refuses offline logins once no backend has answered for
+	// this long (zero disables the limit)
+	MaxOfflineAge time.Duration
+	// Params tunes the verifier hashes
+	Params crypt.Argon2Params
+	// Warnings receives problems that do not change a result, such as a
+	// failed cache update; nil discards them
+	Warnings io.Writer
+
+	mu sync.Mutex
+}
+
+// New returns a Cache for backend with the default limits
+func New(backend auth.Authenticator, path string, key []byte) *Cache {
+	return &Cache{
+		Backend:       backend,
+		Path:          path,
+		Key:           key,
+		TTL:           DefaultTTL,
+		MaxOfflineAge: DefaultMaxOfflineAge,
+		Params:        crypt.DefaultArgon2Params,
+	}
+}
+
+// LoadKey reads the 32 byte key at path, generating it with mode 0600 when
+// the file does not exist yet
+func LoadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			// Another process created it first
+			return LoadKey(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(key); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return key, file.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("offline cache key %s must be 32 bytes, got %d", path, len(key))
+	}
+	return key, nil
+}
+
+// Authenticate implements auth.Authenticator. Online answers keep the cache
+// up to date; an unreachable backend is answered from the cache.
+func (c *Cache) Authenticate(ctx context.Context, username, password string) auth.Result {
+	result := c.Backend.Authenticate(ctx, username, password)
+	if result.Err != nil {
+		return result
+	}
+	if result.Unavailable {
+		return c.authenticateOffline(result, password)
+	}
+	if err := c.learn(result, password); err != nil && c.Warnings != nil {
+		fmt.Fprintf(c.Warnings, "⚠️ Could not update the offline cache: %v\n", err)
+	}
+	return result
+}
+
+// learn records an online answer. A success stores the verifier; a reject
+// of the cached password means it was changed on the backend, so the entry
+// is dropped. The file is only rewritten when an entry changes or the
+// timestamps it keeps are more than refreshInterval old, so repeated logins
+// do not write on every attempt.
+func (c *Cache) learn(result auth.Result, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := c.load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	changed := now.Sub(data.LastOnline) >= refreshInterval
+
+	key := entryKey(result.Backend, result.Username)
+	entry := data.Entries[key]
+	if result.Success {
+		if entry == nil || !verify(entry.Verifier, password) {
+			verifier, err := crypt.Argon2id(password, c.Params)
+			if err != nil {
+				return err
+			}
+			entry = &Entry{Username: result.Username, Backend: result.Backend, Verifier: verifier}
+			data.Entries[key] = entry
+			changed = true
+		}
+		if !slices.Equal(entry.Groups, result.Groups) {
+			entry.Groups = result.Groups
+			changed = true
+		}
+		if now.Sub(entry.Verified) >= refreshInterval || entry.Expires.Sub(now) < c.TTL-refreshInterval {
+			entry.Verified = now
+			entry.Expires = now.Add(c.TTL)
+			changed = true
+		}
+	} else if entry != nil && verify(entry.Verifier, password) {
+		delete(data.Entries, key)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	data.LastOnline = now
+	return c.save(data)
+}
+
+// authenticateOffline answers an unreachable backend's failure from the cache
+func (c *Cache) authenticateOffline(online auth.Result, password string) auth.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	refuse := func(reason string) auth.Result {
+		online.Message += "; offline cache: " + reason
+		return online
+	}
+	data, err := c.load()
+	if err != nil {
+		return refuse(err.Error())
+	}
+	now := time.Now()
+	entry := data.Entries[entryKey(online.Backend, online.Username)]
+	switch {
+	case entry == nil:
+		return refuse("user has no cached credentials")
+	case !now.Before(entry.Expires):
+		return refuse(fmt.Sprintf("cached credentials expired on %s", entry.Expires.Format(time.RFC3339)))
+	case c.MaxOfflineAge > 0 && now.Sub(data.LastOnline) > c.MaxOfflineAge:
+		return refuse(fmt.Sprintf("offline for longer than %s", c.MaxOfflineAge))
+	case !verify(entry.Verifier, password):
+		return refuse("password mismatch")
+	}
+	return auth.Result{
+		Username: online.Username,
+		Backend:  online.Backend,
+		Success:  true,
+		Groups:   entry.Groups,
+		Message:  "served from the offline cache: " + online.Message,
+		Offline:  true,
+	}
+}
+
+// Groups implements auth.GroupSource from the wrapped backend, falling back
+// to the groups cached at the last online login
+func (c *Cache) Groups(username string) []string {
+	if source, ok := c.Backend.(auth.GroupSource); ok {
+		if groups := source.Groups(username); len(groups) > 0 {
+			return groups
+		}
+	}
+	entries, _ := c.List()
+	for _, entry := range entries {
+		if entry.Username == username {
+			return entry.Groups
+		}
+	}
+	return nil
+}
+
+// List returns the cached users sorted by name and backend
+func (c *Cache) List() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(data.Entries))
+	for _, entry := range data.Entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Username != entries[j].Username {
+			return entries[i].Username < entries[j].Username
+		}
+		return entries[i].Backend < entries[j].Backend
+	})
+	return entries, nil
+}
+
+// Forget removes every cached entry for username and reports how many there were
+func (c *Cache) Forget(username string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := c.load()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for key, entry := range data.Entries {
+		if entry.Username == username {
+			delete(data.Entries, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.save(data)
+}
+
+// Ping implements auth.Pinger for wrapped backends that support it
+func (c *Cache) Ping(ctx context.Context) error {
+	if pinger, ok := c.Backend.(auth.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped backend if it holds resources
+func (c *Cache) Close() error {
+	if closer, ok := c.Backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// entryKey identifies a user of one backend in the cache
+func entryKey(backend, username string) string {
+	return backend + "/" + username
+}
+
+// verify reports whether password matches a cached verifier
+func verify(verifier, password string) bool {
+	ok, err := crypt.Verify(verifier, password)
+	return err == nil && ok
+}
+
+// load decrypts the cache file; a missing file is an empty cache
+func (c *Cache) load() (*contents, error) {
+	data := &contents{Entries: make(map[string]*Entry)}
+	sealed, err := os.ReadFile(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(sealed, magic) || len(sealed) < len(magic)+aead.NonceSize() {
+		return nil, fmt.Errorf("%s is not an offline cache file", c.Path)
+	}
+	nonce := sealed[len(magic) : len(magic)+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, sealed[len(magic)+aead.NonceSize():], magic)
+	if err != nil {
+		return nil, ErrBadKey
+	}
+	if err := json.Unmarshal(plain, data); err != nil {
+		return nil, err
+	}
+	if data.Entries == nil {
+		data.Entries = make(map[string]*Entry)
+	}
+	return data, nil
+}
+
+// save encrypts data and replaces the cache file atomically with mode 0600
+func (c *Cache) save(data *contents) error {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	aead, err := c.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := append(append(append([]byte{}, magic...), nonce...), aead.Seal(nil, nonce, plain, magic)...)
+
+	dir := filepath.Dir(c.Path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".offline-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// aead returns the AES-256-GCM cipher for Key
+func (c *Cache) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("offline cache key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
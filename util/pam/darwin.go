@@ -4,6 +4,7 @@
 package pam
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -17,13 +18,16 @@ var UseBiometric bool
 var UseRealPAM bool
 var ClearBioCache bool
 
+// RealPAMCompiled reports whether this build links libpam (Linux builds with -tags pam only)
+const RealPAMCompiled = false
+
 // AuthenticateWithDSCL performs authentication using dscl command for macOS
-func AuthenticateWithDSCL(username, password string) bool {
+func AuthenticateWithDSCL(ctx context.Context, username, password string) bool {
 	// macOS user authentication using Directory Service Command Line
 	fmt.Printf("Attempting macOS authentication for user: %s\n", username)
 
 	// Check user information with dscl
-	cmd := exec.Command("dscl", ".", "-read", "/Users/"+username)
+	cmd := exec.CommandContext(ctx, "dscl", ".", "-read", "/Users/"+username)
 	output, err := cmd.Output()
 	if err != nil {
 		fmt.Printf("dscl command failed: %v\n", err)
@@ -36,7 +40,7 @@ func AuthenticateWithDSCL(username, password string) bool {
 		// Check if biometric authentication was requested
 		if UseBiometric {
 			fmt.Println("🔐 Biometric authentication requested...")
-			if AuthenticateWithBiometrics(username) {
+			if AuthenticateWithBiometrics(ctx, username) {
 				return true
 			}
 			fmt.Println("⚠️ Biometric authentication failed, falling back to password...")
@@ -87,7 +91,7 @@ func CheckTouchIDStatus() bool {
 }
 
 // AuthenticateWithBiometrics performs biometric authentication using AppleScript
-func AuthenticateWithBiometrics(username string) bool {
+func AuthenticateWithBiometrics(ctx context.Context, username string) bool {
 	if runtime.GOOS != "darwin" {
 		fmt.Println("Biometric authentication is only available on macOS")
 		return false
@@ -109,52 +113,56 @@ func AuthenticateWithBiometrics(username string) bool {
 	fmt.Println("💡 Please follow the system prompts to authenticate")
 
 	// Use security framework for TouchID authentication
-	return AuthenticateWithSecurityFramework(username)
+	return AuthenticateWithSecurityFramework(ctx, username)
 }
 
-// AuthenticateWithSecurityFramework uses the security command for authentication
-func AuthenticateWithSecurityFramework(username string) bool {
+// biometricTimeout bounds how long the TouchID prompt may stay open
+const biometricTimeout = 10 * time.Second
+
+// AuthenticateWithSecurityFramework uses the security command for
+// authentication. The command is killed after biometricTimeout or when ctx
+// is done.
+func AuthenticateWithSecurityFramework(ctx context.Context, username string) bool {
 	fmt.Println("🔒 Attempting biometric authentication...")
 
 	// Try to use a more direct TouchID authentication approach
 	// Use the authorization services with a specific right that doesn't require login screen
-	cmd := exec.Command("security", "authorizationdb", "read", "system.preferences")
+	cmd := exec.CommandContext(ctx, "security", "authorizationdb", "read", "system.preferences")
 	err := cmd.Run()
 	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
 		fmt.Printf("⚠️ Security framework not accessible: %v\n", err)
-		return AuthenticateWithAuthServices(username)
+		return AuthenticateWithAuthServices(ctx, username)
 	}
 
-	// Try using the authenticate-session right which is less intrusive
-	cmd = exec.Command("security", "authorize", "-u", username, "-e", "authenticate-session")
-
-	// Don't wait too long for TouchID
-	done := make(chan bool, 1)
-	go func() {
-		err = cmd.Run()
-		done <- (err == nil)
-	}()
-
-	// Wait for authentication or timeout
-	select {
-	case success := <-done:
-		if success {
-			fmt.Println("✅ Biometric authentication successful!")
-			// Show detailed biometric user information
-			GetUserBiometricInfo(username)
-			return true
-		}
-		fmt.Printf("⚠️ Biometric authentication failed\n")
-		return AuthenticateWithAuthServices(username)
-	case <-time.After(10 * time.Second):
-		// Timeout after 10 seconds
+	// Try using the authenticate-session right which is less intrusive,
+	// without waiting too long for TouchID
+	promptCtx, cancel := context.WithTimeout(ctx, biometricTimeout)
+	defer cancel()
+	cmd = exec.CommandContext(promptCtx, "security", "authorize", "-u", username, "-e", "authenticate-session")
+	err = cmd.Run()
+
+	switch {
+	case err == nil:
+		fmt.Println("✅ Biometric authentication successful!")
+		// Show detailed biometric user information
+		GetUserBiometricInfo(username)
+		return true
+	case ctx.Err() != nil:
+		fmt.Printf("⏰ Biometric authentication aborted: %v\n", ctx.Err())
+		return false
+	case promptCtx.Err() != nil:
 		fmt.Println("⏰ Biometric authentication timeout")
-		return AuthenticateWithAuthServices(username)
+		return AuthenticateWithAuthServices(ctx, username)
 	}
+	fmt.Printf("⚠️ Biometric authentication failed\n")
+	return AuthenticateWithAuthServices(ctx, username)
 }
 
 // AuthenticateWithAuthServices uses authorization services for TouchID
-func AuthenticateWithAuthServices(username string) bool {
+func AuthenticateWithAuthServices(ctx context.Context, username string) bool {
 	fmt.Println("🔐 Trying TouchID authentication...")
 
 	// Use a simpler AppleScript approach that triggers TouchID without login screen
@@ -178,7 +186,7 @@ Please use TouchID/FaceID or enter your password:" default answer "" with hidden
 	end tell'
 	`, username)
 
-	cmd := exec.Command("sh", "-c", script)
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
 	output, err := cmd.Output()
 
 	if err != nil {
@@ -358,11 +366,19 @@ func getHardwareUUID() {
 
 // Linux-specific function stubs for Darwin builds
 // AuthenticateWithRealPAM stub for Darwin (not available)
-func AuthenticateWithRealPAM(username, password string) bool {
+func AuthenticateWithRealPAM(ctx context.Context, username, password string) bool {
 	fmt.Printf("Real PAM authentication is only available on Linux (current: %s)\n", runtime.GOOS)
 	return false
 }
 
+// ConfigurePAMPool is a no-op without real PAM support
+func ConfigurePAMPool(workers, queue int) {}
+
+// PAMPoolStats returns empty counters without real PAM support
+func PAMPoolStats() PoolStats {
+	return PoolStats{}
+}
+
 // IsPAMAvailable stub for Darwin (not available)
 func IsPAMAvailable() bool {
 	return false
@@ -4,11 +4,14 @@
 package pam
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 
-	"github.com/msteinert/pam"
+	"github.com/msteinert/pam/v2"
 )
 
 // Global variables to track usage (will be set from main package)
@@ -16,8 +19,13 @@ var UseRealPAM bool
 var UseBiometric bool
 var ClearBioCache bool
 
-// AuthenticateWithRealPAM performs real PAM authentication on Linux
-func AuthenticateWithRealPAM(username, password string) bool {
+// RealPAMCompiled reports whether this build links libpam (built with -tags pam)
+const RealPAMCompiled = true
+
+// AuthenticateWithRealPAM performs real PAM authentication on Linux. The
+// transaction runs on the shared worker pool (see ConfigurePAMPool) and is
+// abandoned once ctx is done.
+func AuthenticateWithRealPAM(ctx context.Context, username, password string) bool {
 	if runtime.GOOS != "linux" {
 		fmt.Println("Real PAM authentication is only available on Linux")
 		return false
@@ -25,8 +33,28 @@ func AuthenticateWithRealPAM(username, password string) bool {
 
 	fmt.Printf("🔐 Attempting real PAM authentication for user: %s\n", username)
 
-	// Start PAM transaction
-	t, err := pam.StartFunc("login", username, func(s pam.Style, msg string) (string, error) {
+	err := sharedPool().Authenticate(ctx, username, password)
+	switch {
+	case err == nil:
+		fmt.Println("✅ Real PAM authentication successful!")
+		return true
+	case ctx.Err() != nil:
+		fmt.Printf("⏰ PAM authentication aborted: %v\n", ctx.Err())
+	default:
+		fmt.Printf("❌ %v\n", err)
+	}
+	return false
+}
+
+// runTransaction authenticates username and checks the account in a single
+// PAM transaction. Once ctx is done the conversation fails, which aborts
+// modules waiting for the password. It runs on a pool worker, so pam_end is
+// called on the same locked OS thread as the rest of the transaction.
+func runTransaction(ctx context.Context, service, username, password string) error {
+	t, err := pam.StartFunc(service, username, func(s pam.Style, msg string) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
 		switch s {
 		case pam.PromptEchoOff:
 			// Password prompt
@@ -43,28 +71,169 @@ func AuthenticateWithRealPAM(username, password string) bool {
 		}
 		return "", fmt.Errorf("unknown PAM style: %v", s)
 	})
-
 	if err != nil {
-		fmt.Printf("❌ PAM Start failed: %v\n", err)
-		return false
+		return fmt.Errorf("PAM Start failed: %w", err)
+	}
+	defer t.End()
+	if err := t.Authenticate(0); err != nil {
+		return fmt.Errorf("PAM Authentication failed: %w", err)
 	}
+	if err := t.AcctMgmt(0); err != nil {
+		return fmt.Errorf("PAM Account management failed: %w", err)
+	}
+	return nil
+}
 
-	// Perform authentication
-	err = t.Authenticate(0)
-	if err != nil {
-		fmt.Printf("❌ PAM Authentication failed: %v\n", err)
-		return false
+// Pool runs PAM transactions on a fixed number of workers, each locked to
+// its own OS thread, because many PAM modules are not reentrant or keep
+// per-thread state. Requests wait in a bounded queue; when it is full they
+// are refused with ErrPoolBusy instead of piling up behind a slow module.
+type Pool struct {
+	service string
+	workers int
+	jobs    chan *poolJob
+	wg      sync.WaitGroup
+
+	busy      atomic.Int64
+	queued    atomic.Int64
+	completed atomic.Uint64
+	failed    atomic.Uint64
+	rejected  atomic.Uint64
+	abandoned atomic.Uint64
+}
+
+// poolJob is one queued transaction
+type poolJob struct {
+	ctx      context.Context
+	username string
+	password string
+	done     chan error
+}
+
+// NewPool starts workers threads running transactions for service with room
+// for queue waiting requests
+func NewPool(service string, workers, queue int) *Pool {
+	if workers <= 0 {
+		workers = DefaultPoolWorkers
 	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := &Pool{service: service, workers: workers, jobs: make(chan *poolJob, queue)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
 
-	// Account management check
-	err = t.AcctMgmt(0)
-	if err != nil {
-		fmt.Printf("❌ PAM Account management failed: %v\n", err)
-		return false
+// Authenticate runs one transaction on the pool. Contexts without a deadline
+// are bounded by DefaultPoolTimeout.
+func (p *Pool) Authenticate(ctx context.Context, username, password string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultPoolTimeout)
+		defer cancel()
 	}
 
-	fmt.Println("✅ Real PAM authentication successful!")
-	return true
+	job := &poolJob{ctx: ctx, username: username, password: password, done: make(chan error, 1)}
+	p.queued.Add(1)
+	select {
+	case p.jobs <- job:
+	default:
+		p.queued.Add(-1)
+		p.rejected.Add(1)
+		return ErrPoolBusy
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		p.abandoned.Add(1)
+		return ctx.Err()
+	}
+}
+
+// work runs queued transactions on a dedicated OS thread. The thread is
+// never unlocked, so it exits with the worker and takes any per-thread
+// module state with it.
+func (p *Pool) work() {
+	defer p.wg.Done()
+	runtime.LockOSThread()
+
+	for job := range p.jobs {
+		p.queued.Add(-1)
+		if err := job.ctx.Err(); err != nil {
+			job.done <- err
+			continue
+		}
+		p.busy.Add(1)
+		err := runTransaction(job.ctx, p.service, job.username, job.password)
+		p.busy.Add(-1)
+		if err != nil {
+			p.failed.Add(1)
+		} else {
+			p.completed.Add(1)
+		}
+		job.done <- err
+	}
+}
+
+// Stats returns a snapshot of the pool counters
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:   p.workers,
+		Busy:      int(p.busy.Load()),
+		Queued:    int(p.queued.Load()),
+		QueueSize: cap(p.jobs),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+		Rejected:  p.rejected.Load(),
+		Abandoned: p.abandoned.Load(),
+	}
+}
+
+// Close stops accepting requests and waits for running transactions
+func (p *Pool) Close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// The pool shared by AuthenticateWithRealPAM
+var (
+	poolMu      sync.Mutex
+	pool        *Pool
+	poolWorkers = DefaultPoolWorkers
+	poolQueue   = DefaultPoolQueue
+)
+
+// sharedPool returns the shared pool, starting it on first use
+func sharedPool() *Pool {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	if pool == nil {
+		pool = NewPool("login", poolWorkers, poolQueue)
+	}
+	return pool
+}
+
+// ConfigurePAMPool sets the size of the pool used by AuthenticateWithRealPAM.
+// It must be called before the first authentication.
+func ConfigurePAMPool(workers, queue int) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	poolWorkers, poolQueue = workers, queue
+}
+
+// PAMPoolStats returns the counters of the shared pool
+func PAMPoolStats() PoolStats {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	if pool == nil {
+		return PoolStats{}
+	}
+	return pool.Stats()
 }
 
 // IsPAMAvailable checks if PAM authentication is available on the system
@@ -98,7 +267,7 @@ func CheckPAMPermissions() bool {
 
 // Darwin-specific function stubs for Linux builds
 // AuthenticateWithDSCL stub for Linux (not available)
-func AuthenticateWithDSCL(username, password string) bool {
+func AuthenticateWithDSCL(ctx context.Context, username, password string) bool {
 	fmt.Printf("macOS authentication is only available on Darwin (current: %s)\n", runtime.GOOS)
 	return false
 }
@@ -114,19 +283,19 @@ func CheckTouchIDStatus() bool {
 }
 
 // AuthenticateWithBiometrics stub for Linux (not available)
-func AuthenticateWithBiometrics(username string) bool {
+func AuthenticateWithBiometrics(ctx context.Context, username string) bool {
 	fmt.Printf("Biometric authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
 
 // AuthenticateWithSecurityFramework stub for Linux (not available)
-func AuthenticateWithSecurityFramework(username string) bool {
+func AuthenticateWithSecurityFramework(ctx context.Context, username string) bool {
 	fmt.Printf("Security framework authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
 
 // AuthenticateWithAuthServices stub for Linux (not available)
-func AuthenticateWithAuthServices(username string) bool {
+func AuthenticateWithAuthServices(ctx context.Context, username string) bool {
 	fmt.Printf("Authorization services authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
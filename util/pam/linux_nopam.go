@@ -4,6 +4,7 @@
 package pam
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"runtime"
@@ -14,8 +15,11 @@ var UseRealPAM bool
 var UseBiometric bool
 var ClearBioCache bool
 
+// RealPAMCompiled reports whether this build links libpam (built with -tags pam)
+const RealPAMCompiled = false
+
 // AuthenticateWithRealPAM performs fallback authentication on Linux without PAM
-func AuthenticateWithRealPAM(username, password string) bool {
+func AuthenticateWithRealPAM(ctx context.Context, username, password string) bool {
 	if runtime.GOOS != "linux" {
 		fmt.Printf("Real PAM authentication is only available on Linux (current: %s)\n", runtime.GOOS)
 		return false
@@ -26,7 +30,7 @@ func AuthenticateWithRealPAM(username, password string) bool {
 	fmt.Printf("📝 Falling back to basic user verification for user: %s\n", username)
 
 	// Check user information from /etc/passwd file
-	cmd := exec.Command("getent", "passwd", username)
+	cmd := exec.CommandContext(ctx, "getent", "passwd", username)
 	output, err := cmd.Output()
 	if err != nil {
 		fmt.Printf("getent command failed: %v\n", err)
@@ -48,6 +52,14 @@ func AuthenticateWithRealPAM(username, password string) bool {
 	return false
 }
 
+// ConfigurePAMPool is a no-op without real PAM support
+func ConfigurePAMPool(workers, queue int) {}
+
+// PAMPoolStats returns empty counters without real PAM support
+func PAMPoolStats() PoolStats {
+	return PoolStats{}
+}
+
 // IsPAMAvailable returns false when PAM is not available
 func IsPAMAvailable() bool {
 	return false
@@ -59,7 +71,7 @@ func CheckPAMPermissions() bool {
 }
 
 // AuthenticateWithBiometrics stub for Linux (not available)
-func AuthenticateWithBiometrics(username string) bool {
+func AuthenticateWithBiometrics(ctx context.Context, username string) bool {
 	fmt.Printf("Biometric authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
@@ -70,14 +82,14 @@ func IsBiometricAvailable() bool {
 }
 
 // AuthenticateWithDSCL stub for Linux (not available)
-func AuthenticateWithDSCL(username, password string) bool {
+func AuthenticateWithDSCL(ctx context.Context, username, password string) bool {
 	fmt.Printf("DSCL authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 
 	// Fallback to basic Linux authentication
 	fmt.Printf("🐧 Linux detected - attempting basic authentication for user: %s\n", username)
 
 	// Check user information from /etc/passwd file
-	cmd := exec.Command("getent", "passwd", username)
+	cmd := exec.CommandContext(ctx, "getent", "passwd", username)
 	output, err := cmd.Output()
 	if err != nil {
 		fmt.Printf("getent command failed: %v\n", err)
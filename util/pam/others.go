@@ -4,6 +4,7 @@
 package pam
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 )
@@ -13,12 +14,23 @@ var UseBiometric bool
 var UseRealPAM bool
 var ClearBioCache bool
 
+// RealPAMCompiled reports whether this build links libpam (Linux builds with -tags pam only)
+const RealPAMCompiled = false
+
 // AuthenticateWithRealPAM stub for non-Linux platforms
-func AuthenticateWithRealPAM(username, password string) bool {
+func AuthenticateWithRealPAM(ctx context.Context, username, password string) bool {
 	fmt.Printf("Real PAM authentication is only available on Linux (current: %s)\n", runtime.GOOS)
 	return false
 }
 
+// ConfigurePAMPool is a no-op without real PAM support
+func ConfigurePAMPool(workers, queue int) {}
+
+// PAMPoolStats returns empty counters without real PAM support
+func PAMPoolStats() PoolStats {
+	return PoolStats{}
+}
+
 // IsPAMAvailable stub for non-Linux platforms
 func IsPAMAvailable() bool {
 	return false
@@ -30,7 +42,7 @@ func CheckPAMPermissions() bool {
 }
 
 // AuthenticateWithDSCL stub for non-Darwin platforms
-func AuthenticateWithDSCL(username, password string) bool {
+func AuthenticateWithDSCL(ctx context.Context, username, password string) bool {
 	fmt.Printf("macOS authentication is only available on Darwin (current: %s)\n", runtime.GOOS)
 	return false
 }
@@ -46,19 +58,19 @@ func CheckTouchIDStatus() bool {
 }
 
 // AuthenticateWithBiometrics stub for non-Darwin platforms
-func AuthenticateWithBiometrics(username string) bool {
+func AuthenticateWithBiometrics(ctx context.Context, username string) bool {
 	fmt.Printf("Biometric authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
 
 // AuthenticateWithSecurityFramework stub for non-Darwin platforms
-func AuthenticateWithSecurityFramework(username string) bool {
+func AuthenticateWithSecurityFramework(ctx context.Context, username string) bool {
 	fmt.Printf("Security framework authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
 
 // AuthenticateWithAuthServices stub for non-Darwin platforms
-func AuthenticateWithAuthServices(username string) bool {
+func AuthenticateWithAuthServices(ctx context.Context, username string) bool {
 	fmt.Printf("Authorization services authentication is only available on macOS (current: %s)\n", runtime.GOOS)
 	return false
 }
@@ -0,0 +1,40 @@
+package pam
+
+import (
+	"errors"
+	"time"
+)
+
+// Defaults for the PAM transaction pool used by AuthenticateWithRealPAM
+const (
+	DefaultPoolWorkers = 8
+	DefaultPoolQueue   = 64
+	// DefaultPoolTimeout bounds transactions whose context has no deadline
+	DefaultPoolTimeout = 30 * time.Second
+)
+
+// ErrPoolBusy is returned when every PAM worker is busy and the queue is full
+var ErrPoolBusy = errors.New("PAM pool queue is full")
+
+// ErrNoSessions is returned by OpenSession in builds without libpam
+var ErrNoSessions = errors.New("PAM sessions need a Linux build with -tags pam")
+
+// PoolStats is a snapshot of the PAM transaction pool
+type PoolStats struct {
+	// Workers is the number of OS threads running transactions
+	Workers int
+	// Busy is the number of transactions in progress
+	Busy int
+	// Queued is the number of requests waiting for a worker
+	Queued int
+	// QueueSize is the number of requests that may wait for a worker
+	QueueSize int
+	// Completed counts transactions that accepted the user
+	Completed uint64
+	// Failed counts transactions that rejected the user or failed
+	Failed uint64
+	// Rejected counts requests refused with ErrPoolBusy
+	Rejected uint64
+	// Abandoned counts requests whose caller gave up before the answer
+	Abandoned uint64
+}
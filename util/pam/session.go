@@ -0,0 +1,94 @@
+//go:build linux && pam
+// +build linux,pam
+
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// Session is a PAM session opened for a command run on a user's behalf
+type Session struct {
+	t *pam.Transaction
+}
+
+// OpenSession checks that username may log in now and opens a session for
+// it on service with its credentials established, as su does after
+// authenticating. It does not authenticate: the caller already has, but
+// account modules such as pam_nologin and pam_access and password expiry
+// still apply. tty is set as PAM_TTY when not empty. Session modules may
+// keep per-thread state, so the calling goroutine stays locked to its OS
+// thread until Close, which also ends the transaction there.
+func OpenSession(service, username, tty string) (*Session, error) {
+	runtime.LockOSThread()
+	t, err := pam.StartFunc(service, username, func(s pam.Style, msg string) (string, error) {
+		switch s {
+		case pam.ErrorMsg, pam.TextInfo:
+			// pam_motd, pam_lastlog and friends talk to the user
+			fmt.Fprintln(os.Stderr, msg)
+			return "", nil
+		}
+		return "", errors.New("session modules cannot prompt")
+	})
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("PAM Start failed: %w", err)
+	}
+	fail := func(step string, err error) (*Session, error) {
+		t.End()
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("PAM %s failed: %w", step, err)
+	}
+	if tty != "" {
+		if err := t.SetItem(pam.Tty, tty); err != nil {
+			return fail("set tty", err)
+		}
+	}
+	if err := t.AcctMgmt(0); err != nil {
+		return fail("Account management", err)
+	}
+	if err := t.SetCred(pam.EstablishCred); err != nil {
+		return fail("Set credentials", err)
+	}
+	if err := t.OpenSession(0); err != nil {
+		t.SetCred(pam.DeleteCred)
+		return fail("Open session", err)
+	}
+	return &Session{t: t}, nil
+}
+
+// Env returns the variables session modules set, such as XDG_RUNTIME_DIR,
+// as NAME=VALUE pairs
+func (s *Session) Env() []string {
+	vars, err := s.t.GetEnvList()
+	if err != nil {
+		return nil
+	}
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// Close closes the session, deletes the credentials and ends the
+// transaction. It must be called from the goroutine that opened the session.
+func (s *Session) Close() error {
+	defer runtime.UnlockOSThread()
+	err := s.t.CloseSession(0)
+	if credErr := s.t.SetCred(pam.DeleteCred); err == nil && credErr != nil {
+		err = credErr
+	}
+	if endErr := s.t.End(); err == nil && endErr != nil {
+		err = endErr
+	}
+	if err != nil {
+		return fmt.Errorf("PAM Close session failed: %w", err)
+	}
+	return nil
+}
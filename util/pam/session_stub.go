@@ -0,0 +1,22 @@
+//go:build !linux || !pam
+// +build !linux !pam
+
+package pam
+
+// Session is a PAM session; it cannot be opened in this build
+type Session struct{}
+
+// OpenSession fails without libpam
+func OpenSession(service, username, tty string) (*Session, error) {
+	return nil, ErrNoSessions
+}
+
+// Env returns no variables without libpam
+func (s *Session) Env() []string {
+	return nil
+}
+
+// Close does nothing without libpam
+func (s *Session) Close() error {
+	return nil
+}
@@ -0,0 +1,317 @@
+package pamconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultDir holds the service files
+const DefaultDir = "/etc/pam.d"
+
+// ModuleDirs are searched for modules named by a relative path
+var ModuleDirs = []string{
+	"/lib/security", "/lib64/security", "/usr/lib/security", "/usr/lib64/security",
+	"/lib/x86_64-linux-gnu/security", "/usr/lib/x86_64-linux-gnu/security",
+	"/lib/aarch64-linux-gnu/security", "/usr/lib/aarch64-linux-gnu/security",
+}
+
+// RemoteServices are services reached over the network, where nullok lets
+// anyone log in to an account without a password
+var RemoteServices = []string{"sshd", "telnet", "rlogin", "rsh", "rexec", "ftp", "vsftpd", "proftpd", "xrdp", "cockpit", "dovecot"}
+
+// Options configures Lint
+type Options struct {
+	// Dir holds the service files (default DefaultDir)
+	Dir string
+	// ModuleDirs overrides the module search path
+	ModuleDirs []string
+	// RemoteServices overrides the services nullok is flagged on
+	RemoteServices []string
+}
+
+// denyValues is what pam_deny.so returns for each facility
+var denyValues = map[string]string{
+	"auth":     "auth_err",
+	"account":  "auth_err",
+	"password": "authtok_err",
+	"session":  "session_err",
+}
+
+// linter accumulates diagnostics across the files a service includes
+type linter struct {
+	opts    Options
+	files   map[string][]Rule
+	missing map[string]bool
+	diags   map[string]Diagnostic
+}
+
+// Lint parses service and every file it includes and reports syntax errors,
+// missing modules and includes, include cycles, unreachable rules, required
+// rules skipped by an earlier sufficient rule, and nullok on remote services
+func Lint(service string, opts Options) ([]Diagnostic, error) {
+	if opts.Dir == "" {
+		opts.Dir = DefaultDir
+	}
+	if opts.ModuleDirs == nil {
+		opts.ModuleDirs = ModuleDirs
+	}
+	if opts.RemoteServices == nil {
+		opts.RemoteServices = RemoteServices
+	}
+	l := &linter{opts: opts, files: make(map[string][]Rule), missing: make(map[string]bool), diags: make(map[string]Diagnostic)}
+
+	if _, err := os.Stat(filepath.Join(opts.Dir, service)); err != nil {
+		return nil, err
+	}
+	if err := l.load(service); err != nil {
+		return nil, err
+	}
+
+	remote := false
+	for _, name := range opts.RemoteServices {
+		remote = remote || name == service
+	}
+	stacks := []string{service}
+	analyzed := make(map[string]bool)
+	for len(stacks) > 0 {
+		name := stacks[0]
+		stacks = stacks[1:]
+		if analyzed[name] {
+			continue
+		}
+		analyzed[name] = true
+		for _, facility := range Facilities {
+			stack, substacks := l.expand(name, facility, []string{name})
+			l.analyze(facility, stack)
+			if remote {
+				l.checkNullok(service, stack)
+			}
+			stacks = append(stacks, substacks...)
+		}
+	}
+
+	diags := make([]Diagnostic, 0, len(l.diags))
+	for _, d := range l.diags {
+		diags = append(diags, d)
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File < diags[j].File
+		}
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Message < diags[j].Message
+	})
+	return diags, nil
+}
+
+// add records diagnostics, dropping duplicates found through several includes
+func (l *linter) add(diags ...Diagnostic) {
+	for _, d := range diags {
+		l.diags[d.String()] = d
+	}
+}
+
+// warn records a warning at rule
+func (l *linter) warn(rule Rule, format string, args ...any) {
+	l.add(Diagnostic{File: rule.File, Line: rule.Line, Severity: Warning, Message: fmt.Sprintf(format, args...)})
+}
+
+// fail records an error at rule
+func (l *linter) fail(rule Rule, format string, args ...any) {
+	l.add(Diagnostic{File: rule.File, Line: rule.Line, Severity: Error, Message: fmt.Sprintf(format, args...)})
+}
+
+// load parses service once, checks its modules and loads what it includes
+func (l *linter) load(service string) error {
+	if _, loaded := l.files[service]; loaded || l.missing[service] {
+		return nil
+	}
+	rules, diags, err := ParseFile(filepath.Join(l.opts.Dir, service))
+	if err != nil {
+		l.missing[service] = true
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	l.files[service] = rules
+	l.add(diags...)
+
+	for _, rule := range rules {
+		if rule.IsInclude() {
+			if err := l.load(rule.Module); err != nil {
+				return err
+			}
+			if l.missing[rule.Module] {
+				l.fail(rule, "%s %s: %s not found", rule.Control, rule.Module, filepath.Join(l.opts.Dir, rule.Module))
+			}
+			continue
+		}
+		if !rule.Optional && !l.moduleExists(rule.Module) {
+			l.fail(rule, "module %s not found", rule.Module)
+		}
+	}
+	return nil
+}
+
+// moduleExists looks module up on the module search path
+func (l *linter) moduleExists(module string) bool {
+	if filepath.IsAbs(module) {
+		_, err := os.Stat(module)
+		return err == nil
+	}
+	for _, dir := range l.opts.ModuleDirs {
+		if _, err := os.Stat(filepath.Join(dir, module)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expand returns the facility stack of service with includes inlined, as
+// libpam builds it, and the services it runs as substacks. chain holds the
+// services being expanded to catch include cycles.
+func (l *linter) expand(service, facility string, chain []string) ([]Rule, []string) {
+	var stack []Rule
+	var substacks []string
+	for _, rule := range l.files[service] {
+		switch {
+		case rule.includes(facility):
+			if l.missing[rule.Module] {
+				continue
+			}
+			if cycle := indexOf(chain, rule.Module); cycle >= 0 {
+				l.fail(rule, "include cycle: %s", strings.Join(append(chain[cycle:], rule.Module), " -> "))
+				continue
+			}
+			included, nested := l.expand(rule.Module, facility, append(chain, rule.Module))
+			stack = append(stack, included...)
+			substacks = append(substacks, nested...)
+		case rule.Type == facility:
+			stack = append(stack, rule)
+			if rule.Control == "substack" && !l.missing[rule.Module] {
+				if indexOf(chain, rule.Module) >= 0 {
+					l.fail(rule, "substack cycle: %s", strings.Join(append(chain, rule.Module), " -> "))
+					continue
+				}
+				substacks = append(substacks, rule.Module)
+			}
+		}
+	}
+	return stack, substacks
+}
+
+// analyze follows every path through stack to flag rules that can never run
+// and required rules an earlier sufficient rule can skip
+func (l *linter) analyze(facility string, stack []Rule) {
+	reachable := make([]bool, len(stack)+1)
+	reachable[0] = true
+	var sufficient *Rule
+	// done ends the stack only while no earlier rule can have failed
+	mayFail := false
+	for i, rule := range stack {
+		base := filepath.Base(rule.Module)
+		if !reachable[i] {
+			// A fallback pam_deny.so that every path jumps over is a
+			// common safety net, not a mistake
+			if base != "pam_deny.so" {
+				l.warn(rule, "unreachable: no path through the %s stack reaches this rule", facility)
+			}
+			continue
+		}
+
+		switch {
+		case rule.Control == "sufficient" && base != "pam_rootok.so" && base != "pam_deny.so":
+			if sufficient == nil {
+				sufficient = &stack[i]
+			}
+		case (rule.Control == "required" || rule.Control == "requisite") && sufficient != nil &&
+			base != "pam_deny.so" && base != "pam_permit.so":
+			l.warn(rule, "%s %s is skipped whenever sufficient %s (%s:%d) succeeds; move it above",
+				rule.Control, rule.Module, sufficient.Module, sufficient.File, sufficient.Line)
+		}
+
+		actions := outcomes(rule, facility)
+		for _, action := range actions {
+			if action == "die" || (action == "done" && !mayFail) {
+				continue
+			}
+			jump := 1
+			if n, err := strconv.Atoi(action); err == nil {
+				jump += n
+			}
+			if i+jump > len(stack) {
+				l.warn(rule, "jump %s skips past the end of the %s stack", action, facility)
+				jump = len(stack) - i
+			}
+			reachable[i+jump] = true
+		}
+		for _, action := range actions {
+			mayFail = mayFail || action == "bad"
+		}
+	}
+}
+
+// outcomes returns the actions rule can take. pam_permit.so always succeeds
+// and pam_deny.so always fails; other modules may return anything.
+func outcomes(rule Rule, facility string) []string {
+	if rule.Actions == nil {
+		// A substack ends only itself
+		return []string{"ignore"}
+	}
+	action := func(value string) string {
+		if action, ok := rule.Actions[value]; ok {
+			return action
+		}
+		if action, ok := rule.Actions["default"]; ok {
+			return action
+		}
+		return "bad"
+	}
+
+	var actions []string
+	switch filepath.Base(rule.Module) {
+	case "pam_permit.so":
+		actions = []string{action("success")}
+	case "pam_deny.so":
+		actions = []string{action(denyValues[facility])}
+	default:
+		for _, a := range rule.Actions {
+			actions = append(actions, a)
+		}
+		if _, ok := rule.Actions["default"]; !ok {
+			actions = append(actions, "bad")
+		}
+	}
+	if rule.Optional {
+		// A missing module is skipped
+		actions = append(actions, "ignore")
+	}
+	return actions
+}
+
+// checkNullok flags modules in a remote service's stack that accept empty
+// passwords
+func (l *linter) checkNullok(service string, stack []Rule) {
+	for _, rule := range stack {
+		if rule.HasArg("nullok") {
+			l.warn(rule, "nullok on remote service %s lets accounts with an empty password log in over the network", service)
+		}
+	}
+}
+
+// indexOf returns the position of name in list, or -1
+func indexOf(list []string, name string) int {
+	for i, item := range list {
+		if item == name {
+			return i
+		}
+	}
+	return -1
+}
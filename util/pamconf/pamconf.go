@@ -0,0 +1,271 @@
+package pamconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Severity grades a diagnostic
+type Severity string
+
+// Diagnostic severities. Errors break the stack, warnings are likely mistakes.
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Diagnostic is a problem found at a line of a PAM configuration file
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String formats d as FILE:LINE: SEVERITY: MESSAGE
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// Facilities are the module types a rule can belong to
+var Facilities = []string{"auth", "account", "password", "session"}
+
+// Rule is one line of a pam.d file
+type Rule struct {
+	File string
+	Line int
+	// Type is the facility, or empty for an @include directive
+	Type string
+	// Optional is set by a leading dash: a missing module is skipped silently
+	Optional bool
+	// Control is the control keyword, the bracketed control as written, or
+	// "@include"
+	Control string
+	// Actions maps return values to actions, with keywords translated to
+	// their bracketed equivalent. It is nil for include, substack and @include.
+	Actions map[string]string
+	// Module is the module path, or the service named by an include
+	Module string
+	Args   []string
+}
+
+// IsInclude reports whether the rule pulls in another service file
+func (r Rule) IsInclude() bool {
+	switch r.Control {
+	case "include", "substack", "@include":
+		return true
+	}
+	return false
+}
+
+// includes reports whether r contributes rules of facility through an include
+func (r Rule) includes(facility string) bool {
+	return r.Control == "@include" || (r.Control == "include" && r.Type == facility)
+}
+
+// HasArg reports whether the module is passed arg
+func (r Rule) HasArg(arg string) bool {
+	for _, a := range r.Args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// keywordActions are the bracketed forms of the control keywords
+var keywordActions = map[string]map[string]string{
+	"required":   {"success": "ok", "new_authtok_reqd": "ok", "ignore": "ignore", "default": "bad"},
+	"requisite":  {"success": "ok", "new_authtok_reqd": "ok", "ignore": "ignore", "default": "die"},
+	"sufficient": {"success": "done", "new_authtok_reqd": "done", "default": "ignore"},
+	"optional":   {"success": "ok", "new_authtok_reqd": "ok", "default": "ignore"},
+}
+
+// returnValues are the values a bracketed control may name
+var returnValues = map[string]bool{
+	"success": true, "open_err": true, "symbol_err": true, "service_err": true, "system_err": true,
+	"buf_err": true, "perm_denied": true, "auth_err": true, "cred_insufficient": true,
+	"authinfo_unavail": true, "user_unknown": true, "maxtries": true, "new_authtok_reqd": true,
+	"acct_expired": true, "session_err": true, "cred_unavail": true, "cred_expired": true,
+	"cred_err": true, "no_module_data": true, "conv_err": true, "authtok_err": true,
+	"authtok_recover_err": true, "authtok_lock_busy": true, "authtok_disable_aging": true,
+	"try_again": true, "ignore": true, "abort": true, "authtok_expired": true,
+	"module_unknown": true, "bad_item": true, "conv_again": true, "incomplete": true,
+	"default": true,
+}
+
+// actionNames are the actions a bracketed control may take besides a jump
+var actionNames = map[string]bool{"ignore": true, "bad": true, "die": true, "ok": true, "done": true, "reset": true}
+
+// ParseFile parses the pam.d file at path
+func ParseFile(path string) ([]Rule, []Diagnostic, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return Parse(path, file)
+}
+
+// Parse reads pam.d syntax from r, naming it name in rules and diagnostics.
+// Syntax errors are returned as diagnostics and the offending lines skipped.
+func Parse(name string, r io.Reader) ([]Rule, []Diagnostic, error) {
+	var rules []Rule
+	var diags []Diagnostic
+	report := func(line int, format string, args ...any) {
+		diags = append(diags, Diagnostic{File: name, Line: line, Severity: Error, Message: fmt.Sprintf(format, args...)})
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		start := lineNo
+		text := scanner.Text()
+		// A trailing backslash continues the rule on the next line
+		for strings.HasSuffix(text, "\\") && scanner.Scan() {
+			lineNo++
+			text = strings.TrimSuffix(text, "\\") + " " + scanner.Text()
+		}
+		text, _, _ = strings.Cut(text, "#")
+
+		fields, err := tokenize(text)
+		if err != nil {
+			report(start, "%v", err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "@include" {
+			if len(fields) != 2 {
+				report(start, "@include takes exactly one service name")
+				continue
+			}
+			rules = append(rules, Rule{File: name, Line: start, Control: "@include", Module: fields[1]})
+			continue
+		}
+		if len(fields) < 3 {
+			report(start, "expected TYPE CONTROL MODULE [ARGUMENTS]")
+			continue
+		}
+
+		rule := Rule{File: name, Line: start, Control: fields[1], Module: fields[2], Args: fields[3:]}
+		rule.Type = strings.ToLower(fields[0])
+		if strings.HasPrefix(rule.Type, "-") {
+			rule.Optional = true
+			rule.Type = rule.Type[1:]
+		}
+		if !validFacility(rule.Type) {
+			report(start, "unknown type %q, expected auth, account, password or session", fields[0])
+			continue
+		}
+
+		switch control := strings.ToLower(rule.Control); {
+		case strings.HasPrefix(control, "["):
+			if rule.Actions, err = parseActions(control); err != nil {
+				report(start, "%v", err)
+				continue
+			}
+		case control == "include" || control == "substack":
+			rule.Control = control
+			if len(rule.Args) > 0 {
+				report(start, "%s takes no module arguments", control)
+			}
+		case keywordActions[control] != nil:
+			rule.Control = control
+			rule.Actions = keywordActions[control]
+		default:
+			report(start, "unknown control %q", rule.Control)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, diags, scanner.Err()
+}
+
+// tokenize splits a rule into fields. A field opening with "[" runs to the
+// matching "]" and may contain spaces; "\]" escapes a bracket inside it.
+// Brackets are kept on the control field and dropped on module arguments.
+func tokenize(text string) ([]string, error) {
+	var fields []string
+	for {
+		text = strings.TrimLeft(text, " \t")
+		if text == "" {
+			return fields, nil
+		}
+		if text[0] != '[' {
+			end := strings.IndexAny(text, " \t")
+			if end < 0 {
+				end = len(text)
+			}
+			fields = append(fields, text[:end])
+			text = text[end:]
+			continue
+		}
+
+		var field strings.Builder
+		closed := false
+		i := 1
+		for ; i < len(text); i++ {
+			if text[i] == '\\' && i+1 < len(text) && text[i+1] == ']' {
+				field.WriteByte(']')
+				i++
+				continue
+			}
+			if text[i] == ']' {
+				closed = true
+				break
+			}
+			field.WriteByte(text[i])
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated [ in %q", strings.TrimSpace(text))
+		}
+		if len(fields) == 1 {
+			fields = append(fields, "["+field.String()+"]")
+		} else {
+			fields = append(fields, field.String())
+		}
+		text = text[i+1:]
+	}
+}
+
+// parseActions parses a bracketed control such as
+// "[success=1 default=ignore]"
+func parseActions(control string) (map[string]string, error) {
+	actions := make(map[string]string)
+	for _, pair := range strings.Fields(strings.Trim(control, "[]")) {
+		value, action, ok := strings.Cut(pair, "=")
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("control %q: expected VALUE=ACTION", pair)
+		case !returnValues[value]:
+			return nil, fmt.Errorf("control %q: unknown return value %q", pair, value)
+		case !actionNames[action]:
+			if n, err := strconv.Atoi(action); err != nil || n < 1 {
+				return nil, fmt.Errorf("control %q: unknown action %q", pair, action)
+			}
+		}
+		actions[value] = action
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("empty control []")
+	}
+	return actions, nil
+}
+
+// validFacility reports whether name is one of Facilities
+func validFacility(name string) bool {
+	for _, facility := range Facilities {
+		if name == facility {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package pamconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Step is one rule of a simulated stack
+type Step struct {
+	Rule Rule
+	// Level is the substack nesting depth
+	Level int
+	// Result is the return value the module was given, empty for skipped
+	// rules and substack entries
+	Result string
+	// Action is what the control did with Result, or "skipped"
+	Action string
+}
+
+// Simulation is the trace and outcome of Simulate
+type Simulation struct {
+	Service  string
+	Facility string
+	Steps    []Step
+	// Decision is the return value libpam hands the application
+	Decision string
+}
+
+// Succeeded reports whether the stack granted the request
+func (s Simulation) Succeeded() bool {
+	return s.Decision == "success"
+}
+
+// Results gives simulated modules their return values
+type Results struct {
+	// Modules maps module names, with or without ".so", to return values
+	Modules map[string]string
+	// Default is returned by modules missing from Modules (default
+	// "success"). pam_permit.so and pam_deny.so keep their fixed results.
+	Default string
+}
+
+// ParseResults parses "pam_unix=success,pam_faillock=auth_err"
+func ParseResults(spec string) (map[string]string, error) {
+	modules := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		module, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("result %q: expected MODULE=VALUE", pair)
+		}
+		if err := checkResult(value); err != nil {
+			return nil, err
+		}
+		modules[strings.TrimSuffix(module, ".so")] = value
+	}
+	return modules, nil
+}
+
+// checkResult rejects names that are not PAM return values
+func checkResult(value string) error {
+	if !returnValues[value] || value == "default" {
+		return fmt.Errorf("unknown PAM return value %q", value)
+	}
+	return nil
+}
+
+// resultFor returns the simulated return value of rule's module
+func (r Results) resultFor(rule Rule, facility string) string {
+	module := strings.TrimSuffix(filepath.Base(rule.Module), ".so")
+	if value, ok := r.Modules[module]; ok {
+		return value
+	}
+	switch module {
+	case "pam_permit":
+		return "success"
+	case "pam_deny":
+		return denyValues[facility]
+	}
+	return r.Default
+}
+
+// handler is a rule placed in the flattened stack libpam dispatches
+type handler struct {
+	rule  Rule
+	level int
+}
+
+// Simulated impressions of the stack, as libpam tracks them
+const (
+	undecided = iota
+	positive
+	negative
+)
+
+// mustFail is the status libpam uses when the stack cannot succeed
+const mustFail = "perm_denied"
+
+// Simulate evaluates the facility stack of service with libpam's control
+// flow: include and @include are inlined, a substack counts as one module
+// for jumps and confines done, die and reset to itself, and a jump past
+// the end of its stack fails the request
+func Simulate(service, facility string, results Results, opts Options) (Simulation, error) {
+	if opts.Dir == "" {
+		opts.Dir = DefaultDir
+	}
+	if results.Default == "" {
+		results.Default = "success"
+	}
+	if err := checkResult(results.Default); err != nil {
+		return Simulation{}, err
+	}
+	if !validFacility(facility) {
+		return Simulation{}, fmt.Errorf("unknown type %q, expected auth, account, password or session", facility)
+	}
+	handlers, err := flatten(opts.Dir, service, facility, 0, []string{service})
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	sim := Simulation{Service: service, Facility: facility, Steps: make([]Step, len(handlers))}
+	for i, h := range handlers {
+		sim.Steps[i] = Step{Rule: h.rule, Level: h.level, Action: "skipped"}
+	}
+
+	type state struct {
+		impression int
+		status     string
+	}
+	impression, status := undecided, mustFail
+	saved := make(map[int]state)
+	prevLevel := 0
+
+	for i := 0; i < len(handlers); i++ {
+		h := handlers[i]
+		level := h.level
+		if prevLevel < level {
+			saved[level] = state{impression, status}
+		}
+		prevLevel = level
+		step := &sim.Steps[i]
+
+		if h.rule.Control == "substack" {
+			step.Action = "substack"
+			continue
+		}
+		retval := results.resultFor(h.rule, facility)
+		step.Result = retval
+		action, ok := h.rule.Actions[retval]
+		if !ok {
+			action, ok = h.rule.Actions["default"]
+		}
+		if !ok {
+			action = "undefined"
+		}
+		step.Action = action
+
+		decided := false
+		switch action {
+		case "reset":
+			impression, status = saved[level].impression, saved[level].status
+		case "ok", "done":
+			if impression == undecided || (impression == positive && status == "success") {
+				impression, status = positive, retval
+			}
+			// done does not override an earlier failure
+			decided = action == "done" && impression != negative
+		case "bad", "die":
+			if impression != negative {
+				impression, status = negative, retval
+				if retval == "ignore" {
+					status = mustFail
+				}
+			}
+			decided = action == "die"
+		case "ignore":
+		default:
+			// A jump skips whole substacks and cannot leave its own
+			jump, _ := strconv.Atoi(action)
+			for i+1 < len(handlers) && handlers[i+1].level >= level && jump > 0 {
+				i++
+				for i+1 < len(handlers) && handlers[i+1].level > level {
+					i++
+				}
+				jump--
+			}
+			if jump != 0 {
+				step.Action = action + " (bad jump)"
+				impression, status = negative, mustFail
+			}
+		}
+
+		if decided {
+			for i+1 < len(handlers) && handlers[i+1].level >= level {
+				i++
+			}
+		}
+	}
+
+	if status == "success" && impression != positive {
+		status = mustFail
+	}
+	sim.Decision = status
+	return sim, nil
+}
+
+// flatten lists the facility rules of service in dispatch order, with
+// substack contents one level below their substack entry
+func flatten(dir, service, facility string, level int, chain []string) ([]handler, error) {
+	rules, diags, err := ParseFile(filepath.Join(dir, service))
+	if err != nil {
+		return nil, err
+	}
+	if len(diags) > 0 {
+		return nil, fmt.Errorf("%s (run pam lint)", diags[0])
+	}
+
+	var handlers []handler
+	for _, rule := range rules {
+		switch {
+		case rule.includes(facility):
+			if indexOf(chain, rule.Module) >= 0 {
+				return nil, fmt.Errorf("%s:%d: include cycle: %s", rule.File, rule.Line, strings.Join(append(chain, rule.Module), " -> "))
+			}
+			included, err := flatten(dir, rule.Module, facility, level, append(chain, rule.Module))
+			if err != nil {
+				return nil, err
+			}
+			handlers = append(handlers, included...)
+		case rule.Type != facility:
+		case rule.Control == "substack":
+			if indexOf(chain, rule.Module) >= 0 {
+				return nil, fmt.Errorf("%s:%d: substack cycle: %s", rule.File, rule.Line, strings.Join(append(chain, rule.Module), " -> "))
+			}
+			nested, err := flatten(dir, rule.Module, facility, level+1, append(chain, rule.Module))
+			if err != nil {
+				return nil, err
+			}
+			handlers = append(handlers, handler{rule: rule, level: level})
+			handlers = append(handlers, nested...)
+		default:
+			handlers = append(handlers, handler{rule: rule, level: level})
+		}
+	}
+	return handlers, nil
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Breached looks password up in a breached password list and returns how
+// often it was seen, or 0 when it is absent. The list holds one uppercase
+// hex SHA-1 hash per line, optionally followed by ":COUNT", sorted
+// ascending like the "ordered by hash" Pwned Passwords download. Entries
+// may be truncated to a common prefix length to keep the file small; a
+// password matches when its hash starts with the entry. The file is
+// binary searched, so lists of any size are checked in a few reads.
+func Breached(path, password string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// The entry sought, if present, starts in [lo, hi)
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAt(file, mid, info.Size())
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		if start >= hi {
+			// No entry starts in [mid, hi)
+			hi = mid
+			continue
+		}
+
+		entry, count, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ":")
+		entry = strings.ToUpper(strings.TrimSpace(entry))
+		if entry == "" || len(entry) > len(digest) {
+			return 0, fmt.Errorf("%s: malformed entry at byte %d", path, start)
+		}
+		switch prefix := digest[:len(entry)]; {
+		case prefix == entry:
+			if count == "" {
+				return 1, nil
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: malformed count at byte %d", path, start)
+			}
+			return n, nil
+		case entry < prefix:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineAt returns the first line of r starting at or after offset, with its
+// position; at the end of the file the position is size
+func lineAt(r io.ReaderAt, offset, size int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// Read from the byte before offset so a line starting exactly at
+		// offset is not skipped
+		start = offset - 1
+	}
+	reader := bufio.NewReader(io.NewSectionReader(r, start, size-start))
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	if line == "" {
+		return size, "", nil
+	}
+	return start, line, nil
+}
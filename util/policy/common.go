@@ -0,0 +1,32 @@
+package policy
+
+import "strings"
+
+// commonPasswords are the most frequent passwords and password words in
+// public breach corpora. Variants with digits, symbols or l33t spelling
+// around them are caught by dictionaryWord.
+var commonPasswords = wordSet(`
+	password passw0rd passwd admin administrator root toor welcome letmein
+	login qwerty qwertyuiop asdfgh asdfghjkl zxcvbn zxcvbnm azerty
+	abcdef abcdefg abcdefgh abcd1234 abc123 iloveyou monkey dragon
+	master shadow sunshine princess football baseball soccer hockey
+	superman batman trustno1 freedom whatever secret changeme default
+	guest access starwars computer internet michael jennifer jordan
+	charlie hunter ranger buster thomas tigger robert daniel hannah
+	maggie ginger summer winter spring autumn flower cookie cheese
+	pepper mustang harley killer hello hello123 test testing test123
+	temp temp123 pass pass123 user demo oracle mysql postgres ubuntu
+	linux server manager support service backup system security
+	123456 1234567 12345678 123456789 1234567890 111111 000000
+	123123 654321 666666 121212 112233 987654321 11111111 88888888
+	1q2w3e 1q2w3e4r 1qaz2wsx qazwsx zaq12wsx q1w2e3r4
+`)
+
+// wordSet builds a lookup set from whitespace separated words
+func wordSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
@@ -0,0 +1,254 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bariiss/pam-auth/util/crypt"
+)
+
+// Policy configures the checks a new password must pass. Zero values
+// disable a check.
+type Policy struct {
+	// MinLength and MaxLength bound the length in characters
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+	// MinClasses is how many of lowercase, uppercase, digits and symbols
+	// must appear
+	MinClasses int `json:"min_classes"`
+	// Dictionary rejects common passwords and, with DictionaryFile, the
+	// words of a word list, also with digits, symbols and l33t spelling
+	// around them
+	Dictionary     bool   `json:"dictionary"`
+	DictionaryFile string `json:"dictionary_file,omitempty"`
+	// CheckUser rejects passwords containing the user name or a word of the
+	// full name
+	CheckUser bool `json:"check_user"`
+	// History rejects the current and previous History-1 passwords
+	History int `json:"history"`
+	// BreachedFile is a sorted list of SHA-1 hashes of breached passwords
+	// (see Breached)
+	BreachedFile string `json:"breached_file,omitempty"`
+}
+
+// NIST follows NIST SP 800-63B: at least 8 and up to 64 characters, no
+// composition rules, and screening against common passwords and context
+// specific words. Breached passwords are screened once a list is given.
+var NIST = Policy{MinLength: 8, MaxLength: 64, Dictionary: true, CheckUser: true}
+
+// Presets are the policies selectable by name
+var Presets = map[string]Policy{"none": {}, "nist": NIST}
+
+// Preset returns the policy called name
+func Preset(name string) (Policy, error) {
+	p, ok := Presets[name]
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown password policy %q, expected none or nist", name)
+	}
+	return p, nil
+}
+
+// Account is the context a password is checked in
+type Account struct {
+	Username string
+	// Gecos is the full name field
+	Gecos string
+	// History holds the hashes of the current and earlier passwords,
+	// newest first
+	History []string
+}
+
+// Violation is a check a password failed, with a reason users can act on
+type Violation struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// Check returns every rule password breaks for account. An error means a
+// list or hash could not be read, not that the password was rejected.
+func (p Policy) Check(password string, account Account) ([]Violation, error) {
+	var violations []Violation
+	reject := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		reject("length", "must be at least %d characters (has %d)", p.MinLength, length)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		reject("length", "must be at most %d characters (has %d)", p.MaxLength, length)
+	}
+	if used := classes(password); p.MinClasses > 0 && len(used) < p.MinClasses {
+		reject("classes", "must use at least %d of lowercase, uppercase, digits and symbols (uses %s)", p.MinClasses, describeClasses(used))
+	}
+
+	if p.Dictionary {
+		word, ok := dictionaryWord(password, commonPasswords)
+		if !ok && p.DictionaryFile != "" {
+			words, err := loadWords(p.DictionaryFile)
+			if err != nil {
+				return nil, err
+			}
+			word, ok = dictionaryWord(password, words)
+		}
+		if ok {
+			if word == strings.ToLower(password) {
+				reject("dictionary", "must not be a common password or dictionary word")
+			} else {
+				reject("dictionary", "must not be based on the common word %q", word)
+			}
+		}
+	}
+
+	if p.CheckUser {
+		if reason, ok := similar(password, account); ok {
+			reject("user", "%s", reason)
+		}
+	}
+
+	if p.History > 0 {
+		for i, hash := range account.History {
+			if i == p.History {
+				break
+			}
+			match, err := crypt.Verify(hash, password)
+			if err != nil {
+				return nil, fmt.Errorf("password history: %w", err)
+			}
+			if match {
+				if i == 0 {
+					reject("history", "must differ from the current password")
+				} else {
+					reject("history", "must not reuse one of the last %d passwords", p.History)
+				}
+				break
+			}
+		}
+	}
+
+	if p.BreachedFile != "" {
+		count, err := Breached(p.BreachedFile, password)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case count > 1:
+			reject("breached", "must not appear in the breached password list (seen %d times)", count)
+		case count == 1:
+			reject("breached", "must not appear in the breached password list")
+		}
+	}
+	return violations, nil
+}
+
+// Character classes counted by MinClasses
+var classNames = []string{"lowercase", "uppercase", "digits", "symbols"}
+
+// classes returns the names of the character classes password uses
+func classes(password string) []string {
+	seen := make([]bool, len(classNames))
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			seen[0] = true
+		case unicode.IsUpper(r):
+			seen[1] = true
+		case unicode.IsDigit(r):
+			seen[2] = true
+		default:
+			seen[3] = true
+		}
+	}
+	var used []string
+	for i, name := range classNames {
+		if seen[i] {
+			used = append(used, name)
+		}
+	}
+	return used
+}
+
+// describeClasses formats the classes a password uses
+func describeClasses(used []string) string {
+	if len(used) == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d: %s", len(used), strings.Join(used, ", "))
+}
+
+// leet undoes common character substitutions
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// dictionaryWord reports the word password is built on: the password
+// itself, or what remains after stripping digits and symbols from both
+// ends, read as is or with l33t spelling undone
+func dictionaryWord(password string, words map[string]bool) (string, bool) {
+	letters := func(s string) string {
+		return strings.TrimFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	}
+	lower := strings.ToLower(password)
+	for _, candidate := range []string{lower, letters(lower), leet.Replace(letters(lower)), leet.Replace(lower), letters(leet.Replace(lower))} {
+		// Stripping down to a couple of letters says nothing about the password
+		if utf8.RuneCountInString(candidate) >= 4 && words[candidate] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// similar reports whether password contains the user name, reversed or
+// not, or a word of at least three letters from the full name
+func similar(password string, account Account) (string, bool) {
+	lower := strings.ToLower(password)
+	plain := leet.Replace(lower)
+	contains := func(word string) bool {
+		return strings.Contains(lower, word) || strings.Contains(plain, word)
+	}
+
+	if name := strings.ToLower(account.Username); len(name) >= 3 {
+		if contains(name) {
+			return fmt.Sprintf("must not contain the user name %q", account.Username), true
+		}
+		if contains(reverse(name)) {
+			return fmt.Sprintf("must not contain the user name %q reversed", account.Username), true
+		}
+	}
+	for _, word := range strings.FieldsFunc(account.Gecos, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if utf8.RuneCountInString(word) >= 3 && contains(strings.ToLower(word)) {
+			return fmt.Sprintf("must not contain %q from the full name", word), true
+		}
+	}
+	return "", false
+}
+
+// reverse reverses s by characters
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// loadWords reads a word list with one word per line
+func loadWords(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if word := strings.ToLower(strings.TrimSpace(scanner.Text())); word != "" && !strings.HasPrefix(word, "#") {
+			words[word] = true
+		}
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,209 @@
+package radius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// DefaultClientTimeout is the per-attempt timeout for a RADIUS exchange
+const DefaultClientTimeout = 3 * time.Second
+
+// maxChallengeRounds bounds the number of Access-Challenge round trips
+const maxChallengeRounds = 5
+
+// Client authenticates users against one or more RADIUS servers. Servers are
+// tried in order; a server that does not answer within Timeout is retried
+// Retries times before failing over to the next one.
+type Client struct {
+	// Servers lists host:port addresses in priority order
+	Servers []string
+	// Secret is the shared secret used with every server
+	Secret []byte
+	// Timeout bounds each individual attempt
+	Timeout time.Duration
+	// Retries is the number of additional attempts per server
+	Retries int
+	// NASIdentifier is sent as NAS-Identifier when set
+	NASIdentifier string
+	// GroupMap translates Class/Filter-Id values to group names. Values
+	// without an entry are used as group names unchanged.
+	GroupMap map[string]string
+	// Prompt asks the user to answer an Access-Challenge. Challenges are
+	// rejected when it is nil.
+	Prompt func(message string) (string, error)
+}
+
+// Authenticate implements auth.Authenticator using PAP
+func (c *Client) Authenticate(ctx context.Context, username, password string) auth.Result {
+	if len(c.Servers) == 0 {
+		return auth.Failure("radius", username, "no RADIUS servers configured")
+	}
+
+	request := c.newRequest(username, password, nil)
+	response, server, err := c.exchangeAny(ctx, request)
+	if ctx.Err() != nil {
+		return auth.Aborted(ctx, "radius", username)
+	}
+	if err != nil {
+		return auth.Unreachable("radius", username, err.Error())
+	}
+
+	for round := 0; response.Code == radius.CodeAccessChallenge; round++ {
+		if round >= maxChallengeRounds {
+			return auth.Failure("radius", username, "too many Access-Challenge rounds")
+		}
+		if c.Prompt == nil {
+			return auth.Failure("radius", username, "server requested a challenge but no prompt is available")
+		}
+
+		message := rfc2865.ReplyMessage_GetString(response)
+		if message == "" {
+			message = "Response: "
+		}
+		answer, err := c.Prompt(message)
+		if err != nil {
+			return auth.Failure("radius", username, err.Error())
+		}
+
+		// Challenge replies must go to the server holding the State
+		request = c.newRequest(username, answer, rfc2865.State_Get(response))
+		response, err = c.exchangeWithRetries(ctx, request, server)
+		if ctx.Err() != nil {
+			return auth.Aborted(ctx, "radius", username)
+		}
+		if err != nil {
+			return auth.Failure("radius", username, err.Error())
+		}
+	}
+
+	switch response.Code {
+	case radius.CodeAccessAccept:
+		return auth.Result{Username: username, Backend: "radius", Success: true, Groups: c.groupsFrom(response)}
+	case radius.CodeAccessReject:
+		return auth.Failure("radius", username, rfc2865.ReplyMessage_GetString(response))
+	}
+	return auth.Failure("radius", username, fmt.Sprintf("unexpected RADIUS response %v", response.Code))
+}
+
+// Ping implements auth.Pinger with a Status-Server request (RFC 5997),
+// which servers answer without looking up a user
+func (c *Client) Ping(ctx context.Context) error {
+	if len(c.Servers) == 0 {
+		return errors.New("no RADIUS servers configured")
+	}
+	request := radius.New(radius.CodeStatusServer, c.Secret)
+	if c.NASIdentifier != "" {
+		rfc2865.NASIdentifier_SetString(request, c.NASIdentifier)
+	}
+	SignMessageAuthenticator(request)
+	_, _, err := c.exchangeAny(ctx, request)
+	return err
+}
+
+// newRequest builds a signed Access-Request with a PAP-encrypted password
+func (c *Client) newRequest(username, password string, state []byte) *radius.Packet {
+	packet := radius.New(radius.CodeAccessRequest, c.Secret)
+	rfc2865.UserName_SetString(packet, username)
+	rfc2865.UserPassword_SetString(packet, password)
+	rfc2865.ServiceType_Set(packet, rfc2865.ServiceType_Value_AuthenticateOnly)
+	if c.NASIdentifier != "" {
+		rfc2865.NASIdentifier_SetString(packet, c.NASIdentifier)
+	}
+	if len(state) > 0 {
+		rfc2865.State_Set(packet, state)
+	}
+	SignMessageAuthenticator(packet)
+	return packet
+}
+
+// exchangeAny sends request to each server in turn and returns the first answer
+func (c *Client) exchangeAny(ctx context.Context, request *radius.Packet) (*radius.Packet, string, error) {
+	var lastErr error
+	for _, server := range c.Servers {
+		response, err := c.exchangeWithRetries(ctx, request, server)
+		if err == nil {
+			return response, server, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		fmt.Printf("⚠️ RADIUS server %s unavailable: %v\n", server, err)
+		lastErr = err
+	}
+	return nil, "", fmt.Errorf("all RADIUS servers failed: %w", lastErr)
+}
+
+// exchangeWithRetries sends request to server up to Retries+1 times,
+// giving up early when ctx is done
+func (c *Client) exchangeWithRetries(ctx context.Context, request *radius.Packet, server string) (*radius.Packet, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultClientTimeout
+	}
+	client := &radius.Client{MaxPacketErrors: 10}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.Retries && ctx.Err() == nil; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		response, err := client.Exchange(attemptCtx, request, server)
+		cancel()
+		if err == nil {
+			// A response without a Message-Authenticator to a request that
+			// carried one may be forged (BlastRADIUS, CVE-2024-3596)
+			switch {
+			case HasMessageAuthenticator(response):
+				if !VerifyMessageAuthenticator(response, request.Authenticator) {
+					lastErr = errors.New("invalid Message-Authenticator in response")
+					continue
+				}
+			case HasMessageAuthenticator(request):
+				lastErr = errors.New("response without Message-Authenticator")
+				continue
+			}
+			return response, nil
+		}
+		lastErr = err
+
+		// A refused port will not start answering on the next attempt
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && !opErr.Timeout() {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, lastErr
+}
+
+// groupsFrom maps the Class and Filter-Id attributes of an Access-Accept to groups
+func (c *Client) groupsFrom(response *radius.Packet) []string {
+	var values []string
+	if classes, err := rfc2865.Class_GetStrings(response); err == nil {
+		values = append(values, classes...)
+	}
+	if filters, err := rfc2865.FilterID_GetStrings(response); err == nil {
+		values = append(values, filters...)
+	}
+
+	seen := make(map[string]bool)
+	var groups []string
+	for _, value := range values {
+		group := value
+		if mapped, ok := c.GroupMap[value]; ok {
+			group = mapped
+		}
+		if group != "" && !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
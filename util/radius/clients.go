@@ -0,0 +1,104 @@
+package radius
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// NASClient is a network access server allowed to talk to the RADIUS server
+type NASClient struct {
+	Network *net.IPNet
+	Secret  []byte
+}
+
+// ClientList is a SecretSource that picks the shared secret by source address.
+// The most specific matching network wins.
+type ClientList []NASClient
+
+// ParseClient parses a "CIDR-or-IP secret" pair
+func ParseClient(address, secret string) (NASClient, error) {
+	if secret == "" {
+		return NASClient{}, fmt.Errorf("empty shared secret for %s", address)
+	}
+
+	if !strings.Contains(address, "/") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return NASClient{}, fmt.Errorf("invalid client address: %s", address)
+		}
+		if ip.To4() != nil {
+			address += "/32"
+		} else {
+			address += "/128"
+		}
+	}
+
+	_, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return NASClient{}, fmt.Errorf("invalid client network: %v", err)
+	}
+	return NASClient{Network: network, Secret: []byte(secret)}, nil
+}
+
+// LoadClients reads a clients file with one "CIDR-or-IP secret" entry per line
+func LoadClients(path string) (ClientList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var clients ClientList
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"address secret\"", path, lineNo)
+		}
+		client, err := ParseClient(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, scanner.Err()
+}
+
+// Lookup returns the secret for ip, or nil if the address is not a known client
+func (c ClientList) Lookup(ip net.IP) []byte {
+	var best []byte
+	bestLen := -1
+	for _, client := range c {
+		if !client.Network.Contains(ip) {
+			continue
+		}
+		if ones, _ := client.Network.Mask.Size(); ones > bestLen {
+			best, bestLen = client.Secret, ones
+		}
+	}
+	return best
+}
+
+// RADIUSSecret implements radius.SecretSource. Unknown clients get an empty
+// secret, which makes the server discard their packets.
+func (c ClientList) RADIUSSecret(ctx context.Context, remoteAddr net.Addr) ([]byte, error) {
+	udpAddr, ok := remoteAddr.(*net.UDPAddr)
+	if !ok {
+		return nil, nil
+	}
+	secret := c.Lookup(udpAddr.IP)
+	if secret == nil {
+		fmt.Printf("⚠️ RADIUS request from unknown client %s dropped\n", udpAddr.IP)
+	}
+	return secret, nil
+}
@@ -0,0 +1,93 @@
+package radius
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2869"
+)
+
+// messageAuthenticatorLen is the length of the HMAC-MD5 Message-Authenticator value
+const messageAuthenticatorLen = 16
+
+// HasMessageAuthenticator reports whether p carries a Message-Authenticator attribute
+func HasMessageAuthenticator(p *radius.Packet) bool {
+	_, ok := p.Lookup(rfc2869.MessageAuthenticator_Type)
+	return ok
+}
+
+// VerifyMessageAuthenticator validates the Message-Authenticator of a received
+// packet (RFC 3579 section 3.2). authenticator is the Request Authenticator the
+// HMAC was computed over: the packet's own for requests, the original
+// request's for responses.
+func VerifyMessageAuthenticator(p *radius.Packet, authenticator [16]byte) bool {
+	received := rfc2869.MessageAuthenticator_Get(p)
+	if len(received) != messageAuthenticatorLen {
+		return false
+	}
+
+	expected, err := computeMessageAuthenticator(p, authenticator)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(received, expected)
+}
+
+// SignMessageAuthenticator adds or replaces the Message-Authenticator of an
+// outgoing packet. It must be called after all other attributes are set.
+func SignMessageAuthenticator(p *radius.Packet) error {
+	sum, err := computeMessageAuthenticator(p, p.Authenticator)
+	if err != nil {
+		return err
+	}
+	return rfc2869.MessageAuthenticator_Set(p, sum)
+}
+
+// computeMessageAuthenticator returns HMAC-MD5(secret, packet) with the
+// Message-Authenticator value zeroed and authenticator in the header
+func computeMessageAuthenticator(p *radius.Packet, authenticator [16]byte) ([]byte, error) {
+	clone := *p
+	clone.Authenticator = authenticator
+	clone.Attributes = make(radius.Attributes, len(p.Attributes))
+	copy(clone.Attributes, p.Attributes)
+
+	zero := make([]byte, messageAuthenticatorLen)
+	found := false
+	for i, avp := range clone.Attributes {
+		if avp.Type == rfc2869.MessageAuthenticator_Type {
+			clone.Attributes[i] = &radius.AVP{Type: avp.Type, Attribute: zero}
+			found = true
+		}
+	}
+	if !found {
+		clone.Attributes = append(clone.Attributes, &radius.AVP{Type: rfc2869.MessageAuthenticator_Type, Attribute: zero})
+	}
+
+	wire, err := clone.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(md5.New, p.Secret)
+	mac.Write(wire)
+	return mac.Sum(nil), nil
+}
+
+// chapResponse computes the RFC 1994 CHAP response MD5(id || password || challenge)
+func chapResponse(id byte, password string, challenge []byte) []byte {
+	hash := md5.New()
+	hash.Write([]byte{id})
+	hash.Write([]byte(password))
+	hash.Write(challenge)
+	return hash.Sum(nil)
+}
+
+// verifyCHAP checks a CHAP-Password attribute value against the cleartext password
+func verifyCHAP(chapPassword []byte, password string, challenge []byte) bool {
+	if len(chapPassword) != 17 {
+		return false
+	}
+	expected := chapResponse(chapPassword[0], password, challenge)
+	return hmac.Equal(expected, chapPassword[1:])
+}
@@ -0,0 +1,91 @@
+package radius
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// ReplyRule adds a reply attribute to Access-Accept when the user is in Group
+type ReplyRule struct {
+	Group     string
+	Attribute string
+	Value     string
+}
+
+// replyAttributes lists the attribute names a ReplyRule may set
+var replyAttributes = []string{"Class", "Filter-Id", "Reply-Message", "Service-Type", "Session-Timeout", "Idle-Timeout"}
+
+// ParseReplyRule parses a "GROUP=ATTRIBUTE:VALUE" rule, e.g. "wheel=Service-Type:Administrative-User"
+func ParseReplyRule(spec string) (ReplyRule, error) {
+	group, rest, ok := strings.Cut(spec, "=")
+	if !ok || group == "" {
+		return ReplyRule{}, fmt.Errorf("invalid reply rule %q: expected GROUP=ATTRIBUTE:VALUE", spec)
+	}
+	attribute, value, ok := strings.Cut(rest, ":")
+	if !ok || value == "" {
+		return ReplyRule{}, fmt.Errorf("invalid reply rule %q: expected GROUP=ATTRIBUTE:VALUE", spec)
+	}
+
+	rule := ReplyRule{Group: group, Attribute: attribute, Value: value}
+	// Validate the attribute and value up front so mistakes surface at startup
+	if err := rule.apply(radius.New(radius.CodeAccessAccept, []byte("validate"))); err != nil {
+		return ReplyRule{}, err
+	}
+	return rule, nil
+}
+
+// apply adds the rule's attribute to p
+func (r ReplyRule) apply(p *radius.Packet) error {
+	switch strings.ToLower(r.Attribute) {
+	case "class":
+		return rfc2865.Class_AddString(p, r.Value)
+	case "filter-id":
+		return rfc2865.FilterID_AddString(p, r.Value)
+	case "reply-message":
+		return rfc2865.ReplyMessage_AddString(p, r.Value)
+	case "service-type":
+		for value, name := range rfc2865.ServiceType_Strings {
+			if strings.EqualFold(name, r.Value) {
+				return rfc2865.ServiceType_Add(p, value)
+			}
+		}
+		n, err := strconv.ParseUint(r.Value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("unknown Service-Type value: %s", r.Value)
+		}
+		return rfc2865.ServiceType_Add(p, rfc2865.ServiceType(n))
+	case "session-timeout":
+		n, err := strconv.ParseUint(r.Value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid Session-Timeout value: %s", r.Value)
+		}
+		return rfc2865.SessionTimeout_Add(p, rfc2865.SessionTimeout(n))
+	case "idle-timeout":
+		n, err := strconv.ParseUint(r.Value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid Idle-Timeout value: %s", r.Value)
+		}
+		return rfc2865.IdleTimeout_Add(p, rfc2865.IdleTimeout(n))
+	}
+	return fmt.Errorf("unsupported reply attribute %q (supported: %s)", r.Attribute, strings.Join(replyAttributes, ", "))
+}
+
+// applyReplyRules adds every rule matching one of groups to p
+func applyReplyRules(p *radius.Packet, rules []ReplyRule, groups []string) error {
+	member := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		member[group] = true
+	}
+	for _, rule := range rules {
+		if rule.Group == "*" || member[rule.Group] {
+			if err := rule.apply(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package radius
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2759"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc3079"
+	"layeh.com/radius/vendors/microsoft"
+)
+
+// DefaultChallengeTimeout is how long an Access-Challenge State stays valid
+const DefaultChallengeTimeout = 2 * time.Minute
+
+// SecondFactor verifies the response to an Access-Challenge
+type SecondFactor interface {
+	// Enrolled reports whether username must complete the second factor
+	Enrolled(username string) bool
+	// Verify checks the code the user entered in reply to the challenge
+	Verify(username, code string) bool
+}
+
+// Server answers RFC 2865 Access-Requests using a pam-auth Authenticator
+type Server struct {
+	// Addr is the UDP address to listen on. Defaults to :1812.
+	Addr string
+	// Clients maps NAS addresses to their shared secrets
+	Clients ClientList
+	// Authenticator verifies PAP passwords
+	Authenticator auth.Authenticator
+	// SecondFactor, when set, issues an Access-Challenge after a correct password
+	SecondFactor SecondFactor
+	// ChallengePrompt is sent as Reply-Message in the Access-Challenge
+	ChallengePrompt string
+	// ChallengeTimeout bounds how long a challenge may remain unanswered
+	ChallengeTimeout time.Duration
+	// ReplyRules derive Access-Accept attributes from group membership
+	ReplyRules []ReplyRule
+	// RequireMessageAuthenticator drops Access-Requests without a Message-Authenticator
+	RequireMessageAuthenticator bool
+	// AuthTimeout bounds each backend call (zero means no limit)
+	AuthTimeout time.Duration
+	// Observe, when set, is called with the result and duration of every
+	// PAP and CHAP verification
+	Observe func(result auth.Result, elapsed time.Duration)
+
+	mu      sync.Mutex
+	pending map[string]challengeState
+	server  *radius.PacketServer
+}
+
+// challengeState remembers a user who passed the first factor
+type challengeState struct {
+	username string
+	groups   []string
+	expires  time.Time
+}
+
+// ListenAndServe starts the UDP listener and blocks until Shutdown is called
+func (s *Server) ListenAndServe() error {
+	if s.Authenticator == nil {
+		return errors.New("radius: no authenticator configured")
+	}
+	if len(s.Clients) == 0 {
+		return errors.New("radius: no clients configured")
+	}
+
+	addr := s.Addr
+	if addr == "" {
+		addr = ":1812"
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve answers requests arriving on conn
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	s.server = &radius.PacketServer{
+		Handler:      s,
+		SecretSource: s.Clients,
+	}
+	server := s.server
+	s.mu.Unlock()
+
+	fmt.Printf("📡 RADIUS server listening on %s\n", conn.LocalAddr())
+	err := server.Serve(conn)
+	if errors.Is(err, radius.ErrServerShutdown) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the server, waiting for in-flight requests to finish
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// ServeRADIUS implements radius.Handler
+func (s *Server) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
+	switch r.Code {
+	case radius.CodeAccessRequest, radius.CodeStatusServer:
+	default:
+		fmt.Printf("⚠️ Ignoring unsupported RADIUS packet %v from %s\n", r.Code, r.RemoteAddr)
+		return
+	}
+
+	if HasMessageAuthenticator(r.Packet) {
+		if !VerifyMessageAuthenticator(r.Packet, r.Authenticator) {
+			fmt.Printf("⚠️ Invalid Message-Authenticator from %s, request dropped\n", r.RemoteAddr)
+			return
+		}
+	} else if s.RequireMessageAuthenticator || r.Code == radius.CodeStatusServer {
+		// RFC 5997 requires a Message-Authenticator in every Status-Server
+		fmt.Printf("⚠️ Missing Message-Authenticator from %s, request dropped\n", r.RemoteAddr)
+		return
+	}
+
+	if r.Code == radius.CodeStatusServer {
+		// RFC 5997: answer Status-Server with Access-Accept
+		s.respond(w, r.Response(radius.CodeAccessAccept))
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "radius Access-Request", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	username := rfc2865.UserName_GetString(r.Packet)
+	if username == "" {
+		s.reject(w, r, "", "missing User-Name")
+		return
+	}
+	span.SetAttributes(semconv.EnduserID(username))
+
+	if state := rfc2865.State_Get(r.Packet); len(state) > 0 {
+		s.handleChallengeResponse(w, r, username, state)
+		return
+	}
+
+	var result auth.Result
+	var extra func(*radius.Packet) error
+	started := time.Now()
+	switch {
+	case len(rfc2865.UserPassword_Get(r.Packet)) > 0:
+		fmt.Printf("📡 RADIUS PAP request from %s for user: %s\n", r.RemoteAddr, username)
+		ctx, cancel := auth.WithTimeout(r.Context(), s.AuthTimeout)
+		result = tracing.Authenticate(ctx, "backend", s.Authenticator, username, rfc2865.UserPassword_GetString(r.Packet))
+		cancel()
+	case len(rfc2865.CHAPPassword_Get(r.Packet)) > 0:
+		fmt.Printf("📡 RADIUS CHAP request from %s for user: %s\n", r.RemoteAddr, username)
+		result = s.authenticateCHAP(r.Packet, username)
+	case len(microsoft.MSCHAP2Response_Get(r.Packet)) > 0:
+		fmt.Printf("📡 RADIUS MS-CHAPv2 request from %s for user: %s\n", r.RemoteAddr, username)
+		result, extra = s.authenticateMSCHAPv2(r.Packet, username)
+		if result.Success && s.SecondFactor != nil && s.SecondFactor.Enrolled(username) {
+			// The MPPE keys cannot wait for the reply to an Access-Challenge
+			result = auth.Failure("radius", username, "MS-CHAPv2 cannot complete a second factor")
+		}
+	default:
+		s.reject(w, r, username, "no supported authentication attributes")
+		return
+	}
+	if s.Observe != nil {
+		s.Observe(result, time.Since(started))
+	}
+
+	if !result.Success {
+		s.reject(w, r, username, result.Message)
+		return
+	}
+
+	if s.SecondFactor != nil && s.SecondFactor.Enrolled(username) {
+		s.challenge(w, r, username, result.Groups)
+		return
+	}
+
+	s.accept(w, r, username, result.Groups, extra)
+}
+
+// authenticateCHAP validates a CHAP-Password using the backend's cleartext password
+func (s *Server) authenticateCHAP(p *radius.Packet, username string) auth.Result {
+	source, ok := s.Authenticator.(auth.PasswordSource)
+	if !ok {
+		return auth.Failure("radius", username, "CHAP requires a backend with cleartext passwords")
+	}
+	password, ok := source.CleartextPassword(username)
+	if !ok {
+		return auth.Failure("radius", username, "unknown user")
+	}
+
+	challenge := rfc2865.CHAPChallenge_Get(p)
+	if len(challenge) == 0 {
+		challenge = p.Authenticator[:]
+	}
+	if !verifyCHAP(rfc2865.CHAPPassword_Get(p), password, challenge) {
+		return auth.Failure("radius", username, "CHAP response mismatch")
+	}
+	return auth.Result{Username: username, Backend: "radius", Success: true, Groups: s.groups(username)}
+}
+
+// authenticateMSCHAPv2 validates an MS-CHAPv2 response (RFC 2759). On
+// success it also returns the function adding MS-CHAP2-Success and the
+// MPPE keys to the Access-Accept.
+func (s *Server) authenticateMSCHAPv2(p *radius.Packet, username string) (auth.Result, func(*radius.Packet) error) {
+	source, ok := s.Authenticator.(auth.PasswordSource)
+	if !ok {
+		return auth.Failure("radius", username, "MS-CHAPv2 requires a backend with cleartext passwords"), nil
+	}
+	password, ok := source.CleartextPassword(username)
+	if !ok {
+		return auth.Failure("radius", username, "unknown user"), nil
+	}
+
+	challenge := microsoft.MSCHAPChallenge_Get(p)
+	response := microsoft.MSCHAP2Response_Get(p)
+	if len(challenge) != 16 || len(response) != 50 {
+		return auth.Failure("radius", username, "malformed MS-CHAPv2 attributes"), nil
+	}
+
+	// RFC 2548 section 2.3.2: Ident, Flags, Peer-Challenge, Reserved, Response
+	ident := response[0]
+	peerChallenge := response[2:18]
+	peerResponse := response[26:50]
+	ntResponse, err := rfc2759.GenerateNTResponse(challenge, peerChallenge, []byte(username), []byte(password))
+	if err != nil || !hmac.Equal(ntResponse, peerResponse) {
+		return auth.Failure("radius", username, "MS-CHAPv2 response mismatch"), nil
+	}
+
+	authenticatorResponse, err := rfc2759.GenerateAuthenticatorResponse(challenge, peerChallenge, ntResponse, []byte(username), []byte(password))
+	if err != nil {
+		return auth.Failure("radius", username, err.Error()), nil
+	}
+	recvKey, err := rfc3079.MakeKey(ntResponse, []byte(password), false)
+	if err != nil {
+		return auth.Failure("radius", username, err.Error()), nil
+	}
+	sendKey, err := rfc3079.MakeKey(ntResponse, []byte(password), true)
+	if err != nil {
+		return auth.Failure("radius", username, err.Error()), nil
+	}
+
+	success := make([]byte, 43)
+	success[0] = ident
+	copy(success[1:], authenticatorResponse)
+
+	result := auth.Result{Username: username, Backend: "radius", Success: true, Groups: s.groups(username)}
+	return result, func(p *radius.Packet) error {
+		if err := microsoft.MSCHAP2Success_Add(p, success); err != nil {
+			return err
+		}
+		if err := microsoft.MSMPPERecvKey_Add(p, recvKey); err != nil {
+			return err
+		}
+		if err := microsoft.MSMPPESendKey_Add(p, sendKey); err != nil {
+			return err
+		}
+		if err := microsoft.MSMPPEEncryptionPolicy_Add(p, microsoft.MSMPPEEncryptionPolicy_Value_EncryptionAllowed); err != nil {
+			return err
+		}
+		return microsoft.MSMPPEEncryptionTypes_Add(p, microsoft.MSMPPEEncryptionTypes_Value_RC440or128BitAllowed)
+	}
+}
+
+// handleChallengeResponse completes the second factor for a pending State
+func (s *Server) handleChallengeResponse(w radius.ResponseWriter, r *radius.Request, username string, state []byte) {
+	key := hex.EncodeToString(state)
+
+	s.mu.Lock()
+	pending, ok := s.pending[key]
+	delete(s.pending, key)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(pending.expires) || pending.username != username {
+		s.reject(w, r, username, "unknown or expired challenge state")
+		return
+	}
+
+	fmt.Printf("📡 RADIUS challenge response from %s for user: %s\n", r.RemoteAddr, username)
+	_, span := tracing.Tracer().Start(r.Context(), "second factor")
+	verified := s.SecondFactor.Verify(username, rfc2865.UserPassword_GetString(r.Packet))
+	span.End()
+	if !verified {
+		s.reject(w, r, username, "invalid verification code")
+		return
+	}
+	s.accept(w, r, username, pending.groups, nil)
+}
+
+// challenge sends an Access-Challenge and remembers the State for the reply
+func (s *Server) challenge(w radius.ResponseWriter, r *radius.Request, username string, groups []string) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		s.reject(w, r, username, "cannot generate challenge state")
+		return
+	}
+
+	timeout := s.ChallengeTimeout
+	if timeout <= 0 {
+		timeout = DefaultChallengeTimeout
+	}
+	prompt := s.ChallengePrompt
+	if prompt == "" {
+		prompt = "Verification code: "
+	}
+
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]challengeState)
+	}
+	now := time.Now()
+	for key, pending := range s.pending {
+		if now.After(pending.expires) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[hex.EncodeToString(state)] = challengeState{username: username, groups: groups, expires: now.Add(timeout)}
+	s.mu.Unlock()
+
+	response := r.Response(radius.CodeAccessChallenge)
+	rfc2865.State_Set(response, state)
+	rfc2865.ReplyMessage_SetString(response, prompt)
+	rfc2865.SessionTimeout_Set(response, rfc2865.SessionTimeout(timeout/time.Second))
+
+	fmt.Printf("🔐 Access-Challenge sent to %s for user: %s\n", r.RemoteAddr, username)
+	s.respond(w, response)
+}
+
+// accept sends Access-Accept with the reply attributes for groups. extra, when
+// non-nil, adds protocol specific attributes.
+func (s *Server) accept(w radius.ResponseWriter, r *radius.Request, username string, groups []string, extra func(*radius.Packet) error) {
+	response := r.Response(radius.CodeAccessAccept)
+	if err := applyReplyRules(response, s.ReplyRules, groups); err != nil {
+		s.reject(w, r, username, err.Error())
+		return
+	}
+	if extra != nil {
+		if err := extra(response); err != nil {
+			s.reject(w, r, username, err.Error())
+			return
+		}
+	}
+
+	fmt.Printf("✅ Access-Accept sent to %s for user: %s\n", r.RemoteAddr, username)
+	s.respond(w, response)
+}
+
+// reject sends Access-Reject
+func (s *Server) reject(w radius.ResponseWriter, r *radius.Request, username, reason string) {
+	if reason == "" {
+		reason = "authentication failed"
+	}
+	fmt.Printf("❌ Access-Reject sent to %s for user %q: %s\n", r.RemoteAddr, username, reason)
+	s.respond(w, r.Response(radius.CodeAccessReject))
+}
+
+// respond signs the Message-Authenticator and writes the packet
+func (s *Server) respond(w radius.ResponseWriter, p *radius.Packet) {
+	if err := SignMessageAuthenticator(p); err != nil {
+		fmt.Printf("⚠️ Cannot sign RADIUS response: %v\n", err)
+		return
+	}
+	if err := w.Write(p); err != nil {
+		fmt.Printf("⚠️ Cannot send RADIUS response: %v\n", err)
+	}
+}
+
+// groups returns username's groups when the backend can list them
+func (s *Server) groups(username string) []string {
+	if source, ok := s.Authenticator.(auth.GroupSource); ok {
+		return source.Groups(username)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package runas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bariiss/pam-auth/util/sysroot"
+)
+
+// Default search paths when login.defs sets no ENV_PATH or ENV_SUPATH, as
+// in shadow's su
+const (
+	DefaultPath   = "/usr/local/bin:/usr/bin:/bin"
+	DefaultSUPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// ErrUnsupported is returned where processes cannot change their user
+var ErrUnsupported = errors.New("running commands as another user is not supported on this platform")
+
+// Target is the account a command runs as
+type Target struct {
+	Username string
+	UID      int
+	GID      int
+	// Groups are the supplementary group IDs, as initgroups(3) sets them
+	Groups []int
+	Home   string
+	// Shell is the login shell, /bin/sh when the passwd entry has none
+	Shell string
+}
+
+// LookupTarget resolves username's IDs, groups, home and shell on the host
+func LookupTarget(username string) (Target, error) {
+	entry, err := sysroot.Root("").LookupPasswd(username)
+	if err != nil {
+		return Target{}, err
+	}
+	target := Target{
+		Username: entry.Name,
+		UID:      entry.UID,
+		GID:      entry.GID,
+		Home:     entry.Home,
+		Shell:    entry.Shell,
+	}
+	if target.Shell == "" {
+		target.Shell = "/bin/sh"
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return Target{}, err
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return Target{}, fmt.Errorf("groups of %s: %w", username, err)
+	}
+	for _, id := range ids {
+		gid, err := strconv.Atoi(id)
+		if err != nil {
+			return Target{}, fmt.Errorf("groups of %s: invalid gid %q", username, id)
+		}
+		target.Groups = append(target.Groups, gid)
+	}
+	return target, nil
+}
+
+// Environment returns the clean environment of a login as t: HOME, SHELL,
+// USER, LOGNAME and PATH from login.defs (ENV_SUPATH for root, ENV_PATH
+// otherwise), TERM from the caller, then extra NAME=VALUE pairs such as
+// those set by PAM session modules, which replace the variables above
+func Environment(t Target, defs map[string]string, extra []string) []string {
+	key, path := "ENV_PATH", DefaultPath
+	if t.UID == 0 {
+		key, path = "ENV_SUPATH", DefaultSUPath
+	}
+	if value, ok := defs[key]; ok {
+		// login.defs accepts both "PATH=..." and a bare path
+		path = strings.TrimPrefix(value, "PATH=")
+	}
+
+	env := []string{
+		"HOME=" + t.Home,
+		"SHELL=" + t.Shell,
+		"USER=" + t.Username,
+		"LOGNAME=" + t.Username,
+		"PATH=" + path,
+	}
+	if term, ok := os.LookupEnv("TERM"); ok {
+		env = append(env, "TERM="+term)
+	}
+	for _, kv := range extra {
+		name, _, _ := strings.Cut(kv, "=")
+		env = slices.DeleteFunc(env, func(existing string) bool {
+			return strings.HasPrefix(existing, name+"=")
+		})
+		env = append(env, kv)
+	}
+	return env
+}
+
+// LookPath finds an executable named name in the directories of path, the
+// value of a PATH variable. Names containing a slash are used as they are.
+func LookPath(name, path string) (string, error) {
+	if strings.Contains(name, "/") {
+		if err := executable(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			// An empty entry is not the current directory here
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		if executable(candidate) == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s: command not found", name)
+}
+
+// executable reports why file cannot be run, or nil
+func executable(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s: permission denied", file)
+	}
+	return nil
+}
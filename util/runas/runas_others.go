@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package runas
+
+import "os/exec"
+
+// Exec is not supported on this platform
+func Exec(t Target, path string, argv, env []string, dir string) error {
+	return ErrUnsupported
+}
+
+// Command is not supported on this platform
+func Command(t Target, path string, argv, env []string, dir string) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package runas
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Exec replaces the process with path run as t, the way doas does: the
+// supplementary groups, group and user are set in that order, since each
+// step needs the privileges the next one drops. Unless running as root,
+// the process must already be t and keeps its credentials.
+func Exec(t Target, path string, argv, env []string, dir string) error {
+	if os.Geteuid() == 0 {
+		if err := syscall.Setgroups(t.Groups); err != nil {
+			return fmt.Errorf("setgroups: %w", err)
+		}
+		if err := syscall.Setgid(t.GID); err != nil {
+			return fmt.Errorf("setgid: %w", err)
+		}
+		if err := syscall.Setuid(t.UID); err != nil {
+			return fmt.Errorf("setuid: %w", err)
+		}
+	}
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(path, argv, env)
+}
+
+// Command returns a command running path as t in a child process, for
+// callers that must outlive it, e.g. to close a PAM session. As with Exec,
+// credentials only change when running as root.
+func Command(t Target, path string, argv, env []string, dir string) (*exec.Cmd, error) {
+	groups := make([]uint32, len(t.Groups))
+	for i, gid := range t.Groups {
+		groups[i] = uint32(gid)
+	}
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   argv,
+		Env:    env,
+		Dir:    dir,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	if os.Geteuid() == 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: uint32(t.UID), Gid: uint32(t.GID), Groups: groups},
+		}
+	}
+	return cmd, nil
+}
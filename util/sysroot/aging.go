@@ -0,0 +1,105 @@
+package sysroot
+
+import (
+	"fmt"
+	"time"
+)
+
+// AgingState classifies a shadow entry the way pam_unix's account
+// management does
+type AgingState string
+
+// Aging states, from healthy to refused
+const (
+	AgingOK             AgingState = "ok"
+	AgingWarn           AgingState = "warn"
+	AgingChangeRequired AgingState = "change-required"
+	AgingInactive       AgingState = "inactive"
+	AgingAccountExpired AgingState = "account-expired"
+)
+
+// neverExpires is the maximum age tools like chage use for "never"
+const neverExpires = 99999
+
+// Aging is the password and account expiry state of a shadow entry
+type Aging struct {
+	State AgingState
+	// DaysLeft counts the days until the password expires, when it does
+	DaysLeft int
+	// LastChange is when the password was changed (zero if unknown or
+	// a change is forced)
+	LastChange time.Time
+	// PasswordExpires and AccountExpires are zero when they never expire
+	PasswordExpires time.Time
+	AccountExpires  time.Time
+}
+
+// Refused reports whether a login must be refused: the account expired, or
+// the password expired and cannot be changed without PAM
+func (a Aging) Refused() bool {
+	switch a.State {
+	case AgingChangeRequired, AgingInactive, AgingAccountExpired:
+		return true
+	}
+	return false
+}
+
+// Message explains the state to the user
+func (a Aging) Message() string {
+	switch a.State {
+	case AgingWarn:
+		if a.DaysLeft == 0 {
+			return "password expires today"
+		}
+		return fmt.Sprintf("password expires in %d days", a.DaysLeft)
+	case AgingChangeRequired:
+		return "password expired: it must be changed (use passwd)"
+	case AgingInactive:
+		return "password expired and the inactivity period has passed: the account is locked"
+	case AgingAccountExpired:
+		return "account expired on " + a.AccountExpires.Format("2006-01-02")
+	}
+	return "password and account are valid"
+}
+
+// NeverExpires reports whether the password has no maximum age
+func (s Shadow) NeverExpires() bool {
+	return s.Max < 0 || s.Max >= neverExpires
+}
+
+// Aging evaluates the entry at now, following pam_unix's checks in order
+func (s Shadow) Aging(now time.Time) Aging {
+	today := int(now.Unix() / 86400)
+	aging := Aging{State: AgingOK, DaysLeft: -1}
+	if s.LastChange > 0 {
+		aging.LastChange = date(s.LastChange)
+	}
+	if s.Expire >= 0 {
+		aging.AccountExpires = date(s.Expire)
+	}
+	if s.LastChange > 0 && !s.NeverExpires() {
+		aging.PasswordExpires = date(s.LastChange + s.Max)
+		aging.DaysLeft = s.LastChange + s.Max - today
+	}
+
+	switch {
+	case s.Expire >= 0 && today >= s.Expire:
+		aging.State = AgingAccountExpired
+	case s.LastChange == 0:
+		aging.State = AgingChangeRequired
+	case today < s.LastChange || s.NeverExpires():
+		// Changed in the future (clock skew) or no maximum age
+	case s.Inactive >= 0 && today-s.LastChange > s.Max+s.Inactive:
+		aging.State = AgingInactive
+	case today-s.LastChange > s.Max:
+		aging.State = AgingChangeRequired
+	case s.Warn >= 0 && today-s.LastChange > s.Max-s.Warn:
+		aging.State = AgingWarn
+	}
+	return aging
+}
+
+// date converts a day count from shadow(5) to a UTC time
+func date(days int) time.Time {
+	return time.Unix(int64(days)*86400, 0).UTC()
+}
@@ -0,0 +1,378 @@
+package sysroot
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// System files, relative to the root
+const (
+	PasswdFile    = "/etc/passwd"
+	GroupFile     = "/etc/group"
+	ShadowFile    = "/etc/shadow"
+	LoginDefsFile = "/etc/login.defs"
+	NSSwitchFile  = "/etc/nsswitch.conf"
+	PAMDir        = "/etc/pam.d"
+	UtmpFile      = "/var/run/utmp"
+	WtmpFile      = "/var/log/wtmp"
+	BtmpFile      = "/var/log/btmp"
+	LastlogFile   = "/var/log/lastlog"
+	Lastlog2File  = "/var/lib/lastlog/lastlog2.db"
+)
+
+// Root is the directory system files are resolved under. The host root
+// ("" or "/") looks accounts up through NSS like the C library; any other
+// root reads its passwd, group and shadow files directly, so an image can be
+// inspected without entering it.
+type Root string
+
+// Host reports whether r is the running system's root
+func (r Root) Host() bool {
+	return r == "" || filepath.Clean(string(r)) == "/"
+}
+
+// maxSymlinks bounds the links followed while resolving one path, as
+// MAXSYMLINKS does for the kernel
+const maxSymlinks = 40
+
+// Path resolves the absolute system path name under r. Symbolic links are
+// followed inside r the way openat2's RESOLVE_IN_ROOT does: absolute targets
+// start again at r and ".." stops at it, so links in an image such as
+// /var/run -> /run never lead to the host's files. A name that runs into a
+// symlink loop resolves to "", which cannot be opened.
+func (r Root) Path(name string) string {
+	if r.Host() {
+		return name
+	}
+	root := filepath.Clean(string(r))
+	resolved := "/"
+	rest := name
+	for links := 0; rest != ""; {
+		var part string
+		part, rest, _ = strings.Cut(rest, "/")
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			// Not a link, or missing
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return ""
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = target + "/" + rest
+	}
+	return filepath.Join(root, resolved)
+}
+
+// Paths resolves each of names under r
+func (r Root) Paths(names []string) []string {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = r.Path(name)
+	}
+	return paths
+}
+
+// Passwd is an /etc/passwd entry
+type Passwd struct {
+	Name string
+	// Password is "x" when the hash is kept in the shadow file
+	Password string
+	UID      int
+	GID      int
+	Gecos    string
+	Home     string
+	Shell    string
+}
+
+// Shadow is an /etc/shadow entry. Dates count days since the epoch and
+// empty numeric fields are -1.
+type Shadow struct {
+	Name string
+	// Password is the crypt(3) hash; "!" or "*" prefixes lock the account
+	// and an empty value means no password is needed
+	Password string
+	// LastChange is the day the password was last changed; 0 forces a
+	// change at the next login
+	LastChange int
+	// Min and Max bound the password age in days; Warn is the number of
+	// days before expiry users are warned and Inactive the grace period
+	// after it during which the password can still be changed
+	Min      int
+	Max      int
+	Warn     int
+	Inactive int
+	// Expire is the day the account expires
+	Expire int
+}
+
+// Locked reports whether the hash is disabled with "!" or "*"
+func (s Shadow) Locked() bool {
+	return strings.HasPrefix(s.Password, "!") || strings.HasPrefix(s.Password, "*")
+}
+
+// Passwordless reports whether the account logs in without a password
+func (s Shadow) Passwordless() bool {
+	return s.Password == ""
+}
+
+// LookupUser finds username in the passwd database
+func (r Root) LookupUser(username string) (*user.User, error) {
+	if r.Host() {
+		return user.Lookup(username)
+	}
+	var found *user.User
+	err := r.scan(PasswdFile, 7, func(fields []string) bool {
+		if fields[0] != username {
+			return false
+		}
+		found = passwdUser(fields)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, user.UnknownUserError(username)
+	}
+	return found, nil
+}
+
+// LookupGroupId finds the group with the numeric id gid
+func (r Root) LookupGroupId(gid string) (*user.Group, error) {
+	if r.Host() {
+		return user.LookupGroupId(gid)
+	}
+	var found *user.Group
+	err := r.scan(GroupFile, 4, func(fields []string) bool {
+		if fields[2] != gid {
+			return false
+		}
+		found = &user.Group{Gid: fields[2], Name: fields[0]}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, user.UnknownGroupIdError(gid)
+	}
+	return found, nil
+}
+
+// GroupNames returns the names of u's primary and supplementary groups
+func (r Root) GroupNames(u *user.User) ([]string, error) {
+	var names []string
+	if r.Host() {
+		ids, err := u.GroupIds()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if group, err := user.LookupGroupId(id); err == nil {
+				names = append(names, group.Name)
+			}
+		}
+		return names, nil
+	}
+
+	err := r.scan(GroupFile, 4, func(fields []string) bool {
+		member := fields[2] == u.Gid
+		for _, name := range strings.Split(fields[3], ",") {
+			member = member || name == u.Username
+		}
+		if member {
+			names = append(names, fields[0])
+		}
+		return false
+	})
+	return names, err
+}
+
+// LookupShadow reads username's entry from the shadow file. Shadow entries
+// are always read from the file, also on the host.
+func (r Root) LookupShadow(username string) (*Shadow, error) {
+	var found *Shadow
+	err := r.scan(ShadowFile, 2, func(fields []string) bool {
+		if fields[0] != username {
+			return false
+		}
+		entry := parseShadow(fields)
+		found = &entry
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no shadow entry for %s in %s", username, r.Path(ShadowFile))
+	}
+	return found, nil
+}
+
+// LookupPasswd finds the passwd entry of username, with the fields os/user
+// does not expose such as the login shell. On the host getent is asked
+// first, so accounts from other NSS sources are found too.
+func (r Root) LookupPasswd(username string) (*Passwd, error) {
+	if r.Host() {
+		if out, err := exec.Command("getent", "passwd", username).Output(); err == nil {
+			// getent also matches numeric IDs, which are not names
+			if fields := strings.Split(strings.TrimSpace(string(out)), ":"); len(fields) >= 7 && fields[0] == username {
+				entry := passwdEntry(fields)
+				return &entry, nil
+			}
+		}
+	}
+	var found *Passwd
+	err := r.scan(PasswdFile, 7, func(fields []string) bool {
+		if fields[0] != username {
+			return false
+		}
+		entry := passwdEntry(fields)
+		found = &entry
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, user.UnknownUserError(username)
+	}
+	return found, nil
+}
+
+// PasswdEntries reads every entry of the passwd file. Accounts only known
+// to NSS sources other than files are not listed, also on the host.
+func (r Root) PasswdEntries() ([]Passwd, error) {
+	var entries []Passwd
+	err := r.scan(PasswdFile, 7, func(fields []string) bool {
+		entries = append(entries, passwdEntry(fields))
+		return false
+	})
+	return entries, err
+}
+
+// ShadowEntries reads every entry of the shadow file, by user name
+func (r Root) ShadowEntries() (map[string]Shadow, error) {
+	entries := make(map[string]Shadow)
+	err := r.scan(ShadowFile, 2, func(fields []string) bool {
+		entries[fields[0]] = parseShadow(fields)
+		return false
+	})
+	return entries, err
+}
+
+// LoginDefs reads the KEY VALUE settings of login.defs. A missing file
+// yields no settings.
+func (r Root) LoginDefs() (map[string]string, error) {
+	defs := make(map[string]string)
+	file, err := os.Open(r.Path(LoginDefsFile))
+	if os.IsNotExist(err) {
+		return defs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		defs[fields[0]] = strings.Trim(fields[1], `"`)
+	}
+	return defs, scanner.Err()
+}
+
+// scan calls match with the colon separated fields of each line of the
+// system file name that has at least minFields fields, until match
+// returns true
+func (r Root) scan(name string, minFields int, match func(fields []string) bool) error {
+	file, err := os.Open(r.Path(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < minFields {
+			continue
+		}
+		if match(fields) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// parseShadow converts a shadow line to a Shadow
+func parseShadow(fields []string) Shadow {
+	field := func(i int) int {
+		if i >= len(fields) {
+			return -1
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	return Shadow{
+		Name:       fields[0],
+		Password:   fields[1],
+		LastChange: field(2),
+		Min:        field(3),
+		Max:        field(4),
+		Warn:       field(5),
+		Inactive:   field(6),
+		Expire:     field(7),
+	}
+}
+
+// passwdEntry converts a passwd line to a Passwd
+func passwdEntry(fields []string) Passwd {
+	uid, _ := strconv.Atoi(fields[2])
+	gid, _ := strconv.Atoi(fields[3])
+	return Passwd{
+		Name: fields[0], Password: fields[1], UID: uid, GID: gid,
+		Gecos: fields[4], Home: fields[5], Shell: fields[6],
+	}
+}
+
+// passwdUser converts a passwd line to a user.User
+func passwdUser(fields []string) *user.User {
+	gecos, _, _ := strings.Cut(fields[4], ",")
+	return &user.User{
+		Username: fields[0],
+		Uid:      fields[2],
+		Gid:      fields[3],
+		Name:     gecos,
+		HomeDir:  fields[5],
+	}
+}
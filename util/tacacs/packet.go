@@ -0,0 +1,328 @@
+package tacacs
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Protocol constants from RFC 8907
+const (
+	headerLen = 12
+
+	majorVersion    = 0xc
+	minorVersionDef = 0x0
+	minorVersionOne = 0x1
+
+	// maxBodyLen guards against absurd length fields from broken clients
+	maxBodyLen = 64 * 1024
+)
+
+// Packet types
+const (
+	typeAuthen = 0x01
+	typeAuthor = 0x02
+	typeAcct   = 0x03
+)
+
+// Header flags
+const (
+	flagUnencrypted   = 0x01
+	flagSingleConnect = 0x04
+)
+
+// Authentication actions, types and statuses
+const (
+	authenActionLogin = 0x01
+
+	authenTypeASCII = 0x01
+	authenTypePAP   = 0x02
+
+	authenStatusPass    = 0x01
+	authenStatusFail    = 0x02
+	authenStatusGetUser = 0x04
+	authenStatusGetPass = 0x05
+	authenStatusError   = 0x07
+
+	authenReplyFlagNoEcho   = 0x01
+	authenContinueFlagAbort = 0x01
+)
+
+// Authorization statuses
+const (
+	authorStatusPassAdd = 0x01
+	authorStatusFail    = 0x10
+	authorStatusError   = 0x11
+)
+
+// Accounting flags and statuses
+const (
+	acctFlagStart    = 0x02
+	acctFlagStop     = 0x04
+	acctFlagWatchdog = 0x08
+
+	acctStatusSuccess = 0x01
+	acctStatusError   = 0x02
+)
+
+// header is the fixed 12 byte TACACS+ packet header
+type header struct {
+	version   byte
+	kind      byte
+	seqNo     byte
+	flags     byte
+	sessionID uint32
+	length    uint32
+}
+
+// packet is a header plus its (deobfuscated) body
+type packet struct {
+	header
+	body []byte
+}
+
+// readPacket reads one packet from r and removes the body obfuscation
+func readPacket(r io.Reader, key []byte) (*packet, error) {
+	var raw [headerLen]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return nil, err
+	}
+
+	h := header{
+		version:   raw[0],
+		kind:      raw[1],
+		seqNo:     raw[2],
+		flags:     raw[3],
+		sessionID: binary.BigEndian.Uint32(raw[4:8]),
+		length:    binary.BigEndian.Uint32(raw[8:12]),
+	}
+	if h.version>>4 != majorVersion {
+		return nil, fmt.Errorf("unsupported TACACS+ major version %#x", h.version>>4)
+	}
+	if h.length > maxBodyLen {
+		return nil, fmt.Errorf("TACACS+ body too large: %d bytes", h.length)
+	}
+
+	body := make([]byte, h.length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if h.flags&flagUnencrypted != 0 {
+		// RFC 8907 section 4.5: unobfuscated packets must not be accepted when a key is configured
+		if len(key) > 0 {
+			return nil, errors.New("unobfuscated TACACS+ packet rejected")
+		}
+	} else {
+		obfuscate(&h, key, body)
+	}
+	return &packet{header: h, body: body}, nil
+}
+
+// writePacket obfuscates p's body and writes it to w
+func writePacket(w io.Writer, key []byte, p *packet) error {
+	p.length = uint32(len(p.body))
+	body := append([]byte(nil), p.body...)
+	if p.flags&flagUnencrypted == 0 {
+		obfuscate(&p.header, key, body)
+	}
+
+	buf := make([]byte, headerLen+len(body))
+	buf[0] = p.version
+	buf[1] = p.kind
+	buf[2] = p.seqNo
+	buf[3] = p.flags
+	binary.BigEndian.PutUint32(buf[4:8], p.sessionID)
+	binary.BigEndian.PutUint32(buf[8:12], p.length)
+	copy(buf[headerLen:], body)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// obfuscate XORs body with the MD5 pseudo pad from RFC 8907 section 4.5.
+// The operation is its own inverse.
+func obfuscate(h *header, key, body []byte) {
+	var sessionID [4]byte
+	binary.BigEndian.PutUint32(sessionID[:], h.sessionID)
+
+	var pad []byte
+	for offset := 0; offset < len(body); offset += md5.Size {
+		hash := md5.New()
+		hash.Write(sessionID[:])
+		hash.Write(key)
+		hash.Write([]byte{h.version, h.seqNo})
+		hash.Write(pad)
+		pad = hash.Sum(nil)
+
+		for i := 0; i < md5.Size && offset+i < len(body); i++ {
+			body[offset+i] ^= pad[i]
+		}
+	}
+}
+
+// fieldReader walks the variable length fields of a packet body
+type fieldReader struct {
+	buf []byte
+	err error
+}
+
+// bytes returns the next n bytes
+func (f *fieldReader) bytes(n int) []byte {
+	if f.err != nil {
+		return nil
+	}
+	if n > len(f.buf) {
+		f.err = errors.New("truncated TACACS+ packet body")
+		return nil
+	}
+	value := f.buf[:n]
+	f.buf = f.buf[n:]
+	return value
+}
+
+// byte returns the next byte
+func (f *fieldReader) byte() byte {
+	b := f.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// uint16 returns the next big endian 16 bit value
+func (f *fieldReader) uint16() int {
+	b := f.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+// fieldWriter builds a packet body
+type fieldWriter struct {
+	buf []byte
+}
+
+func (f *fieldWriter) byte(b byte) {
+	f.buf = append(f.buf, b)
+}
+
+func (f *fieldWriter) uint16(n int) {
+	f.buf = binary.BigEndian.AppendUint16(f.buf, uint16(n))
+}
+
+func (f *fieldWriter) bytes(b []byte) {
+	f.buf = append(f.buf, b...)
+}
+
+// authenStart is the body of an authentication START packet
+type authenStart struct {
+	action     byte
+	privLvl    byte
+	authenType byte
+	service    byte
+	user       string
+	port       string
+	remAddr    string
+	data       []byte
+}
+
+func parseAuthenStart(body []byte) (authenStart, error) {
+	f := &fieldReader{buf: body}
+	s := authenStart{action: f.byte(), privLvl: f.byte(), authenType: f.byte(), service: f.byte()}
+	userLen, portLen, remLen, dataLen := int(f.byte()), int(f.byte()), int(f.byte()), int(f.byte())
+	s.user = string(f.bytes(userLen))
+	s.port = string(f.bytes(portLen))
+	s.remAddr = string(f.bytes(remLen))
+	s.data = f.bytes(dataLen)
+	return s, f.err
+}
+
+// authenContinue is the body of an authentication CONTINUE packet
+type authenContinue struct {
+	userMsg string
+	data    []byte
+	flags   byte
+}
+
+func parseAuthenContinue(body []byte) (authenContinue, error) {
+	f := &fieldReader{buf: body}
+	msgLen, dataLen := f.uint16(), f.uint16()
+	c := authenContinue{flags: f.byte()}
+	c.userMsg = string(f.bytes(msgLen))
+	c.data = f.bytes(dataLen)
+	return c, f.err
+}
+
+// authenReply encodes an authentication REPLY body
+func authenReply(status, flags byte, serverMsg string) []byte {
+	f := &fieldWriter{}
+	f.byte(status)
+	f.byte(flags)
+	f.uint16(len(serverMsg))
+	f.uint16(0)
+	f.bytes([]byte(serverMsg))
+	return f.buf
+}
+
+// authorRequest is the body of an authorization or accounting REQUEST. For
+// accounting, flags carries the START/STOP/WATCHDOG bits.
+type authorRequest struct {
+	flags        byte
+	authenMethod byte
+	privLvl      byte
+	authenType   byte
+	service      byte
+	user         string
+	port         string
+	remAddr      string
+	args         []string
+}
+
+func parseAuthorRequest(body []byte, accounting bool) (authorRequest, error) {
+	f := &fieldReader{buf: body}
+	var r authorRequest
+	if accounting {
+		r.flags = f.byte()
+	}
+	r.authenMethod, r.privLvl, r.authenType, r.service = f.byte(), f.byte(), f.byte(), f.byte()
+	userLen, portLen, remLen, argCnt := int(f.byte()), int(f.byte()), int(f.byte()), int(f.byte())
+	argLens := f.bytes(argCnt)
+	r.user = string(f.bytes(userLen))
+	r.port = string(f.bytes(portLen))
+	r.remAddr = string(f.bytes(remLen))
+	for _, n := range argLens {
+		r.args = append(r.args, string(f.bytes(int(n))))
+	}
+	return r, f.err
+}
+
+// authorResponse encodes an authorization RESPONSE body
+func authorResponse(status byte, args []string, serverMsg string) []byte {
+	f := &fieldWriter{}
+	f.byte(status)
+	f.byte(byte(len(args)))
+	f.uint16(len(serverMsg))
+	f.uint16(0)
+	for _, arg := range args {
+		f.byte(byte(len(arg)))
+	}
+	f.bytes([]byte(serverMsg))
+	for _, arg := range args {
+		f.bytes([]byte(arg))
+	}
+	return f.buf
+}
+
+// acctReply encodes an accounting REPLY body
+func acctReply(status byte, serverMsg string) []byte {
+	f := &fieldWriter{}
+	f.uint16(len(serverMsg))
+	f.uint16(0)
+	f.byte(status)
+	f.bytes([]byte(serverMsg))
+	return f.buf
+}
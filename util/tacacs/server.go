@@ -0,0 +1,452 @@
+package tacacs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/audit"
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultIdleTimeout closes connections that stay silent for this long
+const DefaultIdleTimeout = 30 * time.Second
+
+// groupCacheTTL bounds how long groups learned at login are used for authorization
+const groupCacheTTL = 12 * time.Hour
+
+// KeySource returns the shared key for a client address, or nil for unknown clients
+type KeySource interface {
+	Lookup(ip net.IP) []byte
+}
+
+// Server is a TACACS+ (RFC 8907) server backed by a pam-auth Authenticator
+type Server struct {
+	// Addr is the TCP address to listen on. Defaults to :49.
+	Addr string
+	// Keys maps device addresses to their shared keys
+	Keys KeySource
+	// Authenticator verifies ASCII and PAP logins
+	Authenticator auth.Authenticator
+	// PrivilegeLevels maps group names to privilege levels (0-15). The
+	// highest level among a user's groups is granted.
+	PrivilegeLevels map[string]int
+	// DefaultPrivilege is granted to users without a mapped group who
+	// logged in through this server or have groups in the backend; a
+	// negative value denies authorization instead
+	DefaultPrivilege int
+	// Audit receives authentication, authorization and accounting records
+	Audit *audit.Logger
+	// IdleTimeout closes connections without traffic. Defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// AuthTimeout bounds each backend call (zero means no limit)
+	AuthTimeout time.Duration
+	// Observe, when set, is called with the result and duration of every
+	// login
+	Observe func(result auth.Result, elapsed time.Duration)
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+	groups   map[string]cachedGroups
+}
+
+// cachedGroups remembers the groups returned by a successful login
+type cachedGroups struct {
+	groups  []string
+	expires time.Time
+}
+
+// loginSession tracks an in-progress ASCII login
+type loginSession struct {
+	start authenStart
+}
+
+// connState is the per-connection protocol state
+type connState struct {
+	conn          net.Conn
+	key           []byte
+	remote        string
+	singleConnect bool
+	logins        map[uint32]*loginSession
+}
+
+// ListenAndServe listens on Addr and serves connections until Close is called
+func (s *Server) ListenAndServe() error {
+	if s.Authenticator == nil {
+		return errors.New("tacacs: no authenticator configured")
+	}
+	if s.Keys == nil {
+		return errors.New("tacacs: no client keys configured")
+	}
+
+	addr := s.Addr
+	if addr == "" {
+		addr = ":49"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	fmt.Printf("🖧 TACACS+ server listening on %s\n", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting new connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn serves every session on a single TCP connection
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	key := s.Keys.Lookup(net.ParseIP(host))
+	if key == nil {
+		fmt.Printf("⚠️ TACACS+ connection from unknown client %s dropped\n", host)
+		return
+	}
+
+	timeout := s.IdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+
+	state := &connState{conn: conn, key: key, remote: host, logins: make(map[uint32]*loginSession)}
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		request, err := readPacket(conn, key)
+		if err != nil {
+			if !isClosed(err) {
+				fmt.Printf("⚠️ TACACS+ connection from %s closed: %v\n", host, err)
+			}
+			return
+		}
+		if request.seqNo == 1 && request.flags&flagSingleConnect != 0 {
+			state.singleConnect = true
+		}
+
+		body, done := s.dispatch(state, request)
+		if body != nil {
+			reply := &packet{header: request.header, body: body}
+			reply.seqNo = request.seqNo + 1
+			reply.flags = request.flags & flagUnencrypted
+			if state.singleConnect {
+				reply.flags |= flagSingleConnect
+			}
+			if err := writePacket(conn, key, reply); err != nil {
+				fmt.Printf("⚠️ Cannot send TACACS+ reply to %s: %v\n", host, err)
+				return
+			}
+		}
+
+		// Without single-connect the connection carries a single session
+		if done && !state.singleConnect {
+			return
+		}
+	}
+}
+
+// dispatch handles one packet and returns the reply body and whether the session ended
+func (s *Server) dispatch(state *connState, request *packet) ([]byte, bool) {
+	switch request.kind {
+	case typeAuthen:
+		return s.handleAuthen(state, request)
+	case typeAuthor:
+		return s.handleAuthor(state, request), true
+	case typeAcct:
+		return s.handleAcct(state, request), true
+	}
+	fmt.Printf("⚠️ Unknown TACACS+ packet type %#x from %s\n", request.kind, state.remote)
+	return nil, true
+}
+
+// handleAuthen implements ASCII and PAP login
+func (s *Server) handleAuthen(state *connState, request *packet) ([]byte, bool) {
+	if request.seqNo == 1 {
+		start, err := parseAuthenStart(request.body)
+		if err != nil {
+			return authenReply(authenStatusError, 0, err.Error()), true
+		}
+		if start.action != authenActionLogin {
+			return authenReply(authenStatusFail, 0, "Only login is supported"), true
+		}
+
+		switch start.authenType {
+		case authenTypePAP:
+			if request.version&0x0f != minorVersionOne {
+				return authenReply(authenStatusError, 0, "PAP requires minor version 1"), true
+			}
+			fmt.Printf("🖧 TACACS+ PAP login from %s for user: %s\n", state.remote, start.user)
+			return s.finishLogin(state, start, string(start.data)), true
+		case authenTypeASCII:
+			if request.version&0x0f != minorVersionDef {
+				return authenReply(authenStatusError, 0, "ASCII login requires minor version 0"), true
+			}
+			state.logins[request.sessionID] = &loginSession{start: start}
+			if start.user == "" {
+				return authenReply(authenStatusGetUser, 0, "Username: "), false
+			}
+			return authenReply(authenStatusGetPass, authenReplyFlagNoEcho, "Password: "), false
+		}
+		return authenReply(authenStatusFail, 0, "Unsupported authentication type"), true
+	}
+
+	session, ok := state.logins[request.sessionID]
+	if !ok {
+		return authenReply(authenStatusError, 0, "Unknown session"), true
+	}
+	cont, err := parseAuthenContinue(request.body)
+	if err != nil {
+		delete(state.logins, request.sessionID)
+		return authenReply(authenStatusError, 0, err.Error()), true
+	}
+	if cont.flags&authenContinueFlagAbort != 0 {
+		delete(state.logins, request.sessionID)
+		return nil, true
+	}
+
+	if session.start.user == "" {
+		session.start.user = strings.TrimSpace(cont.userMsg)
+		if session.start.user == "" {
+			return authenReply(authenStatusGetUser, 0, "Username: "), false
+		}
+		return authenReply(authenStatusGetPass, authenReplyFlagNoEcho, "Password: "), false
+	}
+
+	delete(state.logins, request.sessionID)
+	fmt.Printf("🖧 TACACS+ ASCII login from %s for user: %s\n", state.remote, session.start.user)
+	return s.finishLogin(state, session.start, cont.userMsg), true
+}
+
+// finishLogin verifies the password and records the outcome
+func (s *Server) finishLogin(state *connState, start authenStart, password string) []byte {
+	ctx, span := tracing.Tracer().Start(context.Background(), "tacacs login",
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(semconv.EnduserID(start.user)))
+	defer span.End()
+	authCtx, cancel := auth.WithTimeout(ctx, s.AuthTimeout)
+	started := time.Now()
+	result := tracing.Authenticate(authCtx, "backend", s.Authenticator, start.user, password)
+	cancel()
+	if s.Observe != nil {
+		s.Observe(result, time.Since(started))
+	}
+	_, auditSpan := tracing.Tracer().Start(ctx, "audit")
+	defer auditSpan.End()
+
+	record := audit.Record{
+		Event:    audit.EventAuthentication,
+		Service:  "tacacs",
+		Username: start.user,
+		Remote:   firstNonEmpty(start.remAddr, state.remote),
+		Port:     start.port,
+		Outcome:  "failure",
+		Message:  result.Message,
+	}
+
+	if !result.Success {
+		s.Audit.Log(record)
+		fmt.Printf("❌ TACACS+ login failed for user: %s\n", start.user)
+		return authenReply(authenStatusFail, 0, "Authentication failed")
+	}
+
+	s.rememberGroups(start.user, result.Groups)
+	record.Outcome = "success"
+	s.Audit.Log(record)
+	fmt.Printf("✅ TACACS+ login successful for user: %s\n", start.user)
+	return authenReply(authenStatusPass, 0, "")
+}
+
+// handleAuthor grants a privilege level derived from group membership
+func (s *Server) handleAuthor(state *connState, request *packet) []byte {
+	req, err := parseAuthorRequest(request.body, false)
+	if err != nil {
+		return authorResponse(authorStatusError, nil, err.Error())
+	}
+
+	level := s.privilegeFor(req.user)
+	record := audit.Record{
+		Event:      audit.EventAuthorization,
+		Service:    "tacacs",
+		Username:   req.user,
+		Remote:     firstNonEmpty(req.remAddr, state.remote),
+		Port:       req.port,
+		Outcome:    "failure",
+		Attributes: parseArgs(req.args),
+	}
+
+	if level < 0 || int(req.privLvl) > level {
+		record.Message = fmt.Sprintf("privilege level %d not granted (user level %d)", req.privLvl, level)
+		s.Audit.Log(record)
+		fmt.Printf("❌ TACACS+ authorization denied for user %s: %s\n", req.user, record.Message)
+		return authorResponse(authorStatusFail, nil, "Not authorized")
+	}
+
+	record.Outcome = "success"
+	record.Message = fmt.Sprintf("priv-lvl=%d", level)
+	s.Audit.Log(record)
+	fmt.Printf("✅ TACACS+ authorization granted for user %s (priv-lvl %d)\n", req.user, level)
+
+	if record.Attributes["service"] == "shell" && record.Attributes["cmd"] == "" {
+		return authorResponse(authorStatusPassAdd, []string{"priv-lvl=" + strconv.Itoa(level)}, "")
+	}
+	return authorResponse(authorStatusPassAdd, nil, "")
+}
+
+// handleAcct writes accounting records to the audit log
+func (s *Server) handleAcct(state *connState, request *packet) []byte {
+	req, err := parseAuthorRequest(request.body, true)
+	if err != nil {
+		return acctReply(acctStatusError, err.Error())
+	}
+
+	outcome := "update"
+	switch {
+	case req.flags&acctFlagStart != 0:
+		outcome = "start"
+	case req.flags&acctFlagStop != 0:
+		outcome = "stop"
+	case req.flags&acctFlagWatchdog != 0:
+		outcome = "watchdog"
+	}
+
+	err = s.Audit.Log(audit.Record{
+		Event:      audit.EventAccounting,
+		Service:    "tacacs",
+		Username:   req.user,
+		Remote:     firstNonEmpty(req.remAddr, state.remote),
+		Port:       req.port,
+		Outcome:    outcome,
+		Attributes: parseArgs(req.args),
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Cannot write accounting record: %v\n", err)
+		return acctReply(acctStatusError, "Accounting record not stored")
+	}
+	fmt.Printf("🧾 TACACS+ accounting %s for user: %s\n", outcome, req.user)
+	return acctReply(acctStatusSuccess, "")
+}
+
+// rememberGroups caches the groups returned at login for later authorization
+func (s *Server) rememberGroups(username string, groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.groups == nil {
+		s.groups = make(map[string]cachedGroups)
+	}
+	s.groups[username] = cachedGroups{groups: groups, expires: time.Now().Add(groupCacheTTL)}
+}
+
+// groupsFor returns username's groups from the backend or the login cache;
+// known is false for users the server cannot vouch for
+func (s *Server) groupsFor(username string) (groups []string, known bool) {
+	if source, ok := s.Authenticator.(auth.GroupSource); ok {
+		if groups := source.Groups(username); len(groups) > 0 {
+			return groups, true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.groups[username]
+	if !ok || time.Now().After(cached.expires) {
+		return nil, false
+	}
+	return cached.groups, true
+}
+
+// privilegeFor returns the highest privilege level granted by username's
+// groups, or -1 for users who are neither known nor logged in
+func (s *Server) privilegeFor(username string) int {
+	groups, known := s.groupsFor(username)
+	if !known {
+		return -1
+	}
+	level := -1
+	for _, group := range groups {
+		if groupLevel, ok := s.PrivilegeLevels[group]; ok && groupLevel > level {
+			level = groupLevel
+		}
+	}
+	if level < 0 {
+		return s.DefaultPrivilege
+	}
+	return level
+}
+
+// parseArgs turns "attr=value" and "attr*value" arguments into a map
+func parseArgs(args []string) map[string]string {
+	if len(args) == 0 {
+		return nil
+	}
+	attributes := make(map[string]string, len(args))
+	for _, arg := range args {
+		separator := strings.IndexAny(arg, "=*")
+		if separator < 0 {
+			attributes[arg] = ""
+			continue
+		}
+		name, value := arg[:separator], arg[separator+1:]
+		if existing, ok := attributes[name]; ok && name == "cmd-arg" {
+			value = existing + " " + value
+		}
+		attributes[name] = value
+	}
+	return attributes
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// isClosed reports whether err is a normal end of the connection
+func isClosed(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
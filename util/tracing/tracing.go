@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the pam-auth tracer
+const instrumentationName = "github.com/bariiss/pam-auth"
+
+// Span attributes. Passwords, shared secrets and tokens are never recorded.
+var (
+	AttrBackend = attribute.Key("pam_auth.backend")
+	AttrOutcome = attribute.Key("pam_auth.outcome")
+	AttrOffline = attribute.Key("pam_auth.offline")
+	AttrMethod  = attribute.Key("pam_auth.method")
+)
+
+// Tracer returns the pam-auth tracer. Until a tracer provider is installed
+// every span is a no-op, so libraries can trace unconditionally.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// FromEnvironment returns ctx with the parent span passed by the calling
+// process in the TRACEPARENT and TRACESTATE environment variables
+func FromEnvironment(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// Middleware continues the trace of incoming HTTP requests carrying W3C
+// traceparent headers and wraps each request in a server span
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer().Start(ctx, r.Method+" "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate calls authenticator inside a span named after the stage and
+// records the outcome of the result
+func Authenticate(ctx context.Context, stage string, authenticator auth.Authenticator, username, password string) auth.Result {
+	ctx, span := Tracer().Start(ctx, stage, trace.WithAttributes(semconv.EnduserID(username)))
+	defer span.End()
+	result := authenticator.Authenticate(ctx, username, password)
+	RecordResult(span, result)
+	return result
+}
+
+// RecordResult sets the backend and outcome of result on span and marks
+// errors and timeouts as failed spans
+func RecordResult(span trace.Span, result auth.Result) {
+	span.SetAttributes(AttrBackend.String(result.Backend), AttrOutcome.String(result.Outcome()))
+	if result.Offline {
+		span.SetAttributes(AttrOffline.Bool(true))
+	}
+	if outcome := result.Outcome(); outcome == "error" || outcome == "timeout" {
+		span.SetStatus(codes.Error, result.Message)
+	}
+}
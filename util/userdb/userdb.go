@@ -0,0 +1,673 @@
+package userdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/auth"
+	"github.com/bariiss/pam-auth/util/crypt"
+	"github.com/bariiss/pam-auth/util/nsscache"
+	"github.com/bariiss/pam-auth/util/tracing"
+	_ "modernc.org/sqlite"
+)
+
+// FirstUID is the first numeric ID handed out to new users, chosen to stay
+// clear of the ranges useradd allocates from
+const FirstUID = 100000
+
+// FirstGID is the first numeric ID handed out to named groups
+const FirstGID = 200000
+
+// MinID is the lowest UID or GID a user may be given explicitly: lower IDs
+// belong to root and the system accounts
+const MinID = 1000
+
+// ErrNoSuchUser is returned when a user is not in the database
+var ErrNoSuchUser = fmt.Errorf("%w in database", auth.ErrUnknownUser)
+
+// HistoryLimit is how many replaced password hashes are kept per user
+const HistoryLimit = 24
+
+// ErrUserExists is returned when adding a user that already exists
+var ErrUserExists = errors.New("user already exists")
+
+// schema creates the tables of a new database; it is safe to run repeatedly
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+	name          TEXT PRIMARY KEY,
+	uid           INTEGER NOT NULL UNIQUE,
+	gid           INTEGER NOT NULL,
+	gecos         TEXT NOT NULL DEFAULT '',
+	home          TEXT NOT NULL DEFAULT '',
+	shell         TEXT NOT NULL DEFAULT '',
+	password_hash TEXT NOT NULL,
+	disabled      INTEGER NOT NULL DEFAULT 0,
+	expires_at    INTEGER NOT NULL DEFAULT 0,
+	changed_at    INTEGER NOT NULL,
+	created_at    INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS memberships (
+	user  TEXT NOT NULL REFERENCES users(name) ON DELETE CASCADE,
+	grp   TEXT NOT NULL,
+	PRIMARY KEY (user, grp)
+);
+CREATE TABLE IF NOT EXISTS groups (
+	name  TEXT PRIMARY KEY,
+	gid   INTEGER NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS attributes (
+	user  TEXT NOT NULL REFERENCES users(name) ON DELETE CASCADE,
+	key   TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (user, key)
+);
+CREATE TABLE IF NOT EXISTS password_history (
+	user       TEXT NOT NULL REFERENCES users(name) ON DELETE CASCADE,
+	hash       TEXT NOT NULL,
+	changed_at INTEGER NOT NULL
+);
+`
+
+// User is an account stored in the database
+type User struct {
+	Name  string
+	UID   int
+	GID   int
+	Gecos string
+	Home  string
+	Shell string
+	// Disabled accounts are refused regardless of password
+	Disabled bool
+	// Expires is the account expiry time (zero means never)
+	Expires time.Time
+	// PasswordChanged is when the password was last set
+	PasswordChanged time.Time
+	Created         time.Time
+	Groups          []string
+	Attributes      map[string]string
+}
+
+// Expired reports whether the account has expired at now
+func (u User) Expired(now time.Time) bool {
+	return !u.Expires.IsZero() && !now.Before(u.Expires)
+}
+
+// Store is a SQLite user database. It is safe for concurrent use.
+type Store struct {
+	// Path is the location of the database file
+	Path string
+	// Params tunes the argon2id hashes of new passwords
+	Params crypt.Argon2Params
+
+	db *sql.DB
+	// dummyHash is verified for unknown users so they take as long to
+	// reject as a wrong password does
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise %s: %w", path, err)
+	}
+	if err := backfillGroups(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise %s: %w", path, err)
+	}
+	return &Store{Path: path, Params: crypt.DefaultArgon2Params, db: db}, nil
+}
+
+// backfillGroups allocates IDs for groups referenced by memberships that
+// predate the groups table
+func backfillGroups(db *sql.DB) error {
+	rows, err := db.Query(`SELECT DISTINCT grp FROM memberships WHERE grp NOT IN (SELECT name FROM groups)`)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, name := range missing {
+		if err := ensureGroup(db, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping implements auth.Pinger by checking that the database file is still
+// there and can be queried
+func (s *Store) Ping(ctx context.Context) error {
+	if _, err := os.Stat(s.Path); err != nil {
+		return err
+	}
+	var users int
+	return s.db.QueryRowContext(ctx, `SELECT count(*) FROM users`).Scan(&users)
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// AddUser creates u with the given password. A zero UID picks the next free
+// ID from FirstUID and a zero GID defaults to the UID.
+func (s *Store) AddUser(u User, password string) error {
+	if err := validName(u.Name); err != nil {
+		return err
+	}
+	hash, err := crypt.Argon2id(password, s.Params)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE name = ?`, u.Name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrUserExists
+	}
+	// A database user named after a host account would be taken for it
+	// by anything that resolves the name
+	if _, err := user.Lookup(u.Name); err == nil {
+		return fmt.Errorf("user %s already exists on the host", u.Name)
+	}
+	if (u.UID != 0 && u.UID < MinID) || (u.GID != 0 && u.GID < MinID) {
+		return fmt.Errorf("IDs below %d are reserved for system accounts", MinID)
+	}
+	if u.UID == 0 {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(uid) + 1, ?) FROM users WHERE uid >= ?`, FirstUID, FirstUID).Scan(&u.UID); err != nil {
+			return err
+		}
+	}
+	if u.GID == 0 {
+		u.GID = u.UID
+	}
+	if u.Home == "" {
+		u.Home = "/home/" + u.Name
+	}
+	if u.Shell == "" {
+		u.Shell = "/bin/sh"
+	}
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`INSERT INTO users (name, uid, gid, gecos, home, shell, password_hash, disabled, expires_at, changed_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.Name, u.UID, u.GID, u.Gecos, u.Home, u.Shell, hash, u.Disabled, unixOrZero(u.Expires), now, now)
+	if err != nil {
+		return err
+	}
+	if err := setGroups(tx, u.Name, u.Groups); err != nil {
+		return err
+	}
+	for key, value := range u.Attributes {
+		if err := setAttribute(tx, u.Name, key, value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetPassword replaces username's password hash, keeping the old hash in
+// the password history
+func (s *Store) SetPassword(username, password string) error {
+	hash, err := crypt.Argon2id(password, s.Params)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old string
+	var changed int64
+	err = tx.QueryRow(`SELECT password_hash, changed_at FROM users WHERE name = ?`, username).Scan(&old, &changed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoSuchUser
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO password_history (user, hash, changed_at) VALUES (?, ?, ?)`, username, old, changed); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM password_history WHERE user = ? AND rowid NOT IN
+		(SELECT rowid FROM password_history WHERE user = ? ORDER BY changed_at DESC, rowid DESC LIMIT ?)`, username, username, HistoryLimit)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password_hash = ?, changed_at = ? WHERE name = ?`, hash, time.Now().Unix(), username); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PasswordHistory returns the hashes of username's current and up to
+// HistoryLimit earlier passwords, newest first
+func (s *Store) PasswordHistory(username string) ([]string, error) {
+	_, current, err := s.lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	earlier, err := s.queryStrings(`SELECT hash FROM password_history WHERE user = ? ORDER BY changed_at DESC, rowid DESC`, username)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{current}, earlier...), nil
+}
+
+// SetDisabled disables or re-enables username
+func (s *Store) SetDisabled(username string, disabled bool) error {
+	return s.update(`UPDATE users SET disabled = ? WHERE name = ?`, disabled, username)
+}
+
+// SetExpiry sets when username's account expires (zero means never)
+func (s *Store) SetExpiry(username string, expires time.Time) error {
+	return s.update(`UPDATE users SET expires_at = ? WHERE name = ?`, unixOrZero(expires), username)
+}
+
+// SetGroups replaces username's group memberships
+func (s *Store) SetGroups(username string, groups []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := requireUser(tx, username); err != nil {
+		return err
+	}
+	if err := setGroups(tx, username, groups); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAttribute sets an attribute on username; an empty value removes it
+func (s *Store) SetAttribute(username, key, value string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := requireUser(tx, username); err != nil {
+		return err
+	}
+	if err := setAttribute(tx, username, key, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes username together with its groups and attributes
+func (s *Store) DeleteUser(username string) error {
+	return s.update(`DELETE FROM users WHERE name = ?`, username)
+}
+
+// Group is a named group with its members
+type Group struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// GroupList returns every named group ordered by ID
+func (s *Store) GroupList() ([]Group, error) {
+	rows, err := s.db.Query(`SELECT name, gid FROM groups ORDER BY gid`)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.Name, &g.GID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		if groups[i].Members, err = s.queryStrings(`SELECT user FROM memberships WHERE grp = ? ORDER BY user`, groups[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// User returns username's account
+func (s *Store) User(username string) (User, error) {
+	u, _, err := s.lookup(username)
+	return u, err
+}
+
+// Users returns every account ordered by name
+func (s *Store) Users() ([]User, error) {
+	names, err := s.queryStrings(`SELECT name FROM users ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(names))
+	for _, name := range names {
+		u, err := s.User(name)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// Authenticate implements auth.Authenticator. Disabled and expired accounts
+// are refused even when the password is correct.
+func (s *Store) Authenticate(ctx context.Context, username, password string) auth.Result {
+	if ctx.Err() != nil {
+		return auth.Aborted(ctx, "db", username)
+	}
+	u, hash, err := s.lookup(username)
+	if errors.Is(err, ErrNoSuchUser) {
+		s.burnDummyHash(password)
+		return auth.Failure("db", username, "password mismatch")
+	}
+	if err != nil {
+		return auth.Failure("db", username, err.Error())
+	}
+
+	match, err := crypt.Verify(hash, password)
+	if err != nil {
+		return auth.Failure("db", username, err.Error())
+	}
+	if !match {
+		return auth.Failure("db", username, "password mismatch")
+	}
+	_, span := tracing.Tracer().Start(ctx, "policy")
+	err = auth.CheckAccount(u.info(), time.Now())
+	span.End()
+	if err != nil {
+		result := auth.Failure("db", username, err.Error())
+		result.Locked = true
+		return result
+	}
+	return auth.Result{Username: username, Backend: "db", Success: true, Groups: u.Groups}
+}
+
+// Groups implements auth.GroupSource
+func (s *Store) Groups(username string) []string {
+	u, err := s.User(username)
+	if err != nil {
+		return nil
+	}
+	return u.Groups
+}
+
+// UserInfo implements auth.InfoSource
+func (s *Store) UserInfo(username string) (auth.UserInfo, error) {
+	u, err := s.User(username)
+	if err != nil {
+		return auth.UserInfo{}, err
+	}
+	return u.info(), nil
+}
+
+// info converts u to the backend independent account description
+func (u User) info() auth.UserInfo {
+	return auth.UserInfo{
+		Username:   u.Name,
+		UID:        strconv.Itoa(u.UID),
+		GID:        strconv.Itoa(u.GID),
+		HomeDir:    u.Home,
+		Name:       u.Gecos,
+		Shell:      u.Shell,
+		Groups:     u.Groups,
+		Attributes: u.Attributes,
+		Expires:    u.Expires,
+		Disabled:   u.Disabled,
+	}
+}
+
+// lookup loads username's account and password hash
+func (s *Store) lookup(username string) (User, string, error) {
+	var (
+		u                             User
+		hash                          string
+		expires, changed, createdUnix int64
+	)
+	err := s.db.QueryRow(`SELECT name, uid, gid, gecos, home, shell, password_hash, disabled, expires_at, changed_at, created_at
+		FROM users WHERE name = ?`, username).Scan(
+		&u.Name, &u.UID, &u.GID, &u.Gecos, &u.Home, &u.Shell, &hash, &u.Disabled, &expires, &changed, &createdUnix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, "", ErrNoSuchUser
+	}
+	if err != nil {
+		return User{}, "", err
+	}
+	if expires != 0 {
+		u.Expires = time.Unix(expires, 0)
+	}
+	u.PasswordChanged = time.Unix(changed, 0)
+	u.Created = time.Unix(createdUnix, 0)
+
+	if u.Groups, err = s.queryStrings(`SELECT grp FROM memberships WHERE user = ? ORDER BY grp`, username); err != nil {
+		return User{}, "", err
+	}
+	rows, err := s.db.Query(`SELECT key, value FROM attributes WHERE user = ?`, username)
+	if err != nil {
+		return User{}, "", err
+	}
+	defer rows.Close()
+	u.Attributes = make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return User{}, "", err
+		}
+		u.Attributes[key] = value
+	}
+	return u, hash, rows.Err()
+}
+
+// queryStrings runs a single column query
+func (s *Store) queryStrings(query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// update runs a statement that must touch exactly one user row
+func (s *Store) update(query string, args ...any) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoSuchUser
+	}
+	return err
+}
+
+// burnDummyHash spends the same time as a real verification
+func (s *Store) burnDummyHash(password string) {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = crypt.Argon2id("", s.Params)
+	})
+	crypt.Verify(s.dummyHash, password)
+}
+
+// requireUser returns ErrNoSuchUser unless username exists
+func requireUser(tx *sql.Tx, username string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE name = ?`, username).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoSuchUser
+	}
+	return nil
+}
+
+// setGroups replaces username's memberships inside tx
+func setGroups(tx *sql.Tx, username string, groups []string) error {
+	if _, err := tx.Exec(`DELETE FROM memberships WHERE user = ?`, username); err != nil {
+		return err
+	}
+	sorted := append([]string(nil), groups...)
+	sort.Strings(sorted)
+	for _, group := range sorted {
+		if err := validName(group); err != nil {
+			return fmt.Errorf("invalid group: %w", err)
+		}
+		var known int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM groups WHERE name = ?`, group).Scan(&known); err != nil {
+			return err
+		}
+		if _, err := user.LookupGroup(group); err == nil && known == 0 {
+			return fmt.Errorf("group %s already exists on the host", group)
+		}
+		if err := ensureGroup(tx, group); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO memberships (user, grp) VALUES (?, ?)`, username, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execer is the subset of *sql.DB and *sql.Tx used by ensureGroup
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// ensureGroup gives group the next free ID from FirstGID unless it has one
+func ensureGroup(db execer, group string) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO groups (name, gid)
+		VALUES (?, (SELECT COALESCE(MAX(gid) + 1, ?) FROM groups WHERE gid >= ?))`, group, FirstGID, FirstGID)
+	return err
+}
+
+// setAttribute stores or, for an empty value, removes one attribute inside tx
+func setAttribute(tx *sql.Tx, username, key, value string) error {
+	if key == "" {
+		return errors.New("attribute key must not be empty")
+	}
+	if value == "" {
+		_, err := tx.Exec(`DELETE FROM attributes WHERE user = ? AND key = ?`, username, key)
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO attributes (user, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (user, key) DO UPDATE SET value = excluded.value`, username, key, value)
+	return err
+}
+
+// validName rejects names that could not be used as a login or group name
+func validName(name string) error {
+	if name == "" || strings.ContainsAny(name, ": \t\n/") {
+		return fmt.Errorf("invalid name: %q", name)
+	}
+	return nil
+}
+
+// unixOrZero converts t to unix seconds, keeping the zero time as 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// NSSCache exports the database for libnss_pamauth. Users whose primary
+// group is not a named group get a private group of the same name.
+func (s *Store) NSSCache() (*nsscache.Cache, error) {
+	users, err := s.Users()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.GroupList()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &nsscache.Cache{}
+	names := make(map[string]bool)
+	gids := make(map[int]bool)
+	for _, g := range groups {
+		cache.Groups = append(cache.Groups, nsscache.Group{Name: g.Name, GID: uint32(g.GID), Members: g.Members})
+		names[g.Name] = true
+		gids[g.GID] = true
+	}
+
+	for _, u := range users {
+		expire := int64(-1)
+		if !u.Expires.IsZero() {
+			expire = nsscache.Day(u.Expires)
+		}
+		cache.Users = append(cache.Users, nsscache.User{
+			Name:       u.Name,
+			UID:        uint32(u.UID),
+			GID:        uint32(u.GID),
+			Gecos:      u.Gecos,
+			Home:       u.Home,
+			Shell:      u.Shell,
+			Locked:     u.Disabled,
+			LastChange: nsscache.Day(u.PasswordChanged),
+			Expire:     expire,
+		})
+		if !gids[u.GID] && !names[u.Name] {
+			cache.Groups = append(cache.Groups, nsscache.Group{Name: u.Name, GID: uint32(u.GID)})
+			names[u.Name] = true
+			gids[u.GID] = true
+		}
+	}
+	return cache, nil
+}
@@ -0,0 +1,148 @@
+package utmp
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/sysroot"
+)
+
+// How a session ended, as last(1) reports it
+const (
+	EndLogout   = "logout"
+	EndCrash    = "crash"
+	EndDown     = "down"
+	EndLoggedIn = "still logged in"
+)
+
+// Session is a login paired with its logout
+type Session struct {
+	User  string    `json:"user"`
+	Line  string    `json:"line"`
+	Host  string    `json:"host"`
+	PID   int32     `json:"pid"`
+	Login time.Time `json:"login"`
+	// Logout is zero while the session is open
+	Logout time.Time `json:"logout"`
+	// End is EndLogout, EndCrash (the system booted again first), EndDown
+	// (it was shut down) or EndLoggedIn
+	End string `json:"end"`
+}
+
+// Sessions pairs the USER_PROCESS records of a wtmp file with the
+// DEAD_PROCESS record closing the same terminal, newest login first
+func Sessions(records []Record) []Session {
+	var sessions []Session
+	open := make(map[string]int)
+	closeAll := func(at time.Time, end string) {
+		for line, i := range open {
+			sessions[i].Logout, sessions[i].End = at, end
+			delete(open, line)
+		}
+	}
+	for _, r := range records {
+		switch {
+		case r.Type == UserProcess && r.User != "":
+			if i, ok := open[r.Line]; ok {
+				sessions[i].Logout, sessions[i].End = r.Time, EndLogout
+			}
+			open[r.Line] = len(sessions)
+			sessions = append(sessions, Session{
+				User: r.User, Line: r.Line, Host: r.Source(), PID: r.PID,
+				Login: r.Time, End: EndLoggedIn,
+			})
+		case r.Type == DeadProcess:
+			if i, ok := open[r.Line]; ok {
+				sessions[i].Logout, sessions[i].End = r.Time, EndLogout
+				delete(open, r.Line)
+			}
+		case r.Type == BootTime:
+			closeAll(r.Time, EndCrash)
+		case r.Type == RunLevel && r.User == "shutdown":
+			closeAll(r.Time, EndDown)
+		}
+	}
+	slices.Reverse(sessions)
+	return sessions
+}
+
+// Failures returns the btmp records of username, or of every user when it
+// is empty, newest first
+func Failures(records []Record, username string) []Record {
+	var failures []Record
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.User != "" && (username == "" || r.User == username) {
+			failures = append(failures, r)
+		}
+	}
+	return failures
+}
+
+// Summary is the login history of a user as shown by user info
+type Summary struct {
+	// Last is the most recent successful login, nil when none is recorded
+	Last *Login `json:"last_login"`
+	// Failures are the failed logins after Last, newest first
+	Failures []Record `json:"failures"`
+	// FailuresKnown is false when the btmp file could not be read
+	FailuresKnown bool `json:"failures_known"`
+	// Errors lists the files that exist but could not be read
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Summarize reads the wtmp, lastlog, lastlog2 and btmp files of root and
+// returns the last successful login of username and the failed attempts
+// since. uid selects the lastlog entry; pass -1 to skip it. Missing files
+// are skipped, as most systems keep only some of them.
+func Summarize(root sysroot.Root, username string, uid int) Summary {
+	var summary Summary
+	failed := func(err error) bool {
+		if err == nil {
+			return false
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			summary.Errors = append(summary.Errors, err.Error())
+		}
+		return true
+	}
+	latest := func(login Login) {
+		if summary.Last == nil || login.Time.After(summary.Last.Time) {
+			summary.Last = &login
+		}
+	}
+
+	if records, err := ReadFile(root.Path(sysroot.WtmpFile)); !failed(err) {
+		for i := len(records) - 1; i >= 0; i-- {
+			r := records[i]
+			if r.Type == UserProcess && r.User == username {
+				latest(Login{Time: r.Time, Line: r.Line, Host: r.Source(), Source: "wtmp"})
+				break
+			}
+		}
+	}
+	if login, ok, err := Lastlog2(root.Path(sysroot.Lastlog2File), username); !failed(err) && ok {
+		latest(login)
+	}
+	if uid >= 0 {
+		if login, ok, err := Lastlog(root.Path(sysroot.LastlogFile), uid); !failed(err) && ok {
+			latest(login)
+		}
+	}
+
+	records, err := ReadFile(root.Path(sysroot.BtmpFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		summary.FailuresKnown = true
+	} else if !failed(err) {
+		summary.FailuresKnown = true
+		for _, r := range Failures(records, username) {
+			if summary.Last != nil && !r.Time.After(summary.Last.Time) {
+				break
+			}
+			summary.Failures = append(summary.Failures, r)
+		}
+	}
+	return summary
+}
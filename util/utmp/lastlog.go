@@ -0,0 +1,97 @@
+package utmp
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// LastlogRecordSize is the size of struct lastlog on Linux: a 32-bit time,
+// the terminal and the host. The file is indexed by UID and usually sparse.
+const LastlogRecordSize = 292
+
+// Login is the last successful login of a user
+type Login struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+	Host string    `json:"host"`
+	// Service is the PAM service, only recorded by lastlog2
+	Service string `json:"service,omitempty"`
+	// Source names the file the login was read from: wtmp, lastlog or
+	// lastlog2
+	Source string `json:"source"`
+}
+
+// Lastlog reads the entry of uid from a lastlog file; ok is false when the
+// user never logged in
+func Lastlog(path string, uid int) (login Login, ok bool, err error) {
+	if uid < 0 {
+		return Login{}, false, fmt.Errorf("invalid uid %d", uid)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return Login{}, false, err
+	}
+	defer file.Close()
+
+	b := make([]byte, LastlogRecordSize)
+	if _, err := file.ReadAt(b, int64(uid)*LastlogRecordSize); err != nil {
+		if err == io.EOF {
+			return Login{}, false, nil
+		}
+		return Login{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	sec := binary.NativeEndian.Uint32(b)
+	if sec == 0 {
+		return Login{}, false, nil
+	}
+	return Login{
+		Time:   time.Unix(int64(sec), 0),
+		Line:   cString(b[4 : 4+lineSize]),
+		Host:   cString(b[4+lineSize : 4+lineSize+hostSize]),
+		Source: "lastlog",
+	}, true, nil
+}
+
+// Lastlog2 reads the entry of username from a lastlog2 database, which
+// util-linux's pam_lastlog2 maintains instead of the Y2038-limited lastlog
+func Lastlog2(path, username string) (login Login, ok bool, err error) {
+	// Opening a missing file read-only would create it
+	if _, err := os.Stat(path); err != nil {
+		return Login{}, false, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return Login{}, false, err
+	}
+	defer db.Close()
+
+	var (
+		sec                 int64
+		line, host, service sql.NullString
+	)
+	err = db.QueryRow("SELECT Time, TTY, RemoteHost, Service FROM Lastlog2 WHERE Name = ?", username).
+		Scan(&sec, &line, &host, &service)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Login{}, false, nil
+	}
+	if err != nil {
+		return Login{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if sec == 0 {
+		return Login{}, false, nil
+	}
+	return Login{
+		Time:    time.Unix(sec, 0),
+		Line:    line.String,
+		Host:    host.String,
+		Service: service.String,
+		Source:  "lastlog2",
+	}, true, nil
+}
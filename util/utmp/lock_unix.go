@@ -0,0 +1,15 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package utmp
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes the fcntl write lock glibc's utmp functions take, released
+// when file is closed
+func lock(file *os.File) error {
+	return syscall.FcntlFlock(file.Fd(), syscall.F_SETLKW, &syscall.Flock_t{Type: syscall.F_WRLCK})
+}
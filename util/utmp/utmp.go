@@ -0,0 +1,171 @@
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Type is the ut_type of a record
+type Type int16
+
+// Record types of utmp(5)
+const (
+	Empty Type = iota
+	RunLevel
+	BootTime
+	NewTime
+	OldTime
+	InitProcess
+	LoginProcess
+	UserProcess
+	DeadProcess
+	Accounting
+)
+
+// String returns the constant name used by utmp(5)
+func (t Type) String() string {
+	names := []string{"EMPTY", "RUN_LVL", "BOOT_TIME", "NEW_TIME", "OLD_TIME",
+		"INIT_PROCESS", "LOGIN_PROCESS", "USER_PROCESS", "DEAD_PROCESS", "ACCOUNTING"}
+	if t >= 0 && int(t) < len(names) {
+		return names[t]
+	}
+	return fmt.Sprintf("TYPE_%d", int(t))
+}
+
+// RecordSize is the size of struct utmp with glibc on 64-bit Linux, where
+// ut_tv holds 32-bit seconds for compatibility with 32-bit programs
+const RecordSize = 384
+
+// Field offsets in struct utmp
+const (
+	offType    = 0
+	offPID     = 4
+	offLine    = 8
+	offID      = 40
+	offUser    = 44
+	offHost    = 76
+	offExit    = 332
+	offSession = 336
+	offTime    = 340
+	offAddr    = 348
+	lineSize   = 32
+	idSize     = 4
+	userSize   = 32
+	hostSize   = 256
+)
+
+// Record is one utmp, wtmp or btmp entry
+type Record struct {
+	Type Type  `json:"type"`
+	PID  int32 `json:"pid"`
+	// Line is the terminal without "/dev/", e.g. "pts/0" or "ssh:notty"
+	Line string `json:"line"`
+	// ID is the terminal suffix or inittab ID
+	ID   string `json:"id"`
+	User string `json:"user"`
+	Host string `json:"host"`
+	// Termination and Exit are the status of a DEAD_PROCESS
+	Termination int16     `json:"termination,omitempty"`
+	Exit        int16     `json:"exit,omitempty"`
+	Session     int32     `json:"session,omitempty"`
+	Time        time.Time `json:"time"`
+	// Addr is the remote address, nil when not recorded
+	Addr net.IP `json:"addr,omitempty"`
+}
+
+// Source returns the remote host, or the address when no host is recorded
+func (r Record) Source() string {
+	if r.Host == "" && r.Addr != nil {
+		return r.Addr.String()
+	}
+	return r.Host
+}
+
+// Decode parses one RecordSize byte record
+func Decode(b []byte) (Record, error) {
+	if len(b) < RecordSize {
+		return Record{}, fmt.Errorf("short utmp record: %d bytes", len(b))
+	}
+	order := binary.NativeEndian
+	r := Record{
+		Type:        Type(order.Uint16(b[offType:])),
+		PID:         int32(order.Uint32(b[offPID:])),
+		Line:        cString(b[offLine : offLine+lineSize]),
+		ID:          cString(b[offID : offID+idSize]),
+		User:        cString(b[offUser : offUser+userSize]),
+		Host:        cString(b[offHost : offHost+hostSize]),
+		Termination: int16(order.Uint16(b[offExit:])),
+		Exit:        int16(order.Uint16(b[offExit+2:])),
+		Session:     int32(order.Uint32(b[offSession:])),
+	}
+	// Seconds are unsigned so records keep working after 2038
+	sec, usec := order.Uint32(b[offTime:]), order.Uint32(b[offTime+4:])
+	r.Time = time.Unix(int64(sec), int64(usec)*1000)
+
+	addr := b[offAddr : offAddr+16]
+	switch {
+	case zero(addr):
+	case zero(addr[4:]):
+		r.Addr = net.IPv4(addr[0], addr[1], addr[2], addr[3]).To4()
+	default:
+		r.Addr = net.IP(bytes.Clone(addr))
+	}
+	return r, nil
+}
+
+// Read decodes every record of r; a truncated trailing record is ignored,
+// as last(1) does
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	buf := make([]byte, RecordSize)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return records, nil
+			}
+			return records, err
+		}
+		record, err := Decode(buf)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// ReadFile decodes the records of a utmp, wtmp or btmp file
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// cString returns the bytes of b up to the first NUL
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// zero reports whether every byte of b is zero
+func zero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package utmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bariiss/pam-auth/util/sysroot"
+)
+
+// Encode returns the RecordSize byte form of r
+func Encode(r Record) []byte {
+	b := make([]byte, RecordSize)
+	order := binary.NativeEndian
+	order.PutUint16(b[offType:], uint16(r.Type))
+	order.PutUint32(b[offPID:], uint32(r.PID))
+	copy(b[offLine:offLine+lineSize], r.Line)
+	copy(b[offID:offID+idSize], r.ID)
+	copy(b[offUser:offUser+userSize], r.User)
+	copy(b[offHost:offHost+hostSize], r.Host)
+	order.PutUint16(b[offExit:], uint16(r.Termination))
+	order.PutUint16(b[offExit+2:], uint16(r.Exit))
+	order.PutUint32(b[offSession:], uint32(r.Session))
+	order.PutUint32(b[offTime:], uint32(r.Time.Unix()))
+	order.PutUint32(b[offTime+4:], uint32(r.Time.Nanosecond()/1000))
+	if v4 := r.Addr.To4(); v4 != nil {
+		copy(b[offAddr:], v4)
+	} else {
+		copy(b[offAddr:offAddr+16], r.Addr)
+	}
+	return b
+}
+
+// NewRecord returns a record of typ for a process on the terminal line,
+// stamped with the current time. A "/dev/" prefix is dropped from line, the
+// ID is its last four characters as login(1) derives it, and a host that is
+// an IP address is also stored as the address.
+func NewRecord(typ Type, pid int, line, user, host string) Record {
+	line = strings.TrimPrefix(line, "/dev/")
+	id := line
+	if len(id) > idSize {
+		id = id[len(id)-idSize:]
+	}
+	return Record{
+		Type: typ,
+		PID:  int32(pid),
+		Line: line,
+		ID:   id,
+		User: user,
+		Host: host,
+		Time: time.Now(),
+		Addr: net.ParseIP(host),
+	}
+}
+
+// NoTTY reports whether line names a session without a terminal, such as
+// "ssh:notty"
+func NoTTY(line string) bool {
+	return strings.HasSuffix(line, ":notty")
+}
+
+// Append adds r to the end of a wtmp or btmp file, as updwtmp(3) does. A
+// missing file is not created: like glibc, that disables the log.
+func Append(path string, r Record) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := lock(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := file.Write(Encode(r)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Update writes r over the utmp entry of the same terminal ID, or appends it
+// when the terminal has none, as pututxline(3) does. A missing file is
+// not created.
+func Update(path string, r Record) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := lock(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	offset := int64(0)
+	buf := make([]byte, RecordSize)
+	for {
+		_, err := file.ReadAt(buf, offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		existing, _ := Decode(buf)
+		if process(existing.Type) && existing.ID == r.ID {
+			break
+		}
+		offset += RecordSize
+	}
+	if _, err := file.WriteAt(Encode(r), offset); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// process reports whether t is a process record, whose terminal ID
+// identifies the utmp entry
+func process(t Type) bool {
+	switch t {
+	case InitProcess, LoginProcess, UserProcess, DeadProcess:
+		return true
+	}
+	return false
+}
+
+// RecordLogin writes the USER_PROCESS record login to utmp and wtmp under
+// root. Sessions without a terminal only go to wtmp, as sshd does, since
+// they have no terminal ID to keep them apart in utmp.
+func RecordLogin(root sysroot.Root, login Record) error {
+	if !NoTTY(login.Line) {
+		if err := Update(root.Path(sysroot.UtmpFile), login); err != nil {
+			return err
+		}
+	}
+	return Append(root.Path(sysroot.WtmpFile), login)
+}
+
+// RecordLogout closes the session opened by RecordLogin with a
+// DEAD_PROCESS record of the same process and terminal
+func RecordLogout(root sysroot.Root, login Record) error {
+	logout := login
+	logout.Type = DeadProcess
+	logout.User, logout.Host, logout.Addr = "", "", nil
+	logout.Time = time.Now()
+	if !NoTTY(logout.Line) {
+		if err := Update(root.Path(sysroot.UtmpFile), logout); err != nil {
+			return err
+		}
+	}
+	return Append(root.Path(sysroot.WtmpFile), logout)
+}
+
+// RecordFailure writes a failed login to btmp. login(1) and sshd record
+// failures as LOGIN_PROCESS records.
+func RecordFailure(root sysroot.Root, failure Record) error {
+	failure.Type = LoginProcess
+	return Append(root.Path(sysroot.BtmpFile), failure)
+}